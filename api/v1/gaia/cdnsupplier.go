@@ -55,7 +55,7 @@ type CdnSupplierList struct {
 //		@Router		/gaia/cdnsuppliers/new [post]
 func CreateCDNSupplier(c *gin.Context) {
 	appG := app.Gin{C: c}
-	k8sClients, err := k8s.GetClientWithPanic()
+	k8sClients, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -113,7 +113,7 @@ func CreateCDNSupplier(c *gin.Context) {
 //	@Router		/gaia/cdnsuppliers/recycle [post]
 func DeleteCDNSupplier(c *gin.Context) {
 	appG := app.Gin{C: c}
-	k8sClients, err := k8s.GetClientWithPanic()
+	k8sClients, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -148,7 +148,7 @@ func DeleteCDNSupplier(c *gin.Context) {
 // @Router		/gaia/cdnsuppliers/update [post]
 func UpdateCDNSupplier(c *gin.Context) {
 	appG := app.Gin{C: c}
-	k8sClients, err := k8s.GetClientWithPanic()
+	k8sClients, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
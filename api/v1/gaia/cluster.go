@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	platformv1alpha1 "github.com/lmxia/gaia/pkg/apis/platform/v1alpha1"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
 
@@ -25,23 +26,46 @@ type LabelUri struct {
 // @Summary	查看全部clusters
 // @accept		application/json
 // @Produce	application/json
-// @Success	200	{object}	v1alpha1.ManagedClusterList
+// @Param		label		query		string	false	"Label selector"
+// @Param		consistent	query		bool	false	"Read from the apiserver instead of the cache"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200	{object}	app.ResponseExtra{data=v1alpha1.ManagedClusterList}
 // @Failure	500	{object}	app.Response
 // @Router		/gaia/clusters [get]
 func GetClusters(c *gin.Context) {
 	appG := app.Gin{C: c}
-	k8sClient, err := k8s.GetClientWithPanic()
+	var q ListQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
-	clusters, err := k8sClient.Gaiaclient.PlatformV1alpha1().ManagedClusters(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	var clusters *platformv1alpha1.ManagedClusterList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.ManagedClustersResource, metav1.NamespaceAll, q.Consistent || !q.Cacheable()); ok {
+		clusters, err = cache.ListManagedClusters(q.Label)
+	} else {
+		clusters, err = k8sClient.Gaiaclient.PlatformV1alpha1().ManagedClusters(metav1.NamespaceAll).List(context.TODO(), q.ListOptions(q.Label))
+	}
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(clusters)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", clusters)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", clusters)
 }
 
 // @Tags		Cluster
@@ -71,7 +95,7 @@ func GetClusterLabel(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
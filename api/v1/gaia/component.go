@@ -42,7 +42,7 @@ func GetComponentField(c *gin.Context) {
 	if len(u.Name) == 0 {
 		appG.Fail(http.StatusBadRequest, errors.New("cannot get the params: name"), nil)
 	} else {
-		k8sClient, err := k8s.GetClientWithPanic()
+		k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 		if err != nil {
 			appG.Fail(http.StatusInternalServerError, err, nil)
 			return
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	appv1alpha1 "github.com/lmxia/gaia/pkg/apis/apps/v1alpha1"
 	"github.com/lmxia/gaia/pkg/common"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
@@ -19,6 +20,12 @@ type DescriptionUri struct {
 	DescName  string `uri:"descName" binding:"required"`
 }
 
+type ListQuery struct {
+	k8s.ListParams
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
+}
+
 type SCNUri struct {
 	ScnID1 string `form:"scn1" json:"scn1" binding:"required"`
 	ScnID2 string `form:"scn2" json:"scn2" binding:"required"`
@@ -46,7 +53,7 @@ func DeleteDescription(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClients, err := k8s.GetClientWithPanic()
+	k8sClients, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -63,25 +70,93 @@ func DeleteDescription(c *gin.Context) {
 // @Tags		Descriptions
 // @accept		application/json
 // @Produce	application/json
-// @Success	200	{object}	v1alpha1.DescriptionList
+// @Param		label		query		string	false	"Label selector"
+// @Param		consistent	query		bool	false	"Read from the apiserver instead of the cache"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200	{object}	app.ResponseExtra{data=v1alpha1.DescriptionList}
 // @Failure	500	{object}	app.Response
 // @Router		/gaia/descriptions [get]
 func GetDescriptions(c *gin.Context) {
 	appG := app.Gin{C: c}
-	k8sClient, err := k8s.GetClientWithPanic()
+	var q ListQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		klog.Infof("get k8sClient failed: %v", err)
 		return
 	}
 
-	descriptions, err := k8sClient.Gaiaclient.AppsV1alpha1().Descriptions(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	var descriptions *appv1alpha1.DescriptionList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.DescriptionsResource, metav1.NamespaceAll, q.Consistent || !q.Cacheable()); ok {
+		descriptions, err = cache.ListDescriptions(q.Label)
+	} else {
+		descriptions, err = k8sClient.Gaiaclient.AppsV1alpha1().Descriptions(metav1.NamespaceAll).List(context.TODO(), q.ListOptions(q.Label))
+	}
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		klog.Infof("get desc failed: %v", err)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", descriptions)
+	total, err := q.Paginate(descriptions)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", descriptions)
+}
+
+// @Summary	导出某个description及gaia为其部署的资源
+// @Tags		Descriptions
+// @Produce	application/gzip
+// @Produce	application/zip
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		descName	path		string	true	"DescName"
+// @Param		archive		query		string	false	"tar (gzipped, default) or zip"
+// @Param		format		query		string	false	"yaml (default) or json"
+// @Param		clean		query		bool	false	"Strip what the cluster filled in"
+// @Success	200
+// @Failure	500			{object}	app.Response
+// @Router		/gaia/descriptions/{namespace}/{descName}/export [get]
+func ExportDescription(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u DescriptionUri
+		q k8s.ArchiveParams
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	objs, err := k8sClient.ExportDescription(context.TODO(), u.Namespace, u.DescName, &q.ExportParams)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	data, ext, contentType, err := q.Pack(objs)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.SuccessFile(http.StatusOK, u.DescName+ext, contentType, data)
 }
 
 // @Summary	查看全部具体某个 description
@@ -95,14 +170,21 @@ func GetDescriptions(c *gin.Context) {
 // @Router		/gaia/descriptions/{namespace}/{descName} [get]
 func GetDescription(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var u DescriptionUri
+	var (
+		u DescriptionUri
+		q k8s.ExportParams
+	)
 
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -113,7 +195,26 @@ func GetDescription(c *gin.Context) {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", description)
+	if !q.Exporting() {
+		appG.Success(http.StatusOK, "ok", description)
+		return
+	}
+
+	exported, err := q.Export(description)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if q.Format == "" {
+		appG.Success(http.StatusOK, "ok", exported)
+		return
+	}
+	data, err := q.Encode(exported)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.SuccessFile(http.StatusOK, description.Name+q.Ext(), q.ContentType(), data)
 }
 
 // @Summary	查看全部具体某个 description 的Status
@@ -135,7 +236,7 @@ func GetDescriptionStatus(c *gin.Context) {
 	}
 
 	if len(u.Namespace) != 0 && len(u.Name) != 0 {
-		k8sClient, err := k8s.GetClientWithPanic()
+		k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 		if err != nil {
 			appG.Fail(http.StatusInternalServerError, err, nil)
 			return
@@ -179,7 +280,7 @@ func ScnsIsInTheSameDesc(c *gin.Context) {
 	}
 
 	if len(u.ScnID1) != 0 && len(u.ScnID2) != 0 {
-		k8sClient, err := k8s.GetClientWithPanic()
+		k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 		if err != nil {
 			appG.Fail(http.StatusInternalServerError, err, nil)
 			klog.Infof("get k8sClient failed: %v", err)
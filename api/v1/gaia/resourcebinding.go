@@ -32,7 +32,7 @@ func UpdateSelectedRB(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClients, err := k8s.GetClientWithPanic()
+	k8sClients, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -0,0 +1,79 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxApplyBodySize bounds the documents of one apply request.
+const maxApplyBodySize = 10 << 20
+
+type ApplyQuery struct {
+	Namespace string `form:"namespace"`
+	DryRun    bool   `form:"dryRun"`
+	Force     bool   `form:"force"`
+}
+
+// @Summary	用server-side apply导入多个yaml或json对象
+// @Tags		Resources
+// @accept		application/yaml
+// @accept		application/json
+// @Produce	json
+// @Param		namespace	query		string	false	"Namespace of the objects that have none, default when empty"
+// @Param		dryRun		query		bool	false	"Validate without persisting"
+// @Param		force		query		bool	false	"Take over fields owned by other managers"
+// @Param		RequestBody	body		string	true	"YAML or JSON documents"
+// @Success	200			{object}	app.Response{data=[]k8s.ApplyResult}
+// @Success	207			{object}	app.Response{data=[]k8s.ApplyResult}
+// @Failure	400			{object}	app.Response
+// @Router		/apply [post]
+func PostApply(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q ApplyQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if q.Namespace == "" {
+		q.Namespace = metav1.NamespaceDefault
+	}
+
+	objs, err := k8s.DecodeObjects(http.MaxBytesReader(c.Writer, c.Request.Body, maxApplyBodySize))
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if len(objs) == 0 {
+		appG.Fail(http.StatusBadRequest, fmt.Errorf("no objects to apply"), nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	results := k8sClient.Apply(context.TODO(), objs, k8s.ApplyOptions{
+		Namespace: q.Namespace,
+		DryRun:    q.DryRun,
+		Force:     q.Force,
+	})
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		appG.Success(http.StatusMultiStatus, fmt.Sprintf("%d of %d objects failed", failed, len(results)), results)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", results)
+}
@@ -0,0 +1,673 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	"github.com/lmxia/nightwatcher/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+)
+
+// Strategies of a rollout.
+const (
+	// StrategyCanary runs a share of the replicas with the new containers next to the stable ones.
+	StrategyCanary = "canary"
+	// StrategyBlueGreen runs all the replicas with the new containers aside and switches the traffic
+	// over to them before updating the deployment.
+	StrategyBlueGreen = "bluegreen"
+)
+
+// Phases of a rollout.
+const (
+	RolloutProgressing = "Progressing"
+	RolloutPromoting   = "Promoting"
+	RolloutSucceeded   = "Succeeded"
+	RolloutAborted     = "Aborted"
+	RolloutFailed      = "Failed"
+)
+
+var (
+	// rolloutInterval is how often a rollout checks on its pods.
+	rolloutInterval = 10 * time.Second
+	// rolloutAvailableTimeout is how long the new pods of a rollout get to become available.
+	rolloutAvailableTimeout = 10 * time.Minute
+	// rolloutPromotionTimeout is how long the stable deployment gets to roll out the new containers.
+	rolloutPromotionTimeout = 10 * time.Minute
+)
+
+// RolloutBody starts a rollout of new containers of a deployment. The new pods are analysed once they
+// are available, the deployment is updated when they stay healthy long enough.
+type RolloutBody struct {
+	Strategy string `json:"strategy" binding:"required,oneof=canary bluegreen"`
+	// updates keyed by container name
+	Containers map[string]k8s.ContainerUpdate `json:"containers" binding:"required,min=1"`
+	// the share of the replicas the canary gets, 10 when empty
+	Percent int32 `json:"percent" binding:"omitempty,min=1,max=100"`
+	// how long the new pods are analysed once available, 300 when empty
+	AnalysisSeconds int32 `json:"analysisSeconds" binding:"omitempty,min=1"`
+	// the restarts of the new pods, in all, that abort the rollout when exceeded
+	MaxRestarts int32 `json:"maxRestarts" binding:"omitempty,min=0"`
+	// the share of the new pods that must stay ready once available, 100 when empty
+	MinReadyPercent int32 `json:"minReadyPercent" binding:"omitempty,min=1,max=100"`
+	// asked to hermes during the analysis, $namespace and $deployment stand for the new pods' ones
+	PromQL string `json:"promQL"`
+	// the rollout aborts when a sample of PromQL is above it
+	PromThreshold float64 `json:"promThreshold"`
+}
+
+// RolloutStatus is kept in the RolloutAnnotation of the deployment.
+type RolloutStatus struct {
+	Strategy string `json:"strategy"`
+	Phase    string `json:"phase"`
+	Message  string `json:"message,omitempty"`
+	// the deployment running the new pods
+	Target    string      `json:"target"`
+	StartTime metav1.Time `json:"startTime"`
+	// the rollout is over by then, one still running after it was left over by a stopped process
+	Deadline metav1.Time `json:"deadline"`
+}
+
+func (s *RolloutStatus) running() bool {
+	return s.Phase == RolloutProgressing || s.Phase == RolloutPromoting
+}
+
+// rollouts are the rollouts running in this process, keyed by rolloutKey.
+var rollouts = struct {
+	sync.Mutex
+	cancels map[string]context.CancelFunc
+}{cancels: make(map[string]context.CancelFunc)}
+
+// rolloutKey names the rollout of the deployment namespace/name of the cluster targeted by ctx.
+func rolloutKey(ctx context.Context, namespace, name string) string {
+	cluster, _ := utils.ClusterFrom(ctx)
+	return cluster + "/" + namespace + "/" + name
+}
+
+// @Summary	金丝雀或蓝绿发布deployment
+// @accept		application/json
+// @Produce	json
+// @Param		namespace		path		string			true	"Namespace"
+// @Param		deploymentName	path		string			true	"DeploymentName"
+// @Param		RequestBody		body		v1.RolloutBody	true	"RequestBody"
+// @Success	202				{object}	app.Response{data=v1.RolloutStatus}
+// @Failure	403				{object}	app.Response
+// @Failure	409				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/rollouts/{namespace}/{deploymentName} [post]
+func PostRollout(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u DeploymentUri
+		b RolloutBody
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&b); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if b.Percent == 0 {
+		b.Percent = 10
+	}
+	if b.AnalysisSeconds == 0 {
+		b.AnalysisSeconds = 300
+	}
+	if b.MinReadyPercent == 0 {
+		b.MinReadyPercent = 100
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	// the rollout carries on with the service account's clients once the caller may do all it does, the
+	// caller's credentials may expire before it is over.
+	serviceClient, err := k8s.GetServiceClient(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8sClient.Authorize(context.TODO(), k8s.RolloutAccess(u.Namespace, b.Strategy == StrategyBlueGreen)); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+
+	stable, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	key := rolloutKey(c.Request.Context(), stable.Namespace, stable.Name)
+	if status := rolloutStatusOf(stable); status != nil && status.running() {
+		rollouts.Lock()
+		_, ok := rollouts.cancels[key]
+		rollouts.Unlock()
+		left := &rolloutRun{client: k8sClient, namespace: stable.Namespace, name: stable.Name, status: *status}
+		reason := ""
+		if !ok {
+			if reason, err = left.stale(context.TODO()); err != nil {
+				appG.Fail(apiStatusCode(err), err, nil)
+				return
+			}
+		}
+		if reason == "" {
+			appG.Fail(http.StatusConflict, errors.New("deployment "+stable.Name+" is already rolling out to "+status.Target), nil)
+			return
+		}
+		if err := left.expire(context.TODO(), reason); err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+	}
+
+	r := &rolloutRun{client: k8sClient, body: b, namespace: stable.Namespace, name: stable.Name}
+	target, err := r.target(context.TODO(), stable)
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	rollouts.Lock()
+	if _, ok := rollouts.cancels[key]; ok {
+		rollouts.Unlock()
+		appG.Fail(http.StatusConflict, errors.New("deployment "+stable.Name+" is already rolling out"), nil)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	rollouts.cancels[key] = cancel
+	rollouts.Unlock()
+
+	status, err := r.start(ctx, stable, target)
+	if err != nil {
+		rollouts.Lock()
+		delete(rollouts.cancels, key)
+		rollouts.Unlock()
+		cancel()
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	r.client = serviceClient
+	go func() {
+		defer func() {
+			rollouts.Lock()
+			delete(rollouts.cancels, key)
+			rollouts.Unlock()
+			cancel()
+		}()
+		r.run(ctx)
+	}()
+	appG.Success(http.StatusAccepted, "ok", status)
+}
+
+// @Summary	查看deployment的发布状态
+// @Produce	json
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		deploymentName	path		string	true	"DeploymentName"
+// @Success	200				{object}	app.Response{data=v1.RolloutStatus}
+// @Failure	404				{object}	app.Response
+// @Router		/k8s/rollouts/{namespace}/{deploymentName} [get]
+func GetRollout(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u DeploymentUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	stable, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	status := rolloutStatusOf(stable)
+	if status == nil {
+		appG.Fail(http.StatusNotFound, errors.New("deployment "+stable.Name+" has no rollout"), nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", status)
+}
+
+// @Summary	中止deployment的发布
+// @Produce	json
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		deploymentName	path		string	true	"DeploymentName"
+// @Success	200				{object}	app.Response
+// @Failure	409				{object}	app.Response
+// @Router		/k8s/rollouts/{namespace}/{deploymentName} [delete]
+func DeleteRollout(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u DeploymentUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	stable, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	status := rolloutStatusOf(stable)
+	if status == nil || !status.running() {
+		appG.Fail(http.StatusNotFound, errors.New("deployment "+stable.Name+" is not rolling out"), nil)
+		return
+	}
+
+	rollouts.Lock()
+	cancel, ok := rollouts.cancels[rolloutKey(c.Request.Context(), stable.Namespace, stable.Name)]
+	rollouts.Unlock()
+	if ok {
+		if status.Phase == RolloutPromoting {
+			appG.Fail(http.StatusConflict, errors.New("deployment "+stable.Name+" is being promoted"), nil)
+			return
+		}
+		// the rollout cleans up after itself
+		cancel()
+		appG.Success(http.StatusOK, "ok", nil)
+		return
+	}
+
+	// left over by a previous process
+	r := &rolloutRun{client: k8sClient, namespace: stable.Namespace, name: stable.Name, status: *status}
+	if err := r.cleanup(context.TODO()); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if err := r.setPhase(context.TODO(), RolloutAborted, "aborted"); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+func rolloutStatusOf(deployment *appsv1.Deployment) *RolloutStatus {
+	data, ok := deployment.Annotations[k8s.RolloutAnnotation]
+	if !ok {
+		return nil
+	}
+	var status RolloutStatus
+	if err := json.Unmarshal([]byte(data), &status); err != nil {
+		klog.Errorf("invalid rollout status of deployment %s/%s: %v", deployment.Namespace, deployment.Name, err)
+		return nil
+	}
+	return &status
+}
+
+// rolloutRun carries a rollout of the deployment name, the stable one, through.
+type rolloutRun struct {
+	client    *k8s.ClientManager
+	body      RolloutBody
+	namespace string
+	name      string
+	status    RolloutStatus
+	// the selector of the new pods
+	selector map[string]string
+	// the stable deployment, what the events are about
+	ref corev1.ObjectReference
+}
+
+// target builds the deployment running the new pods.
+func (r *rolloutRun) target(ctx context.Context, stable *appsv1.Deployment) (*appsv1.Deployment, error) {
+	replicas := int32(1)
+	if stable.Spec.Replicas != nil && *stable.Spec.Replicas > 0 {
+		replicas = *stable.Spec.Replicas
+	}
+	if r.body.Strategy == StrategyCanary {
+		track := int32(math.Ceil(float64(replicas) * float64(r.body.Percent) / 100))
+		return k8s.RolloutTarget(stable, k8s.TrackCanary, track, r.body.Containers, nil)
+	}
+
+	// the green pods must stay out of the services and of the stable deployment
+	services, err := r.client.RoutingServices(ctx, stable)
+	if err != nil {
+		return nil, err
+	}
+	hidden := sets.New[string]()
+	for _, service := range services {
+		hidden.Insert(sets.KeySet(service.Spec.Selector).UnsortedList()...)
+	}
+	if stable.Spec.Selector != nil {
+		hidden.Insert(sets.KeySet(stable.Spec.Selector.MatchLabels).UnsortedList()...)
+		for _, expression := range stable.Spec.Selector.MatchExpressions {
+			hidden.Insert(expression.Key)
+		}
+	}
+	return k8s.RolloutTarget(stable, k8s.TrackGreen, replicas, r.body.Containers, hidden)
+}
+
+// start creates target and records the rollout in stable.
+func (r *rolloutRun) start(ctx context.Context, stable, target *appsv1.Deployment) (*RolloutStatus, error) {
+	r.ref = corev1.ObjectReference{
+		APIVersion: AppV1APIVersion,
+		Kind:       DeploymentKind,
+		Namespace:  stable.Namespace,
+		Name:       stable.Name,
+		UID:        stable.UID,
+	}
+	r.selector = target.Spec.Selector.MatchLabels
+
+	created, err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Create(ctx, target, metav1.CreateOptions{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	analysis := time.Duration(r.body.AnalysisSeconds) * time.Second
+	r.status = RolloutStatus{
+		Strategy:  r.body.Strategy,
+		Target:    created.Name,
+		StartTime: metav1.NewTime(now),
+		Deadline:  metav1.NewTime(now.Add(rolloutAvailableTimeout + analysis + rolloutPromotionTimeout)),
+	}
+	message := fmt.Sprintf("created %s with %d replicas", created.Name, *created.Spec.Replicas)
+	if err := r.setPhase(ctx, RolloutProgressing, message); err != nil {
+		_ = r.client.K8sClient.AppsV1().Deployments(r.namespace).Delete(ctx, created.Name, metav1.DeleteOptions{})
+		return nil, err
+	}
+	r.event(corev1.EventTypeNormal, "RolloutStarted", "%s rollout %s", r.body.Strategy, message)
+	return &r.status, nil
+}
+
+// run analyses the new pods, then promotes them or aborts. Both must be done by the deadline of the
+// rollout.
+func (r *rolloutRun) run(ctx context.Context) {
+	analysisCtx, cancel := context.WithDeadline(ctx, r.status.Deadline.Add(-rolloutPromotionTimeout))
+	err := r.analyse(analysisCtx)
+	cancel()
+	if err != nil {
+		phase, reason := RolloutFailed, "RolloutFailed"
+		if ctx.Err() != nil {
+			phase, reason, err = RolloutAborted, "RolloutAborted", errors.New("aborted")
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("%s was not available and analysed in time", r.status.Target)
+		}
+		r.event(corev1.EventTypeWarning, reason, "%s", err.Error())
+		r.finish(phase, err.Error())
+		return
+	}
+
+	// the promotion goes through once started
+	promotionCtx, cancel := context.WithTimeout(context.Background(), rolloutPromotionTimeout)
+	defer cancel()
+	if err := r.promote(promotionCtx); err != nil {
+		r.event(corev1.EventTypeWarning, "PromotionFailed", "%s", err.Error())
+		r.finish(RolloutFailed, err.Error())
+		return
+	}
+	r.event(corev1.EventTypeNormal, "RolloutSucceeded", "promoted %s", r.status.Target)
+	r.finish(RolloutSucceeded, "promoted "+r.status.Target)
+}
+
+// analyse waits for the new pods to be available and watches them for the analysis, it fails as soon
+// as a threshold is crossed.
+func (r *rolloutRun) analyse(ctx context.Context) error {
+	ticker := time.NewTicker(rolloutInterval)
+	defer ticker.Stop()
+
+	var availableAt time.Time
+	analysis := time.Duration(r.body.AnalysisSeconds) * time.Second
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		target, err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Get(ctx, r.status.Target, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		code, _, err := getDeploymentStatus(target)
+		if err != nil {
+			return err
+		}
+		if code == http.StatusOK && availableAt.IsZero() {
+			availableAt = time.Now()
+			r.event(corev1.EventTypeNormal, "RolloutAnalysing", "%s is available, analysing it for %s", target.Name, analysis)
+		}
+
+		if err := r.checkPods(ctx, target, !availableAt.IsZero()); err != nil {
+			return err
+		}
+		if availableAt.IsZero() {
+			continue
+		}
+		if err := r.checkMetrics(); err != nil {
+			return err
+		}
+		if time.Since(availableAt) >= analysis {
+			return nil
+		}
+	}
+}
+
+// checkPods fails when the new pods restarted too often or, once available, too few of them are ready.
+func (r *rolloutRun) checkPods(ctx context.Context, target *appsv1.Deployment, available bool) error {
+	pods, err := r.client.K8sClient.CoreV1().Pods(r.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(target.Spec.Selector),
+	})
+	if err != nil {
+		return err
+	}
+	var restarts, ready int32
+	for _, pod := range pods.Items {
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				ready++
+			}
+		}
+	}
+	if restarts > r.body.MaxRestarts {
+		return fmt.Errorf("the pods of %s restarted %d times, more than %d", target.Name, restarts, r.body.MaxRestarts)
+	}
+	if available && target.Spec.Replicas != nil && ready*100 < r.body.MinReadyPercent**target.Spec.Replicas {
+		return fmt.Errorf("%d of %d pods of %s are ready, less than %d%%", ready, *target.Spec.Replicas, target.Name, r.body.MinReadyPercent)
+	}
+	return nil
+}
+
+// checkMetrics fails when a sample of the PromQL query of the rollout is above its threshold, hermes
+// being unavailable only skips the check.
+func (r *rolloutRun) checkMetrics() error {
+	if r.body.PromQL == "" {
+		return nil
+	}
+	promQL := strings.NewReplacer("$namespace", r.namespace, "$deployment", r.status.Target).Replace(r.body.PromQL)
+	result, err := utils.QueryHermes(promQL, rolloutInterval)
+	if err != nil {
+		r.event(corev1.EventTypeWarning, "RolloutMetricsUnavailable", "query hermes: %v", err)
+		return nil
+	}
+	for _, sample := range result.QueryValV {
+		if float64(sample.Value) > r.body.PromThreshold {
+			return fmt.Errorf("%s of %s is %v, above %v", promQL, sample.Metric, sample.Value, r.body.PromThreshold)
+		}
+	}
+	for _, stream := range result.QueryValM {
+		for _, pair := range stream.Values {
+			if float64(pair.Value) > r.body.PromThreshold {
+				return fmt.Errorf("%s of %s is %v, above %v", promQL, stream.Metric, pair.Value, r.body.PromThreshold)
+			}
+		}
+	}
+	return nil
+}
+
+// promote updates the stable deployment to the new containers, with the traffic on the green pods for
+// a blue/green rollout, and removes the new pods once it rolled out. It rolls the stable deployment
+// back when the update fails.
+func (r *rolloutRun) promote(ctx context.Context) error {
+	if err := r.setPhase(ctx, RolloutPromoting, "promoting "+r.status.Target); err != nil {
+		return err
+	}
+	stable, err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	if r.body.Strategy == StrategyBlueGreen {
+		services, err := r.client.RoutingServices(ctx, stable)
+		if err != nil {
+			return err
+		}
+		if err := r.client.RouteServices(ctx, r.name, services, r.selector); err != nil {
+			return err
+		}
+		r.event(corev1.EventTypeNormal, "TrafficSwitched", "switched %d services to %s", len(services), r.status.Target)
+	}
+
+	if _, err := k8s.NewDeploymentOperation(r.client.K8sClient).PatchContainers(ctx, stable, r.body.Containers, nil); err != nil {
+		return err
+	}
+	r.event(corev1.EventTypeNormal, "RolloutPromoting", "updating %s to the containers of %s", r.name, r.status.Target)
+	if err := r.waitStable(ctx); err != nil {
+		// ctx may be over already
+		if _, rerr := k8s.NewDeploymentOperation(r.client.K8sClient).Rollback(context.Background(), r.namespace, r.name, 0); rerr != nil && !errors.Is(rerr, k8s.ErrRollbackSkipped) {
+			klog.Errorf("roll back deployment %s/%s: %v", r.namespace, r.name, rerr)
+		}
+		return err
+	}
+	return r.cleanup(ctx)
+}
+
+// waitStable waits for the stable deployment to roll out.
+func (r *rolloutRun) waitStable(ctx context.Context) error {
+	ticker := time.NewTicker(rolloutInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not roll out in time", r.name)
+		case <-ticker.C:
+		}
+		stable, err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Get(ctx, r.name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		code, _, err := getDeploymentStatus(stable)
+		if err != nil {
+			return err
+		}
+		if code == http.StatusOK {
+			return nil
+		}
+	}
+}
+
+// cleanup sends the traffic back to the stable pods and removes the new ones.
+func (r *rolloutRun) cleanup(ctx context.Context) error {
+	if err := r.client.RestoreServices(ctx, r.namespace, r.name); err != nil {
+		return err
+	}
+	err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Delete(ctx, r.status.Target, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// stale tells why a rollout recorded as running, but not by this process, is over: its deadline passed
+// or the deployment running its new pods is gone. It returns "" for a rollout that may still be running
+// in another process.
+func (r *rolloutRun) stale(ctx context.Context) (string, error) {
+	if !r.status.Deadline.IsZero() && time.Now().After(r.status.Deadline.Time) {
+		return "the rollout passed its deadline " + r.status.Deadline.UTC().Format(time.RFC3339), nil
+	}
+	_, err := r.client.K8sClient.AppsV1().Deployments(r.namespace).Get(ctx, r.status.Target, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return r.status.Target + " is gone", nil
+	}
+	return "", err
+}
+
+// expire cleans up a stale rollout and records it failed.
+func (r *rolloutRun) expire(ctx context.Context, reason string) error {
+	if err := r.cleanup(ctx); err != nil {
+		return err
+	}
+	return r.setPhase(ctx, RolloutFailed, "expired: "+reason)
+}
+
+// finish cleans up and records the outcome of the rollout.
+func (r *rolloutRun) finish(phase, message string) {
+	ctx := context.Background()
+	if err := r.cleanup(ctx); err != nil {
+		klog.Errorf("clean up the rollout of deployment %s/%s: %v", r.namespace, r.name, err)
+		message += ", clean up: " + err.Error()
+	}
+	if err := r.setPhase(ctx, phase, message); err != nil {
+		klog.Errorf("record the rollout of deployment %s/%s: %v", r.namespace, r.name, err)
+	}
+}
+
+// event records a step of the rollout as an event of the stable deployment.
+func (r *rolloutRun) event(eventType, reason, messageFmt string, args ...interface{}) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: r.name + ".",
+			Namespace:    r.namespace,
+		},
+		InvolvedObject:      r.ref,
+		Reason:              reason,
+		Message:             fmt.Sprintf(messageFmt, args...),
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: k8s.FieldManager},
+		ReportingController: k8s.FieldManager,
+		FirstTimestamp:      now,
+		LastTimestamp:       now,
+		Count:               1,
+	}
+	if _, err := r.client.K8sClient.CoreV1().Events(r.namespace).Create(context.Background(), event, metav1.CreateOptions{}); err != nil {
+		klog.Errorf("record event %s of deployment %s/%s: %v", reason, r.namespace, r.name, err)
+	}
+}
+
+func (r *rolloutRun) setPhase(ctx context.Context, phase, message string) error {
+	r.status.Phase, r.status.Message = phase, message
+	status, err := json.Marshal(&r.status)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{k8s.RolloutAnnotation: string(status)},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.client.K8sClient.AppsV1().Deployments(r.namespace).Patch(ctx, r.name, types.MergePatchType, patch, metav1.PatchOptions{})
+	return err
+}
@@ -2,14 +2,168 @@ package v1
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
+	"github.com/lmxia/nightwatcher/utils"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// configmapMaxSize is the most a ConfigMap can hold.
+const configmapMaxSize = 1 << 20
+
+type ConfigmapsQuery struct {
+	k8s.ListParams
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+// ConfigmapUploadForm is the multipart form creating a ConfigMap from files, each one under its file name.
+type ConfigmapUploadForm struct {
+	Namespace string `form:"namespace" binding:"required"`
+	Name      string `form:"name" binding:"required"`
+}
+
+type ConfigmapWriteQuery struct {
+	// validate the update on the apiserver without storing it
+	DryRun bool `form:"dryRun"`
+	// with dryRun, return the unified diff of the update against the live ConfigMap
+	Diff bool `form:"diff"`
+}
+
+// GetConfigmaps
+//
+//	@Summary	获取Configmap列表
+//	@Produce	json
+//	@Param		cluster			path		string	true	"Cluster"
+//	@Param		namespace		query		string	false	"Namespace"
+//	@Param		label			query		string	false	"Label"
+//	@Param		page			query		int		false	"Page, from 1"
+//	@Param		pageSize		query		int		false	"Page size, the whole list when empty"
+//	@Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+//	@Param		fieldSelector	query		string	false	"Field selector"
+//	@Param		nameContains	query		string	false	"Name substring"
+//	@Param		continue		query		string	false	"Continue token of the previous page"
+//	@Success	200				{object}	app.ResponseExtra
+//	@Failure	500				{object}	app.Response
+//	@Router		/k8s/configmaps [get]
+func GetConfigmaps(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q ConfigmapsQuery
+
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	configMaps, err := k8s.NewConfigmapOperation(k8sClient.K8sClient).List(context.TODO(), q.Namespace, q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(configMaps)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(configMaps.Items); i++ {
+		configMaps.Items[i].CreationTimestamp = metav1.NewTime(configMaps.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", configMaps)
+}
+
+// PostConfigmap
+//
+//	@Summary	创建Configmap资源
+//	@accept		application/json
+//	@accept		multipart/form-data
+//	@Param		cluster		path		string	true	"Cluster"
+//	@Param		namespace	formData	string	false	"Namespace, with multipart/form-data"
+//	@Param		name		formData	string	false	"Name, with multipart/form-data"
+//	@Param		files		formData	file	false	"Files keyed by file name, with multipart/form-data"
+//	@Success	200			{object}	app.Response
+//	@Failure	500			{object}	app.Response
+//	@Router		/k8s/configmaps [post]
+func PostConfigmap(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var configMap *v1.ConfigMap
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		uploaded, err := configmapFromUpload(c)
+		if err != nil {
+			appG.Fail(http.StatusBadRequest, err, nil)
+			return
+		}
+		configMap = uploaded
+	} else {
+		configMap = &v1.ConfigMap{}
+		if err := appG.C.ShouldBindJSON(configMap); err != nil {
+			appG.Fail(http.StatusBadRequest, err, nil)
+			return
+		}
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewConfigmapOperation(k8sClient.K8sClient).Create(context.TODO(), configMap)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// configmapFromUpload builds the ConfigMap of a multipart upload, from the files of its files field.
+func configmapFromUpload(c *gin.Context) (*v1.ConfigMap, error) {
+	var form ConfigmapUploadForm
+	if err := c.ShouldBind(&form); err != nil {
+		return nil, err
+	}
+	multipartForm, err := c.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	headers := multipartForm.File["files"]
+	if len(headers) == 0 {
+		return nil, errors.New("no files uploaded")
+	}
+	files := make(map[string][]byte, len(headers))
+	size := int64(0)
+	for _, header := range headers {
+		if _, ok := files[header.Filename]; ok {
+			return nil, fmt.Errorf("file %s uploaded twice", header.Filename)
+		}
+		if size += header.Size; size > configmapMaxSize {
+			return nil, fmt.Errorf("files larger than the %d bytes a ConfigMap holds", configmapMaxSize)
+		}
+		file, err := header.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[header.Filename] = content
+	}
+	return k8s.ConfigmapFromFiles(form.Namespace, form.Name, files)
+}
+
 // GetConfigmap
 //
 //	@Summary	获取Configmap资源
@@ -18,6 +172,8 @@ import (
 //	@Param		namespace	path		string	true	"Namespace"
 //	@Param		name		path		string	true	"Name"
 //	@Success	200			{object}	app.Response
+//	@Failure	400			{object}	app.Response
+//	@Failure	404			{object}	app.Response
 //	@Failure	500			{object}	app.Response
 //	@Router		/k8s/configmaps/{namespace}/{name} [get]
 func GetConfigmap(c *gin.Context) {
@@ -27,7 +183,12 @@ func GetConfigmap(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	var q k8s.ExportParams
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -35,7 +196,11 @@ func GetConfigmap(c *gin.Context) {
 	configMapOperation := k8s.NewConfigmapOperation(k8sClient.K8sClient)
 	configMap, err := configMapOperation.Get(context.TODO(), param["namespace"], param["name"])
 	if err != nil {
-		appG.Fail(http.StatusInternalServerError, err, nil)
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, configMap)
 		return
 	}
 	appG.Success(http.StatusOK, "ok", configMap)
@@ -48,30 +213,68 @@ func GetConfigmap(c *gin.Context) {
 //	@Param		cluster		path		string	true	"Cluster"
 //	@Param		namespace	path		string	true	"Namespace"
 //	@Param		name		path		string	true	"Name"
+//	@Param		dryRun		query		bool	false	"Validate the update without storing it"
+//	@Param		diff		query		bool	false	"With dryRun, return the unified diff against the live ConfigMap"
 //	@Success	200			{object}	app.Response
+//	@Failure	400			{object}	app.Response
+//	@Failure	404			{object}	app.Response
+//	@Failure	409			{object}	app.Response
 //	@Failure	500			{object}	app.Response
 //	@Router		/k8s/configmaps/{namespace}/{name} [put]
 func PutConfigmap(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var configMap v1.ConfigMap
+	var (
+		configMap v1.ConfigMap
+		q         ConfigmapWriteQuery
+	)
 	param, err := app.GetPathParameterString(c, "namespace", "name")
 	if err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err = appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if q.Diff && !q.DryRun {
+		appG.Fail(http.StatusBadRequest, errors.New("diff needs dryRun=true"), nil)
+		return
+	}
 	if err = appG.C.ShouldBind(&configMap); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 	configMapOperation := k8s.NewConfigmapOperation(k8sClient.K8sClient)
+	if q.DryRun {
+		live, updated, err := configMapOperation.DryRunUpdate(context.TODO(), param["namespace"], param["name"], &configMap)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		if !q.Diff {
+			appG.Success(http.StatusOK, "ok", updated)
+			return
+		}
+		diff, err := k8s.DiffObjects(live, updated)
+		if errors.Is(err, utils.ErrDiffTooLarge) {
+			appG.Fail(http.StatusRequestEntityTooLarge, err, nil)
+			return
+		}
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		appG.Success(http.StatusOK, "ok", diff)
+		return
+	}
 	result, err := configMapOperation.Update(context.TODO(), param["namespace"], param["name"], &configMap)
 	if err != nil {
-		appG.Fail(http.StatusInternalServerError, err, nil)
+		appG.Fail(apiStatusCode(err), err, nil)
 		return
 	}
 	appG.Success(http.StatusOK, "ok", result)
@@ -94,7 +297,7 @@ func DeleteConfigmap(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -3,18 +3,22 @@ package v1
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
-	"k8s.io/api/batch/v1beta1"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type CronJobsQuery struct {
-	Namespace string `form:"namespace"`
-	Label     string `form:"label"`
+	k8s.ListParams
+	Namespace  string `form:"namespace"`
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
 }
 
 type CronJobUri struct {
@@ -23,92 +27,133 @@ type CronJobUri struct {
 }
 
 type CronJobBody struct {
-	Spec  v1beta1.CronJobSpec `json:"spec" form:"spec"`
-	Image string              `json:"image" form:"image"`
-	Label string              `json:"label" form:"label"`
+	// replaces the spec of a single CronJob when neither image nor containers are set
+	Spec batchv1.CronJobSpec `json:"spec" form:"spec"`
+	// the image of the first container, use containers to pick others
+	Image string `json:"image" form:"image"`
+	Label string `json:"label" form:"label"`
+	// updates keyed by container name
+	Containers map[string]k8s.ContainerUpdate `json:"containers"`
 }
 
 var (
-	BatchV1BetaVersion = "batch/v1beta1"
-	CronJobKind        = "CronJob"
+	// BatchV1Version is the version of the CronJobs the handlers answer with, whatever version the
+	// cluster serves.
+	BatchV1Version = "batch/v1"
+	CronJobKind    = "CronJob"
 )
 
 func GetCronJobs(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var (
-		q        CronJobsQuery
-		listOpts metav1.ListOptions
-	)
+	var q CronJobsQuery
 
 	if err := appG.C.ShouldBindQuery(&q); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	if q.Label == "" {
-		listOpts = metav1.ListOptions{}
+	var cronjobs *batchv1.CronJobList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.CronJobsResource, q.Namespace, q.Consistent || !q.Cacheable()); ok {
+		cronjobs, err = cache.ListCronJobs(q.Namespace, q.Label)
 	} else {
-		listOpts = metav1.ListOptions{LabelSelector: q.Label}
+		var operation k8s.CronJobInterface
+		operation, err = k8s.NewCronJobOperation(k8sClient)
+		if err == nil {
+			cronjobs, err = operation.List(context.TODO(), q.Namespace, q.ListOptions(q.Label))
+		}
+	}
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
 	}
-	cronjobs, err := k8sClient.K8sClient.BatchV1beta1().CronJobs(q.Namespace).List(context.TODO(), listOpts)
+	total, err := q.Paginate(cronjobs)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", cronjobs)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", cronjobs)
 }
 
 func GetCronJob(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var u CronJobUri
+	var (
+		u CronJobUri
+		q k8s.ExportParams
+	)
 
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	cronjob, err := k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).Get(context.TODO(), u.CronJobName, metav1.GetOptions{})
-	cronjob.APIVersion = BatchV1BetaVersion
-	cronjob.Kind = CronJobKind
+	operation, err := k8s.NewCronJobOperation(k8sClient)
 	if err != nil {
-		appG.Fail(http.StatusInternalServerError, err, nil)
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	cronjob, err := operation.Get(context.TODO(), u.Namespace, u.CronJobName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		// exported as the cluster serves it, so that it applies back
+		served, err := operation.Served(cronjob)
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		exportObject(&appG, &q, served)
 		return
 	}
+	cronjob.APIVersion = BatchV1Version
+	cronjob.Kind = CronJobKind
 	appG.Success(http.StatusOK, "ok", cronjob)
 }
 
 func PostCronJob(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var b v1beta1.CronJob
+	var b batchv1.CronJob
 
 	if err := appG.C.ShouldBindJSON(&b); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	cronjob, err := k8sClient.K8sClient.BatchV1beta1().CronJobs(b.Namespace).Create(context.TODO(), &b, metav1.CreateOptions{})
+	operation, err := k8s.NewCronJobOperation(k8sClient)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	cronjob, err := operation.Create(context.TODO(), &b)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
+	cronjob.APIVersion = BatchV1Version
+	cronjob.Kind = CronJobKind
 
 	appG.Success(http.StatusOK, "Created CronJob Successfully", cronjob)
 }
@@ -130,47 +175,77 @@ func PutCronJob(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
+	operation, err := k8s.NewCronJobOperation(k8sClient)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
 	if b.Label == "" {
-		cronjob, err := k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).Get(context.TODO(), u.CronJobName, metav1.GetOptions{})
+		cronjob, err := operation.Get(context.TODO(), u.Namespace, u.CronJobName)
 		if err != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
+			appG.Fail(apiStatusCode(err), err, nil)
 			return
 		}
-		cronjob.Spec = b.Spec
-		_, err = k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).Update(context.TODO(), cronjob, metav1.UpdateOptions{})
-		if err != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
-			return
+		if b.Image != "" || len(b.Containers) > 0 {
+			err = patchCronJobContainers(operation, cronjob, &b)
+		} else {
+			cronjob.Spec = b.Spec
+			_, err = operation.Update(context.TODO(), cronjob)
 		}
-	} else {
-		// bulk update
-		listOpts = metav1.ListOptions{LabelSelector: b.Label}
-		cronjobs, err := k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).List(context.TODO(), listOpts)
 		if err != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
+			appG.Fail(apiStatusCode(err), err, nil)
 			return
 		}
-		for _, cronjob := range cronjobs.Items {
-			containers := cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers
-			if len(containers) != 1 {
-				appG.Fail(http.StatusInternalServerError, errors.New(cronjob.Name+" containers more than 2, unknown which one to update, please check"), nil)
-				return
-			}
-			cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image = b.Image
-			_, err = k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).Update(context.TODO(), &cronjob, metav1.UpdateOptions{})
-			if err != nil {
-				appG.Fail(http.StatusInternalServerError, err, nil)
-				return
-			}
+		appG.Success(http.StatusOK, "Updated CronJob Successfully", nil)
+		return
+	}
+
+	// bulk update
+	if b.Image == "" && len(b.Containers) == 0 {
+		appG.Fail(http.StatusBadRequest, errors.New("image or containers is required to update cronjobs by label"), nil)
+		return
+	}
+	listOpts = metav1.ListOptions{LabelSelector: b.Label}
+	cronjobs, err := operation.List(context.TODO(), u.Namespace, listOpts)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	results := make([]k8s.UpdateResult, 0, len(cronjobs.Items))
+	failed := 0
+	for i := range cronjobs.Items {
+		result := k8s.UpdateResult{Name: cronjobs.Items[i].Name}
+		if err := patchCronJobContainers(operation, &cronjobs.Items[i], &b); err != nil {
+			result.Error = err.Error()
+			failed++
 		}
+		results = append(results, result)
+	}
+	if failed > 0 {
+		appG.Success(http.StatusMultiStatus, fmt.Sprintf("%d of %d cronjobs failed", failed, len(results)), results)
+		return
 	}
+	appG.Success(http.StatusOK, "Updated CronJob Successfully", results)
+}
 
-	appG.Success(http.StatusOK, "Updated CronJob Successfully", nil)
+// patchCronJobContainers applies the container updates of b to the job template of cronjob.
+func patchCronJobContainers(operation k8s.CronJobInterface, cronjob *batchv1.CronJob, b *CronJobBody) error {
+	spec := &cronjob.Spec.JobTemplate.Spec.Template.Spec
+	containers, err := k8s.ResolveContainers(spec, b.Image, b.Containers)
+	if err != nil {
+		return err
+	}
+	patch, err := k8s.ContainersPatch(spec, containers, nil, "spec", "jobTemplate", "spec", "template")
+	if err != nil {
+		return err
+	}
+	_, err = operation.Patch(context.TODO(), cronjob.Namespace, cronjob.Name, types.StrategicMergePatchType, patch)
+	return err
 }
 
 func DeleteCronJob(c *gin.Context) {
@@ -183,12 +258,108 @@ func DeleteCronJob(c *gin.Context) {
 		return
 	}
 
-	k8sClient, _ := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation, err := k8s.NewCronJobOperation(k8sClient)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
 
 	propagationPolicy := metav1.DeletePropagationBackground
-	if err := k8sClient.K8sClient.BatchV1beta1().CronJobs(u.Namespace).Delete(context.TODO(), u.CronJobName, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
+	if err := operation.Delete(context.TODO(), u.Namespace, u.CronJobName, metav1.DeleteOptions{PropagationPolicy: &propagationPolicy}); err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 	appG.Success(http.StatusOK, "Deleted CronJob Successfully", nil)
 }
+
+type CronJobActionQuery struct {
+	Action string `form:"action" binding:"required,oneof=suspend resume"`
+}
+
+// @Summary	暂停或恢复cronjob
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		cronjobName	path		string	true	"CronJobName"
+// @Param		action		query		string	true	"suspend|resume"
+// @Success	200			{object}	app.Response
+// @Failure	404			{object}	app.Response
+// @Router		/k8s/cronjobs/{namespace}/{cronjobName} [post]
+func CronJobDoAction(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u CronJobUri
+		q CronJobActionQuery
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation, err := k8s.NewCronJobOperation(k8sClient)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	cronjob, err := operation.Suspend(context.TODO(), u.Namespace, u.CronJobName, q.Action == "suspend")
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	cronjob.APIVersion = BatchV1Version
+	cronjob.Kind = CronJobKind
+	appG.Success(http.StatusOK, "ok", cronjob)
+}
+
+// @Summary	立即运行一次cronjob
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		cronjobName	path		string	true	"CronJobName"
+// @Success	201			{object}	app.Response
+// @Failure	404			{object}	app.Response
+// @Router		/k8s/cronjobs/{namespace}/{cronjobName}/trigger [post]
+func TriggerCronJob(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u CronJobUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation, err := k8s.NewCronJobOperation(k8sClient)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	cronjob, err := operation.Get(context.TODO(), u.Namespace, u.CronJobName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	job, err := operation.Trigger(context.TODO(), cronjob)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusCreated, "ok", job)
+}
@@ -0,0 +1,366 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DaemonSetsQuery struct {
+	k8s.ListParams
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+type DaemonSetUri struct {
+	Namespace     string `uri:"namespace" binding:"required"`
+	DaemonSetName string `uri:"daemonsetName" binding:"required"`
+}
+
+type DaemonSetActionQuery struct {
+	Action string `form:"action" binding:"required,oneof=restart"`
+}
+
+var DaemonSetKind = "DaemonSet"
+
+// @Summary	查看daemonset列表
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		query		string	false	"Namespace"
+// @Param		label			query		string	false	"Label"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200				{object}	app.ResponseExtra
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonsets [get]
+func GetDaemonSets(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var q DaemonSetsQuery
+
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	daemonSets, err := k8sClient.K8sClient.AppsV1().DaemonSets(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(daemonSets)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(daemonSets.Items); i++ {
+		daemonSets.Items[i].CreationTimestamp = metav1.NewTime(daemonSets.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", daemonSets)
+}
+
+// @Summary	查看daemonset
+// @Produce	application/json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonsets/{namespace}/{daemonsetName} [get]
+func GetDaemonSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u DaemonSetUri
+		q k8s.ExportParams
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	daemonSet, err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Get(context.TODO(), u.Namespace, u.DaemonSetName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, daemonSet)
+		return
+	}
+	daemonSet.TypeMeta.APIVersion = AppV1APIVersion
+	daemonSet.TypeMeta.Kind = DaemonSetKind
+	daemonSet.CreationTimestamp = metav1.NewTime(daemonSet.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", daemonSet)
+}
+
+// @Summary	创建daemonset
+// @accept		application/json
+// @Param		cluster	path		string	true	"Cluster"
+// @Success	200		{object}	app.Response
+// @Failure	500		{object}	app.Response
+// @Router		/k8s/daemonsets [post]
+func PostDaemonSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var daemonSet appsv1.DaemonSet
+
+	if err := appG.C.ShouldBindJSON(&daemonSet); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Create(context.TODO(), &daemonSet)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	更新daemonset
+// @accept		application/json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonsets/{namespace}/{daemonsetName} [put]
+func PutDaemonSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u         DaemonSetUri
+		daemonSet appsv1.DaemonSet
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&daemonSet); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Update(context.TODO(), u.Namespace, u.DaemonSetName, &daemonSet)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	删除daemonset
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonsets/{namespace}/{daemonsetName} [delete]
+func DeleteDaemonSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u DaemonSetUri
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Delete(context.TODO(), u.Namespace, u.DaemonSetName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	对daemonset执行操作
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Param		action			query		string	true	"restart, a daemonset runs one pod per node and does not scale"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonsets/{namespace}/{daemonsetName} [post]
+func DaemonSetDoAction(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u DaemonSetUri
+		q DaemonSetActionQuery
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if _, err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Restart(context.TODO(), u.Namespace, u.DaemonSetName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	查看daemonset的滚动更新状态
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Param		label			query		string	false	"Label, the status of every daemonset it selects"
+// @Success	200				{object}	app.Response
+// @Failure	308				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonset_status/{namespace}/{daemonsetName} [get]
+func GetDaemonSetStatus(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u DaemonSetUri
+		q DeploymentQuery
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if q.Label == "" {
+		daemonSet, err := k8s.NewDaemonSetOperation(k8sClient.K8sClient).Get(context.TODO(), u.Namespace, u.DaemonSetName)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		status, reasons, err := getDaemonSetStatus(daemonSet)
+		respondRolloutStatus(&appG, status, reasons, err)
+		return
+	}
+	daemonSets, err := k8sClient.K8sClient.AppsV1().DaemonSets(u.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: q.Label})
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(daemonSets.Items) == 0 {
+		appG.Fail(http.StatusNotFound, errors.New("daemonsets not found"), nil)
+		return
+	}
+	// the first daemonset not rolled out yet tells the status of them all
+	for i := range daemonSets.Items {
+		status, reasons, err := getDaemonSetStatus(&daemonSets.Items[i])
+		if status != http.StatusOK {
+			respondRolloutStatus(&appG, status, reasons, err)
+			return
+		}
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// getDaemonSetStatus tells whether the rolling update of daemonSet is complete, as kubectl rollout
+// status does, with the status codes of getDeploymentStatus.
+func getDaemonSetStatus(daemonSet *appsv1.DaemonSet) (status int, reasons string, err error) {
+	if daemonSet.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		return http.StatusInternalServerError, "", fmt.Errorf("rollout status is only available for %s strategy type", appsv1.RollingUpdateDaemonSetStrategyType)
+	}
+	if daemonSet.Generation <= daemonSet.Status.ObservedGeneration {
+		if daemonSet.Status.UpdatedNumberScheduled < daemonSet.Status.DesiredNumberScheduled {
+			return http.StatusPermanentRedirect, fmt.Sprintf("Waiting for daemon set %s rollout to finish: %d out of %d new pods have been updated...", daemonSet.Name, daemonSet.Status.UpdatedNumberScheduled, daemonSet.Status.DesiredNumberScheduled), nil
+		}
+		if daemonSet.Status.NumberAvailable < daemonSet.Status.DesiredNumberScheduled {
+			return http.StatusPermanentRedirect, fmt.Sprintf("Waiting for daemon set %s rollout to finish: %d of %d updated pods are available...", daemonSet.Name, daemonSet.Status.NumberAvailable, daemonSet.Status.DesiredNumberScheduled), nil
+		}
+		return http.StatusOK, fmt.Sprintf("daemon set %s successfully rolled out", daemonSet.Name), nil
+	}
+	return http.StatusPermanentRedirect, "Waiting for daemon set spec update to be observed...", nil
+}
+
+// @Summary	查看daemonset的pod
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		daemonsetName	path		string	true	"DaemonSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/daemonset_pods/{namespace}/{daemonsetName} [get]
+func GetDaemonSetPods(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u DaemonSetUri
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewDaemonSetOperation(k8sClient.K8sClient)
+	daemonSet, err := operation.Get(context.TODO(), u.Namespace, u.DaemonSetName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	pods, err := operation.Pods(context.TODO(), daemonSet)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", pods)
+}
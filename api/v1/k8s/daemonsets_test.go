@@ -0,0 +1,70 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDaemonSetStatus(t *testing.T) {
+	newDaemonSet := func(change func(*appsv1.DaemonSet)) *appsv1.DaemonSet {
+		daemonSet := &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Generation: 2},
+			Spec: appsv1.DaemonSetSpec{
+				UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.RollingUpdateDaemonSetStrategyType},
+			},
+			Status: appsv1.DaemonSetStatus{
+				ObservedGeneration:     2,
+				DesiredNumberScheduled: 3,
+				UpdatedNumberScheduled: 3,
+				NumberAvailable:        3,
+			},
+		}
+		if change != nil {
+			change(daemonSet)
+		}
+		return daemonSet
+	}
+
+	tests := []struct {
+		name      string
+		daemonSet *appsv1.DaemonSet
+		status    int
+		reasons   string
+		err       bool
+	}{
+		{name: "rolled out", daemonSet: newDaemonSet(nil), status: http.StatusOK,
+			reasons: "daemon set agent successfully rolled out"},
+		{name: "no pods to schedule", daemonSet: newDaemonSet(func(d *appsv1.DaemonSet) {
+			d.Status = appsv1.DaemonSetStatus{ObservedGeneration: 2}
+		}), status: http.StatusOK, reasons: "daemon set agent successfully rolled out"},
+		{name: "on delete", daemonSet: newDaemonSet(func(d *appsv1.DaemonSet) {
+			d.Spec.UpdateStrategy.Type = appsv1.OnDeleteDaemonSetStrategyType
+		}), status: http.StatusInternalServerError, err: true},
+		{name: "spec not observed", daemonSet: newDaemonSet(func(d *appsv1.DaemonSet) {
+			d.Generation = 3
+		}), status: http.StatusPermanentRedirect, reasons: "Waiting for daemon set spec update to be observed"},
+		{name: "pods not updated", daemonSet: newDaemonSet(func(d *appsv1.DaemonSet) {
+			d.Status.UpdatedNumberScheduled = 1
+		}), status: http.StatusPermanentRedirect,
+			reasons: "Waiting for daemon set agent rollout to finish: 1 out of 3 new pods have been updated"},
+		{name: "pods not available", daemonSet: newDaemonSet(func(d *appsv1.DaemonSet) {
+			d.Status.NumberAvailable = 2
+		}), status: http.StatusPermanentRedirect,
+			reasons: "Waiting for daemon set agent rollout to finish: 2 of 3 updated pods are available"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reasons, err := getDaemonSetStatus(tt.daemonSet)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if status != tt.status || !strings.HasPrefix(reasons, tt.reasons) {
+				t.Errorf("status = %d %q, want %d %q", status, reasons, tt.status, tt.reasons)
+			}
+		})
+	}
+}
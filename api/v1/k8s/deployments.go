@@ -17,12 +17,16 @@ import (
 )
 
 type DeploymentsQuery struct {
-	Namespace string `form:"namespace"`
-	Label     string `form:"label"`
+	k8s.ListParams
+	Namespace  string `form:"namespace"`
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
 }
 
 type DeploymentActionQuery struct {
 	Action string `form:"action" binding:"required"`
+	// the revision to roll back to, the previous one when empty
+	ToRevision int64 `form:"toRevision" binding:"omitempty,min=0"`
 }
 
 type DeploymentsUri struct {
@@ -39,9 +43,12 @@ type DeploymentUri struct {
 }
 
 type DeploymentBody struct {
+	// the image of the first container, use containers to pick others
 	Image    string `json:"image" form:"image"`
 	Label    string `json:"label" form:"label"`
 	Replicas string `json:"replicas" form:"replicas"`
+	// updates keyed by container name
+	Containers map[string]k8s.ContainerUpdate `json:"containers"`
 }
 
 var (
@@ -54,41 +61,48 @@ var (
 // @Param		cluster		path		string	true	"Cluster"
 // @Param		namespace	query		string	true	"Namespace"
 // @Param		label		query		string	false	"Label"
-// @Success	200			{object}	app.Response
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200			{object}	app.ResponseExtra
 // @Failure	500			{object}	app.Response
 // @Router		/k8s/deployments [get]
 func GetDeployments(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var (
-		q        DeploymentsQuery
-		listOpts metav1.ListOptions
-	)
+	var q DeploymentsQuery
 
 	if err := appG.C.ShouldBindQuery(&q); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	if q.Label == "" {
-		listOpts = metav1.ListOptions{}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	var deployments *appsv1.DeploymentList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.DeploymentsResource, q.Namespace, q.Consistent || !q.Cacheable()); ok {
+		deployments, err = cache.ListDeployments(q.Namespace, q.Label)
 	} else {
-		listOpts = metav1.ListOptions{LabelSelector: q.Label}
+		deployments, err = k8sClient.K8sClient.AppsV1().Deployments(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	deployments, err := k8sClient.K8sClient.AppsV1().Deployments(q.Namespace).List(context.TODO(), listOpts)
-	for i := 0; i < len(deployments.Items); i++ {
-		deployments.Items[i].CreationTimestamp = metav1.NewTime(deployments.Items[i].CreationTimestamp.Add(8 * time.Hour))
-	}
-
+	total, err := q.Paginate(deployments)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", deployments)
+	for i := 0; i < len(deployments.Items); i++ {
+		deployments.Items[i].CreationTimestamp = metav1.NewTime(deployments.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", deployments)
 }
 
 // @Summary	查看deployment
@@ -103,28 +117,38 @@ func GetDeployments(c *gin.Context) {
 func GetDeployment(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var u DeploymentUri
+	var (
+		u DeploymentUri
+		q k8s.ExportParams
+	)
 
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
 	deployment, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
-	deployment.TypeMeta.APIVersion = AppV1APIVersion
-	deployment.TypeMeta.Kind = DeploymentKind
-	deployment.CreationTimestamp = metav1.NewTime(deployment.CreationTimestamp.Add(8 * time.Hour))
-
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
+	if q.Exporting() {
+		exportObject(&appG, &q, deployment)
+		return
+	}
+	deployment.TypeMeta.APIVersion = AppV1APIVersion
+	deployment.TypeMeta.Kind = DeploymentKind
+	deployment.CreationTimestamp = metav1.NewTime(deployment.CreationTimestamp.Add(8 * time.Hour))
 	appG.Success(http.StatusOK, "ok", deployment)
 }
 
@@ -145,7 +169,7 @@ func PostDeployment(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -159,6 +183,15 @@ func PostDeployment(c *gin.Context) {
 	appG.Success(http.StatusOK, "ok", result)
 }
 
+// @Summary	对deployment执行操作
+// @Produce	json
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		deploymentName	path		string	true	"DeploymentName"
+// @Param		action			query		string	true	"redeploy, pause, resume, history or rollback"
+// @Param		toRevision		query		int		false	"Revision to roll back to, the previous one when empty"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/deployments/{namespace}/{deploymentName} [post]
 func DeploymentDoAction(c *gin.Context) {
 	appG := app.Gin{C: c}
 
@@ -177,7 +210,7 @@ func DeploymentDoAction(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -225,8 +258,34 @@ func DeploymentDoAction(c *gin.Context) {
 			appG.Fail(http.StatusInternalServerError, err, nil)
 			return
 		}
+	case "history":
+		revisions, err := k8s.NewDeploymentOperation(k8sClient.K8sClient).History(context.TODO(), u.Namespace, u.DeploymentName)
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		appG.Success(http.StatusOK, "ok", revisions)
+		return
+	case "rollback":
+		deployment, err := k8s.NewDeploymentOperation(k8sClient.K8sClient).Rollback(context.TODO(), u.Namespace, u.DeploymentName, q.ToRevision)
+		if errors.Is(err, k8s.ErrRollbackSkipped) {
+			appG.Success(http.StatusOK, err.Error(), nil)
+			return
+		}
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		// the progress of the rollout it started, follow it with GetDeploymentStatus
+		_, reasons, err := getDeploymentStatus(deployment)
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		appG.Success(http.StatusOK, reasons, deployment)
+		return
 	default:
-		appG.Fail(http.StatusBadRequest, errors.New("Invalid parameter, must be redeploy|pause|resume"), nil)
+		appG.Fail(http.StatusBadRequest, errors.New("Invalid parameter, must be redeploy|pause|resume|history|rollback"), nil)
 		return
 	}
 
@@ -239,7 +298,10 @@ func DeploymentDoAction(c *gin.Context) {
 // @Param		namespace		path		string				true	"Namespace"
 // @Param		deploymentName	path		string				true	"DeploymentName"
 // @Param		RequestBody		body		v1.DeploymentBody	true	"RequestBody"
-// @Success	200				{object}	app.Response
+// @Success	200				{object}	app.Response{data=[]k8s.UpdateResult}
+// @Success	207				{object}	app.Response{data=[]k8s.UpdateResult}
+// @Failure	400				{object}	app.Response
+// @Failure	404				{object}	app.Response
 // @Failure	500				{object}	app.Response
 // @Router		/k8s/deployments/{namespace}/{deploymentName} [put]
 func PutDeployment(c *gin.Context) {
@@ -257,16 +319,17 @@ func PutDeployment(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
+	operation := k8s.NewDeploymentOperation(k8sClient.K8sClient)
 	if b.Label == "" {
 		deployment, derr := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
 		if derr != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
+			appG.Fail(apiStatusCode(derr), derr, nil)
 			return
 		}
 
@@ -274,58 +337,64 @@ func PutDeployment(c *gin.Context) {
 		if b.Replicas != "" {
 			replicas, rerr := strconv.ParseInt(b.Replicas, 10, 32)
 			if rerr != nil {
-				appG.Fail(http.StatusInternalServerError, err, nil)
+				appG.Fail(http.StatusBadRequest, rerr, nil)
 				return
 			}
 			r := int32(replicas)
 			sc, derr := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).GetScale(context.TODO(), u.DeploymentName, metav1.GetOptions{})
 			if derr != nil {
-				appG.Fail(http.StatusInternalServerError, err, nil)
+				appG.Fail(apiStatusCode(derr), derr, nil)
 				return
 			}
 			sc.Spec.Replicas = r
 			_, err = k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).UpdateScale(context.TODO(), u.DeploymentName, sc, metav1.UpdateOptions{})
 			if err != nil {
-				appG.Fail(http.StatusInternalServerError, err, nil)
+				appG.Fail(apiStatusCode(err), err, nil)
 				return
 			}
 			appG.Success(http.StatusOK, "deployment replicas update to "+b.Replicas, nil)
 			return
 		}
 
-		// update image
-		if b.Image != "" {
-			deployment.Spec.Template.Spec.Containers[0].Image = b.Image
-		}
-
-		// force update
-		ForceUpdate(deployment)
-
-		_, err = k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Update(context.TODO(), deployment, metav1.UpdateOptions{})
-		if err != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
-			return
-		}
-	} else {
-		listOpts = metav1.ListOptions{LabelSelector: b.Label}
-		deployments, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).List(context.TODO(), listOpts)
-		if err != nil {
-			appG.Fail(http.StatusInternalServerError, err, nil)
+		if _, err := patchDeploymentContainers(operation, deployment, &b); err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
 			return
 		}
-		for _, deployment := range deployments.Items {
-			deployment.Spec.Template.Spec.Containers[0].Image = b.Image
-			// force update
-			ForceUpdate(&deployment)
-			_, err = k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Update(context.TODO(), &deployment, metav1.UpdateOptions{})
-			if err != nil {
-				appG.Fail(http.StatusInternalServerError, err, nil)
-				return
-			}
+		appG.Success(http.StatusOK, "ok", nil)
+		return
+	}
+
+	listOpts = metav1.ListOptions{LabelSelector: b.Label}
+	deployments, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).List(context.TODO(), listOpts)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	results := make([]k8s.UpdateResult, 0, len(deployments.Items))
+	failed := 0
+	for i := range deployments.Items {
+		result := k8s.UpdateResult{Name: deployments.Items[i].Name}
+		if _, err := patchDeploymentContainers(operation, &deployments.Items[i], &b); err != nil {
+			result.Error = err.Error()
+			failed++
 		}
+		results = append(results, result)
+	}
+	if failed > 0 {
+		appG.Success(http.StatusMultiStatus, fmt.Sprintf("%d of %d deployments failed", failed, len(results)), results)
+		return
 	}
+	appG.Success(http.StatusOK, "ok", results)
+}
 
-	appG.Success(http.StatusOK, "ok", nil)
+// patchDeploymentContainers applies the container updates of b to deployment and restarts its pods.
+func patchDeploymentContainers(operation k8s.DeploymentInterface, deployment *appsv1.Deployment, b *DeploymentBody) (*appsv1.Deployment, error) {
+	containers, err := k8s.ResolveContainers(&deployment.Spec.Template.Spec, b.Image, b.Containers)
+	if err != nil {
+		return nil, err
+	}
+	annotations := map[string]string{"Deployment.UpdateTimestamp": strconv.FormatInt(time.Now().Unix(), 10)}
+	return operation.PatchContainers(context.TODO(), deployment, containers, annotations)
 }
 
 // PatchDeployment
@@ -351,7 +420,7 @@ func PatchDeployment(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -376,7 +445,7 @@ func DeleteDeployment(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -409,7 +478,7 @@ func GetDeploymentStatus(c *gin.Context) {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -509,7 +578,7 @@ func GetDeploymentPods(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -532,13 +601,3 @@ func GetDeploymentPods(c *gin.Context) {
 	}
 	appG.Success(http.StatusOK, "ok", pods)
 }
-
-func ForceUpdate(deployment *appsv1.Deployment) {
-	if deployment.Spec.Template.Annotations == nil {
-		annotations := make(map[string]string)
-		annotations["Deployment.UpdateTimestamp"] = strconv.FormatInt(time.Now().Unix(), 10)
-		deployment.Spec.Template.Annotations = annotations
-	} else {
-		deployment.Spec.Template.Annotations["Deployment.UpdateTimestamp"] = strconv.FormatInt(time.Now().Unix(), 10)
-	}
-}
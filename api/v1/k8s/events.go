@@ -13,6 +13,7 @@ import (
 )
 
 type EventsQuery struct {
+	k8s.ListParams
 	Namespace string `json:"namespace" form:"namespace" binding:"required"`
 	Name      string `json:"name" form:"name" binding:"required"`
 	Kind      string `json:"kind" form:"kind" binding:"required"`
@@ -21,34 +22,41 @@ type EventsQuery struct {
 
 func GetEvents(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var (
-		q        EventsQuery
-		listOpts metav1.ListOptions
-	)
+	var q EventsQuery
 	if err := appG.C.ShouldBindQuery(&q); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	listOpts.FieldSelector = fmt.Sprintf(
+	involvedObject := fmt.Sprintf(
 		"involvedObject.name=%s,involvedObject.namespace=%s,involvedObject.kind=%s,involvedObject.uid=%s",
 		q.Name, q.Namespace, q.Kind, q.Uid,
 	)
+	if q.FieldSelector == "" {
+		q.FieldSelector = involvedObject
+	} else {
+		q.FieldSelector = involvedObject + "," + q.FieldSelector
+	}
+	listOpts := q.ListOptions("")
 	listOpts.TypeMeta = metav1.TypeMeta{Kind: q.Kind}
 	events, err := k8sClient.K8sClient.CoreV1().Events(q.Namespace).List(context.TODO(), listOpts)
-	for i := 0; i < len(events.Items); i++ {
-		events.Items[i].CreationTimestamp = metav1.NewTime(events.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
 	}
-
+	total, err := q.Paginate(events)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
+	for i := 0; i < len(events.Items); i++ {
+		events.Items[i].CreationTimestamp = metav1.NewTime(events.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
 
-	appG.Success(http.StatusOK, "ok", events)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", events)
 }
@@ -0,0 +1,90 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+type ExportNamespaceUri struct {
+	Namespace string `uri:"namespace" binding:"required"`
+}
+
+type ExportNamespaceQuery struct {
+	k8s.ArchiveParams
+	Label string `form:"label"`
+}
+
+// exportObject answers a get request in export mode, with the bare manifest when a format is asked for.
+func exportObject(appG *app.Gin, p *k8s.ExportParams, obj runtime.Object) {
+	exported, err := p.Export(obj)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if p.Format == "" {
+		appG.Success(http.StatusOK, "ok", exported)
+		return
+	}
+	data, err := p.Encode(exported)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.SuccessFile(http.StatusOK, accessor.GetName()+p.Ext(), p.ContentType(), data)
+}
+
+// @Summary	导出某个namespace下的资源
+// @Tags		Resources
+// @Produce	application/gzip
+// @Produce	application/zip
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		archive		query		string	false	"tar (gzipped, default) or zip"
+// @Param		format		query		string	false	"yaml (default) or json"
+// @Param		clean		query		bool	false	"Strip what the cluster filled in"
+// @Param		label		query		string	false	"Label"
+// @Success	200
+// @Failure	500			{object}	app.Response
+// @Router		/export/namespaces/{namespace} [get]
+func ExportNamespace(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u ExportNamespaceUri
+		q ExportNamespaceQuery
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	objs, err := k8sClient.ExportNamespace(context.TODO(), u.Namespace, q.Label, &q.ExportParams)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	data, ext, contentType, err := q.Pack(objs)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.SuccessFile(http.StatusOK, u.Namespace+ext, contentType, data)
+}
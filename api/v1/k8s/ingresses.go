@@ -0,0 +1,200 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type IngressesQuery struct {
+	k8s.ListParams
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+type IngressUri struct {
+	Namespace   string `uri:"namespace" binding:"required"`
+	IngressName string `uri:"ingressName" binding:"required"`
+}
+
+// @Summary	查看ingress列表
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		query		string	false	"Namespace"
+// @Param		label			query		string	false	"Label"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200				{object}	app.ResponseExtra
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/ingresses [get]
+func GetIngresses(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q IngressesQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewIngressOperation(k8sClient.K8sClient)
+	ingresses, err := operation.List(context.TODO(), q.Namespace, q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(ingresses)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation.AnnotateBackendHealth(context.TODO(), ingresses.Items)
+	for i := 0; i < len(ingresses.Items); i++ {
+		ingresses.Items[i].CreationTimestamp = metav1.NewTime(ingresses.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", ingresses)
+}
+
+// @Summary	查看ingress
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		ingressName	path		string	true	"IngressName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/ingresses/{namespace}/{ingressName} [get]
+func GetIngress(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u IngressUri
+		q k8s.ExportParams
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewIngressOperation(k8sClient.K8sClient)
+	ingress, err := operation.Get(context.TODO(), u.Namespace, u.IngressName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, ingress)
+		return
+	}
+	ingresses := []networkingv1.Ingress{*ingress}
+	operation.AnnotateBackendHealth(context.TODO(), ingresses)
+	ingress = &ingresses[0]
+	ingress.CreationTimestamp = metav1.NewTime(ingress.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", ingress)
+}
+
+// @Summary	创建ingress
+// @accept		application/json
+// @Param		cluster	path		string	true	"Cluster"
+// @Success	200		{object}	app.Response
+// @Failure	500		{object}	app.Response
+// @Router		/k8s/ingresses [post]
+func PostIngress(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var ingress networkingv1.Ingress
+	if err := appG.C.ShouldBindJSON(&ingress); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewIngressOperation(k8sClient.K8sClient).Create(context.TODO(), &ingress)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	更新ingress
+// @accept		application/json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		ingressName	path		string	true	"IngressName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/ingresses/{namespace}/{ingressName} [put]
+func PutIngress(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u       IngressUri
+		ingress networkingv1.Ingress
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&ingress); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewIngressOperation(k8sClient.K8sClient).Update(context.TODO(), u.Namespace, u.IngressName, &ingress)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	删除ingress
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		ingressName	path		string	true	"IngressName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/ingresses/{namespace}/{ingressName} [delete]
+func DeleteIngress(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u IngressUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewIngressOperation(k8sClient.K8sClient).Delete(context.TODO(), u.Namespace, u.IngressName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
@@ -0,0 +1,246 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+)
+
+type JobLogQuery struct {
+	// all of them when empty
+	Container  string `form:"container"`
+	Follow     bool   `form:"follow"`
+	Timestamps bool   `form:"timestamps"`
+	TailLines  *int64 `form:"tailLines" binding:"omitempty,min=0"`
+}
+
+// @Summary	通过websocket查看job所有pod的日志
+// @Produce	plain
+// @Param		namespace	path	string	true	"Namespace"
+// @Param		jobName		path	string	true	"JobName"
+// @Param		container	query	string	false	"Container, all of them when empty"
+// @Param		follow		query	bool	false	"Follow"
+// @Param		timestamps	query	bool	false	"Timestamps"
+// @Param		tailLines	query	int		false	"TailLines"
+// @Success	101			{string}	string	"[pod/container] line"
+// @Failure	404			{object}	app.Response
+// @Router		/k8s/jobs/{namespace}/{jobName}/logs [get]
+func GetJobLogs(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u JobUri
+		q JobLogQuery
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	job, err := k8sClient.K8sClient.BatchV1().Jobs(u.Namespace).Get(context.TODO(), u.JobName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	pods, err := k8s.NewJobOperation(k8sClient.K8sClient).Pods(context.TODO(), job)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	var podWatch watch.Interface
+	if q.Follow && job.Spec.Selector != nil {
+		selector := metav1.FormatLabelSelector(job.Spec.Selector)
+		podWatch, err = retryWatch(pods.ResourceVersion, func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = selector
+			return k8sClient.K8sClient.CoreV1().Pods(job.Namespace).Watch(context.TODO(), opts)
+		})
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		defer podWatch.Stop()
+	}
+
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		klog.Errorf("Upgrade error: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	// The goroutine listens to the websocket. When the client goes away,
+	// it cancels the context to stop the streams.
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	logs := &jobLogs{
+		client:    k8sClient.K8sClient,
+		namespace: job.Namespace,
+		ws:        ws,
+		query:     &q,
+		done:      make(chan struct{}),
+		seen:      make(map[string]bool),
+	}
+	defer func() {
+		cancel()
+		logs.wg.Wait()
+	}()
+	for i := range pods.Items {
+		logs.follow(ctx, &pods.Items[i])
+	}
+
+	var events <-chan watch.Event
+	if podWatch != nil {
+		events = podWatch.ResultChan()
+	}
+	for {
+		if logs.streams == 0 {
+			if podWatch == nil {
+				return
+			}
+			if finished, err := jobFinished(ctx, k8sClient.K8sClient, job); err != nil || finished {
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-logs.done:
+			logs.streams--
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if pod, ok := event.Object.(*corev1.Pod); ok && event.Type != watch.Deleted {
+				logs.follow(ctx, pod)
+			}
+		}
+	}
+}
+
+// jobLogs streams the logs of the containers of the pods of a Job to a websocket, a line per message
+// prefixed with [pod/container].
+type jobLogs struct {
+	client    kubernetes.Interface
+	namespace string
+	ws        *websocket.Conn
+	query     *JobLogQuery
+
+	// writes of the streams to the websocket
+	writeLock sync.Mutex
+	wg        sync.WaitGroup
+	// receives when a stream ends
+	done chan struct{}
+	// the streams that didn't end
+	streams int
+	// the pod/container streamed so far
+	seen map[string]bool
+}
+
+// follow starts streaming the containers of pod that started, unless they are already.
+func (l *jobLogs) follow(ctx context.Context, pod *corev1.Pod) {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if l.query.Container != "" && status.Name != l.query.Container {
+			continue
+		}
+		if status.State.Running == nil && status.State.Terminated == nil {
+			continue
+		}
+		key := pod.Name + "/" + status.Name
+		if l.seen[key] {
+			continue
+		}
+		l.seen[key] = true
+		l.streams++
+		l.wg.Add(1)
+		go l.stream(ctx, pod.Name, status.Name, key)
+	}
+}
+
+func (l *jobLogs) stream(ctx context.Context, pod, container, prefix string) {
+	defer func() {
+		l.wg.Done()
+		select {
+		case l.done <- struct{}{}:
+		case <-ctx.Done():
+		}
+	}()
+
+	opts := &corev1.PodLogOptions{
+		Container:  container,
+		Follow:     l.query.Follow,
+		Timestamps: l.query.Timestamps,
+		TailLines:  l.query.TailLines,
+	}
+	readCloser, err := l.client.CoreV1().Pods(l.namespace).GetLogs(pod, opts).Stream(ctx)
+	if err != nil {
+		l.write("[" + prefix + "] " + err.Error())
+		return
+	}
+	defer readCloser.Close()
+
+	scanner := bufio.NewScanner(readCloser)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if !l.write("[" + prefix + "] " + scanner.Text()) {
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		l.write("[" + prefix + "] " + err.Error())
+	}
+}
+
+// write sends a line and reports whether the websocket is still open.
+func (l *jobLogs) write(line string) bool {
+	l.writeLock.Lock()
+	defer l.writeLock.Unlock()
+	if err := l.ws.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+		klog.Errorf("WriteMessage error: %v", err)
+		return false
+	}
+	return true
+}
+
+// jobFinished reports whether job completed or failed.
+func jobFinished(ctx context.Context, client kubernetes.Interface, job *batchv1.Job) (bool, error) {
+	current, err := client.BatchV1().Jobs(job.Namespace).Get(ctx, job.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, condition := range current.Status.Conditions {
+		if (condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed) && condition.Status == corev1.ConditionTrue {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -2,17 +2,28 @@ package v1
 
 import (
 	"context"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type JobsQuery struct {
-	Namespace string `form:"namespace"`
-	Label     string `form:"label"`
+	k8s.ListParams
+	Namespace  string `form:"namespace"`
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
+	// only the Jobs of this CronJob, with the status of their pods
+	CronJob string `form:"cronjob"`
+}
+
+// OwnedJobList is the answer of GetJobs for the Jobs of a CronJob.
+type OwnedJobList struct {
+	Items []k8s.JobWithPods `json:"items"`
 }
 
 type JobUri struct {
@@ -23,45 +34,86 @@ type JobUri struct {
 func GetJobs(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var (
-		q        JobsQuery
-		listOpts metav1.ListOptions
-	)
+	var q JobsQuery
 
 	if err := appG.C.ShouldBindQuery(&q); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	if q.Label == "" {
-		listOpts = metav1.ListOptions{}
+	var owner *batchv1.CronJob
+	if q.CronJob != "" {
+		if q.Namespace == "" {
+			appG.Fail(http.StatusBadRequest, errors.New("the jobs of a cronjob need its namespace"), nil)
+			return
+		}
+		operation, err := k8s.NewCronJobOperation(k8sClient)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		if owner, err = operation.Get(context.TODO(), q.Namespace, q.CronJob); err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+	}
+
+	var jobs *batchv1.JobList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.JobsResource, q.Namespace, q.Consistent || !q.Cacheable()); ok {
+		jobs, err = cache.ListJobs(q.Namespace, q.Label)
+	} else if owner != nil {
+		// filtered here, the apiserver can't cut the pages
+		jobs, err = k8sClient.K8sClient.BatchV1().Jobs(q.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: q.Label, FieldSelector: q.FieldSelector})
 	} else {
-		listOpts = metav1.ListOptions{LabelSelector: q.Label}
+		jobs, err = k8sClient.K8sClient.BatchV1().Jobs(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
+	}
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if owner != nil {
+		k8s.OwnedBy(jobs, owner.UID)
 	}
-	jobs, err := k8sClient.K8sClient.BatchV1().Jobs(q.Namespace).List(context.TODO(), listOpts)
+	total, err := q.Paginate(jobs)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", jobs)
+	if owner != nil {
+		items, err := k8s.NewJobOperation(k8sClient.K8sClient).WithPods(context.TODO(), jobs.Items)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", &OwnedJobList{Items: items})
+		return
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", jobs)
 }
 
 func GetJob(c *gin.Context) {
 	appG := app.Gin{C: c}
 
-	var u JobUri
+	var (
+		u JobUri
+		q k8s.ExportParams
+	)
 
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -73,6 +125,10 @@ func GetJob(c *gin.Context) {
 		return
 	}
 
+	if q.Exporting() {
+		exportObject(&appG, &q, cronjob)
+		return
+	}
 	appG.Success(http.StatusOK, "ok", cronjob)
 }
 
@@ -86,7 +142,7 @@ func DeleteJob(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -100,3 +156,66 @@ func DeleteJob(c *gin.Context) {
 
 	appG.Success(http.StatusOK, "ok", nil)
 }
+
+// @Summary	创建job
+// @accept		application/json
+// @Produce	json
+// @Param		RequestBody	body		object	true	"Job"
+// @Success	201			{object}	app.Response
+// @Failure	400			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/jobs [post]
+func PostJob(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var b batchv1.Job
+	if err := appG.C.ShouldBindJSON(&b); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if b.Namespace == "" {
+		appG.Fail(http.StatusBadRequest, errors.New("the job needs a namespace"), nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	job, err := k8s.NewJobOperation(k8sClient.K8sClient).Create(context.TODO(), &b)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusCreated, "ok", job)
+}
+
+// @Summary	重新运行job
+// @Produce	json
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		jobName		path		string	true	"JobName"
+// @Success	201			{object}	app.Response
+// @Failure	404			{object}	app.Response
+// @Router		/k8s/jobs/{namespace}/{jobName}/rerun [post]
+func RerunJob(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u JobUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	job, err := k8s.NewJobOperation(k8sClient.K8sClient).Rerun(context.TODO(), u.Namespace, u.JobName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusCreated, "ok", job)
+}
@@ -7,22 +7,36 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+type NamespacesQuery struct {
+	k8s.ListParams
+	Label string `form:"label"`
+}
+
 func GetNamespaces(c *gin.Context) {
 	appG := app.Gin{C: c}
+	var q NamespacesQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
-	namespaces, err := k8sClient.K8sClient.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	namespaces, err := k8sClient.K8sClient.CoreV1().Namespaces().List(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(namespaces)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", namespaces)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", namespaces)
 }
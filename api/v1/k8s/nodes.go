@@ -7,22 +7,36 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+type NodesQuery struct {
+	k8s.ListParams
+	Label string `form:"label"`
+}
+
 func GetNodes(c *gin.Context) {
 	appG := app.Gin{C: c}
+	var q NodesQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
-	deployments, err := k8sClient.K8sClient.CoreV1().Nodes().List(context.TODO(), metav1.ListOptions{})
+	deployments, err := k8sClient.K8sClient.CoreV1().Nodes().List(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(deployments)
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", deployments)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", deployments)
 }
@@ -25,8 +25,10 @@ import (
 )
 
 type PodsQuery struct {
-	Namespace string `form:"namespace"`
-	Label     string `form:"label"`
+	k8s.ListParams
+	Namespace  string `form:"namespace"`
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
 }
 
 type PodQuery struct{}
@@ -63,32 +65,44 @@ var upGrader = websocket.Upgrader{
 // @Produce	json
 // @Param		namespace	query		string	true	"Namespace"
 // @Param		label		query		string	false	"Label"
-// @Success	200			{object}	app.Response
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200			{object}	app.ResponseExtra
 // @Failure	500			{object}	app.Response
 // @Router		/k8s/pods [get]
 func GetPods(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var (
-		q        PodsQuery
-		listOpts metav1.ListOptions
-	)
+	var q PodsQuery
 	if err := appG.C.ShouldBindQuery(&q); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
-	if q.Label == "" {
-		listOpts = metav1.ListOptions{}
-	} else {
-		listOpts = metav1.ListOptions{LabelSelector: q.Label}
-	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
-	pods, err := k8sClient.K8sClient.CoreV1().Pods(q.Namespace).List(context.TODO(), listOpts)
+	var pods *corev1.PodList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.PodsResource, q.Namespace, q.Consistent || !q.Cacheable()); ok {
+		pods, err = cache.ListPods(q.Namespace, q.Label)
+	} else {
+		pods, err = k8sClient.K8sClient.CoreV1().Pods(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
+	}
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(pods)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
 	for i := 0; i < len(pods.Items); i++ {
 		pods.Items[i].CreationTimestamp = metav1.NewTime(pods.Items[i].CreationTimestamp.Add(8 * time.Hour))
 	}
@@ -111,11 +125,7 @@ func GetPods(c *gin.Context) {
 		ListMeta: pods.ListMeta,
 		Items:    newPodItems,
 	}
-	if err != nil {
-		appG.Fail(http.StatusInternalServerError, err, nil)
-		return
-	}
-	appG.Success(http.StatusOK, "ok", newPodList)
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", newPodList)
 }
 
 func WatchPods(c *gin.Context) {
@@ -151,7 +161,7 @@ func WatchPods(c *gin.Context) {
 		listOpts = metav1.ListOptions{LabelSelector: q.Label}
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		if gerr := appG.C.AbortWithError(http.StatusInternalServerError, err); gerr != nil {
 			klog.Errorf("AbortWithError: %v", gerr)
@@ -224,25 +234,35 @@ func WatchPods(c *gin.Context) {
 // @Router		/k8s/pods/{namespace}/{podName} [get]
 func GetPod(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var u PodUri
+	var (
+		u PodUri
+		q k8s.ExportParams
+	)
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
 
 	pod, err := k8sClient.K8sClient.CoreV1().Pods(u.Namespace).Get(context.TODO(), u.PodName, metav1.GetOptions{})
-	pod.CreationTimestamp = metav1.NewTime(pod.CreationTimestamp.Add(8 * time.Hour))
-
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
+	if q.Exporting() {
+		exportObject(&appG, &q, pod)
+		return
+	}
+	pod.CreationTimestamp = metav1.NewTime(pod.CreationTimestamp.Add(8 * time.Hour))
 	appG.Success(http.StatusOK, "ok", pod)
 }
 
@@ -254,7 +274,7 @@ func DeletePod(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
@@ -321,7 +341,7 @@ func GetPodLog(c *gin.Context) {
 		TailLines:  &tailLines,
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		if gerr := appG.C.AbortWithError(http.StatusInternalServerError, err); gerr != nil {
 			klog.Errorf("AbortWithError: %v", gerr)
@@ -391,7 +411,7 @@ func DownloadPodContainerLog(c *gin.Context) {
 	if err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 	}
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		if gerr := appG.C.AbortWithError(http.StatusInternalServerError, err); gerr != nil {
 			klog.Errorf("AbortWithError: %v", gerr)
@@ -445,7 +465,7 @@ func PodWebSSH(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		if gerr := appG.C.AbortWithError(http.StatusInternalServerError, err); gerr != nil {
 			klog.Errorf("AbortWithError: %v", gerr)
@@ -0,0 +1,338 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// CoreGroup stands for the empty group of the core resources, such as pods, in resource paths.
+const CoreGroup = "core"
+
+type ResourceUri struct {
+	Group     string `uri:"group" binding:"required"`
+	Version   string `uri:"version" binding:"required"`
+	Resource  string `uri:"resource" binding:"required"`
+	Namespace string `uri:"namespace"`
+	Name      string `uri:"name"`
+}
+
+type ResourcesQuery struct {
+	k8s.ListParams
+	Label string `form:"label"`
+}
+
+// resourceRequest is a resource path resolved through discovery.
+type resourceRequest struct {
+	clients    *k8s.ClientManager
+	gvr        schema.GroupVersionResource
+	gvk        schema.GroupVersionKind
+	namespaced bool
+	namespace  string
+	name       string
+}
+
+// resolveResource binds and resolves the resource path of c, it fails the request when it can't. The
+// namespace segment of a cluster scoped resource is its name.
+func resolveResource(appG *app.Gin) (*resourceRequest, bool) {
+	var u ResourceUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return nil, false
+	}
+	k8sClient, err := k8s.GetClientForRequest(appG.C.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return nil, false
+	}
+
+	r := &resourceRequest{
+		clients:   k8sClient,
+		gvr:       schema.GroupVersionResource{Group: u.Group, Version: u.Version, Resource: u.Resource},
+		namespace: u.Namespace,
+		name:      u.Name,
+	}
+	if r.gvr.Group == CoreGroup {
+		r.gvr.Group = ""
+	}
+	r.gvk, r.namespaced, err = k8sClient.ResolveResource(r.gvr)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return nil, false
+	}
+	if !r.namespaced {
+		if r.name != "" {
+			appG.Fail(http.StatusNotFound, errors.New(r.gvr.String()+" is cluster scoped"), nil)
+			return nil, false
+		}
+		r.name, r.namespace = r.namespace, ""
+	}
+	return r, true
+}
+
+// apiStatusCode returns the http status of an apiserver error, unknown resources are not found and
+// anything else is an internal error.
+func apiStatusCode(err error) int {
+	var status apierrors.APIStatus
+	if errors.As(err, &status) && status.Status().Code != 0 {
+		return int(status.Status().Code)
+	}
+	if meta.IsNoMatchError(err) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// @Summary	查看任意资源的列表或某个资源
+// @Tags		Resources
+// @Produce	json
+// @Param		group		path		string	true	"Group, core for the core group"
+// @Param		version		path		string	true	"Version"
+// @Param		resource	path		string	true	"Resource"
+// @Param		namespace	path		string	false	"Namespace, or the name of a cluster scoped resource"
+// @Param		name		path		string	false	"Name"
+// @Param		label		query		string	false	"Label"
+// @Success	200			{object}	app.ResponseExtra
+// @Failure	404			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/resources/{group}/{version}/{resource}/{namespace}/{name} [get]
+func GetResource(c *gin.Context) {
+	appG := app.Gin{C: c}
+	r, ok := resolveResource(&appG)
+	if !ok {
+		return
+	}
+	crd := k8s.NewCRDOperation(r.clients.K8sDynamicClient)
+
+	if r.name != "" {
+		obj, err := crd.Get(context.TODO(), r.gvr, r.namespace, r.name)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		k8s.MaskSecretObject(obj)
+		var q k8s.ExportParams
+		if err := appG.C.ShouldBindQuery(&q); err != nil {
+			appG.Fail(http.StatusBadRequest, err, nil)
+			return
+		}
+		if q.Exporting() {
+			exportObject(&appG, &q, obj)
+			return
+		}
+		appG.Success(http.StatusOK, "ok", obj)
+		return
+	}
+
+	var q ResourcesQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	list, err := crd.List(context.TODO(), r.gvr, r.namespace, q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(list)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := range list.Items {
+		k8s.MaskSecretObject(&list.Items[i])
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", list)
+}
+
+// @Summary	创建任意资源
+// @Tags		Resources
+// @accept		application/json
+// @Produce	json
+// @Param		group		path		string	true	"Group, core for the core group"
+// @Param		version		path		string	true	"Version"
+// @Param		resource	path		string	true	"Resource"
+// @Param		namespace	path		string	false	"Namespace, metadata.namespace when empty"
+// @Param		RequestBody	body		object	true	"Object"
+// @Success	201			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/resources/{group}/{version}/{resource}/{namespace} [post]
+func PostResource(c *gin.Context) {
+	appG := app.Gin{C: c}
+	r, ok := resolveResource(&appG)
+	if !ok {
+		return
+	}
+	if r.name != "" {
+		appG.Fail(http.StatusBadRequest, errors.New("the name of a new "+r.gvr.Resource+" goes in its metadata"), nil)
+		return
+	}
+	data, ok := bindObject(&appG, r)
+	if !ok {
+		return
+	}
+	metadata := data["metadata"].(map[string]interface{})
+	if r.namespace != "" {
+		metadata["namespace"] = r.namespace
+	} else if !r.namespaced {
+		delete(metadata, "namespace")
+	}
+
+	obj, err := k8s.NewCRDOperation(r.clients.K8sDynamicClient).Create(context.TODO(), r.gvr, data)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	k8s.MaskSecretObject(obj)
+	appG.Success(http.StatusCreated, "ok", obj)
+}
+
+// @Summary	更新任意资源
+// @Tags		Resources
+// @accept		application/json
+// @Produce	json
+// @Param		group		path		string	true	"Group, core for the core group"
+// @Param		version		path		string	true	"Version"
+// @Param		resource	path		string	true	"Resource"
+// @Param		namespace	path		string	true	"Namespace, or the name of a cluster scoped resource"
+// @Param		name		path		string	false	"Name"
+// @Param		RequestBody	body		object	true	"Object"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/resources/{group}/{version}/{resource}/{namespace}/{name} [put]
+func PutResource(c *gin.Context) {
+	appG := app.Gin{C: c}
+	r, ok := resolveResource(&appG)
+	if !ok || !requireName(&appG, r) {
+		return
+	}
+	data, ok := bindObject(&appG, r)
+	if !ok {
+		return
+	}
+
+	obj, err := k8s.NewCRDOperation(r.clients.K8sDynamicClient).Update(context.TODO(), r.gvr, r.namespace, r.name, data)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	k8s.MaskSecretObject(obj)
+	appG.Success(http.StatusOK, "ok", obj)
+}
+
+// patchTypes are the patches accepted by PatchResource, keyed by content type.
+var patchTypes = map[string]types.PatchType{
+	"application/json":                       types.MergePatchType,
+	"application/merge-patch+json":           types.MergePatchType,
+	"application/json-patch+json":            types.JSONPatchType,
+	"application/strategic-merge-patch+json": types.StrategicMergePatchType,
+}
+
+// @Summary	修改任意资源
+// @Tags		Resources
+// @accept		application/merge-patch+json
+// @accept		application/json-patch+json
+// @accept		application/strategic-merge-patch+json
+// @Produce	json
+// @Param		group		path		string	true	"Group, core for the core group"
+// @Param		version		path		string	true	"Version"
+// @Param		resource	path		string	true	"Resource"
+// @Param		namespace	path		string	true	"Namespace, or the name of a cluster scoped resource"
+// @Param		name		path		string	false	"Name"
+// @Param		RequestBody	body		object	true	"Patch"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/resources/{group}/{version}/{resource}/{namespace}/{name} [patch]
+func PatchResource(c *gin.Context) {
+	appG := app.Gin{C: c}
+	r, ok := resolveResource(&appG)
+	if !ok || !requireName(&appG, r) {
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	pt, ok := patchTypes[contentType]
+	if !ok {
+		appG.Fail(http.StatusUnsupportedMediaType, errors.New("unsupported patch type "+contentType), nil)
+		return
+	}
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	obj, err := k8s.NewCRDOperation(r.clients.K8sDynamicClient).Patch(context.TODO(), r.gvr, r.namespace, r.name, pt, patch)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	k8s.MaskSecretObject(obj)
+	appG.Success(http.StatusOK, "ok", obj)
+}
+
+// @Summary	删除任意资源
+// @Tags		Resources
+// @Produce	json
+// @Param		group		path		string	true	"Group, core for the core group"
+// @Param		version		path		string	true	"Version"
+// @Param		resource	path		string	true	"Resource"
+// @Param		namespace	path		string	true	"Namespace, or the name of a cluster scoped resource"
+// @Param		name		path		string	false	"Name"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/resources/{group}/{version}/{resource}/{namespace}/{name} [delete]
+func DeleteResource(c *gin.Context) {
+	appG := app.Gin{C: c}
+	r, ok := resolveResource(&appG)
+	if !ok || !requireName(&appG, r) {
+		return
+	}
+
+	err := k8s.NewCRDOperation(r.clients.K8sDynamicClient).Delete(context.TODO(), r.gvr, r.namespace, r.name)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", r.name)
+}
+
+func requireName(appG *app.Gin, r *resourceRequest) bool {
+	if r.name == "" {
+		appG.Fail(http.StatusBadRequest, errors.New("no "+r.gvr.Resource+" name in the path"), nil)
+		return false
+	}
+	return true
+}
+
+// bindObject binds the object in the body, filling in its apiVersion and kind when they are missing.
+func bindObject(appG *app.Gin, r *resourceRequest) (map[string]interface{}, bool) {
+	var data map[string]interface{}
+	if err := appG.C.ShouldBindJSON(&data); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return nil, false
+	}
+	if _, ok := data["metadata"].(map[string]interface{}); !ok {
+		appG.Fail(http.StatusBadRequest, errors.New("the object has no metadata"), nil)
+		return nil, false
+	}
+	if _, ok := data["apiVersion"]; !ok {
+		data["apiVersion"] = r.gvk.GroupVersion().String()
+	}
+	if _, ok := data["kind"]; !ok {
+		data["kind"] = r.gvk.Kind
+	}
+	return data, true
+}
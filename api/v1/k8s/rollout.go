@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	watchtools "k8s.io/client-go/tools/watch"
+	"k8s.io/klog/v2"
+)
+
+// DeploymentStatusResp is a message of WatchDeploymentStatus.
+type DeploymentStatusResp struct {
+	// "status" or "event"
+	Type string `json:"type"`
+	// of a status, like GetDeploymentStatus: 200 rolled out, 308 in progress, 500 failed
+	Code    int           `json:"code,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Event   *corev1.Event `json:"event,omitempty"`
+}
+
+// @Summary	通过websocket推送deployment的发布进度
+// @Produce	json
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		deploymentName	path		string	true	"DeploymentName"
+// @Success	101				{object}	DeploymentStatusResp
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/watch/deployment_status/{namespace}/{deploymentName} [get]
+func WatchDeploymentStatus(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u DeploymentUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	deployment, err := k8sClient.K8sClient.AppsV1().Deployments(u.Namespace).Get(context.TODO(), u.DeploymentName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	rollout, err := watchRollout(k8sClient.K8sClient, deployment)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	defer rollout.stop()
+
+	ws, err := upGrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		klog.Errorf("Upgrade error: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	// The goroutine listens to the websocket. When the client goes away,
+	// it cancels the context to stop the watches.
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	go func() {
+		for {
+			if _, _, err := ws.NextReader(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	var last DeploymentStatusResp
+	// pushStatus sends the status of d when it changed and reports whether the stream is over.
+	pushStatus := func(d *appsv1.Deployment) bool {
+		code, reasons, err := getDeploymentStatus(d)
+		if err != nil {
+			code, reasons = http.StatusInternalServerError, err.Error()
+		}
+		if code == last.Code && reasons == last.Message {
+			return false
+		}
+		last = DeploymentStatusResp{Type: "status", Code: code, Message: reasons}
+		if err := ws.WriteJSON(&last); err != nil {
+			klog.Errorf("WriteJson error: %v", err)
+			return true
+		}
+		return code != http.StatusPermanentRedirect
+	}
+
+	if pushStatus(deployment) {
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-rollout.deployment.ResultChan():
+			if !ok {
+				return
+			}
+			if event.Type == watch.Deleted {
+				if err := ws.WriteJSON(&DeploymentStatusResp{Type: "status", Code: http.StatusNotFound, Message: "deployment " + deployment.Name + " was deleted"}); err != nil {
+					klog.Errorf("WriteJson error: %v", err)
+				}
+				return
+			}
+			if d, ok := event.Object.(*appsv1.Deployment); ok && pushStatus(d) {
+				return
+			}
+		case event, ok := <-rollout.replicaSets.ResultChan():
+			if !ok {
+				return
+			}
+			if rs, ok := event.Object.(*appsv1.ReplicaSet); ok {
+				rollout.trackReplicaSet(rs)
+			}
+		case event, ok := <-rollout.pods.ResultChan():
+			if !ok {
+				return
+			}
+			if pod, ok := event.Object.(*corev1.Pod); ok {
+				rollout.trackPod(pod)
+			}
+		case event, ok := <-rollout.events.ResultChan():
+			if !ok {
+				return
+			}
+			if e, ok := event.Object.(*corev1.Event); ok && event.Type != watch.Deleted && rollout.concerns(e) {
+				if err := ws.WriteJSON(&DeploymentStatusResp{Type: "event", Message: e.Message, Event: e}); err != nil {
+					klog.Errorf("WriteJson error: %v", err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// rolloutWatch follows a deployment, its ReplicaSets and pods, and the events about them.
+type rolloutWatch struct {
+	deploymentName string
+	deploymentUID  types.UID
+
+	deployment  watch.Interface
+	replicaSets watch.Interface
+	pods        watch.Interface
+	events      watch.Interface
+
+	// names of the ReplicaSets and pods of the deployment
+	replicaSetNames map[string]bool
+	podNames        map[string]bool
+}
+
+func watchRollout(client kubernetes.Interface, deployment *appsv1.Deployment) (*rolloutWatch, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	namespace := deployment.Namespace
+	r := &rolloutWatch{
+		deploymentName:  deployment.Name,
+		deploymentUID:   deployment.UID,
+		replicaSetNames: make(map[string]bool),
+		podNames:        make(map[string]bool),
+	}
+
+	byName := fields.OneTermEqualSelector("metadata.name", deployment.Name).String()
+	r.deployment, err = retryWatch(deployment.ResourceVersion, func(opts metav1.ListOptions) (watch.Interface, error) {
+		opts.FieldSelector = byName
+		return client.AppsV1().Deployments(namespace).Watch(context.TODO(), opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	replicaSets, err := client.AppsV1().ReplicaSets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+	for i := range replicaSets.Items {
+		r.trackReplicaSet(&replicaSets.Items[i])
+	}
+	r.replicaSets, err = retryWatch(replicaSets.ResourceVersion, func(opts metav1.ListOptions) (watch.Interface, error) {
+		opts.LabelSelector = selector.String()
+		return client.AppsV1().ReplicaSets(namespace).Watch(context.TODO(), opts)
+	})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+
+	pods, err := client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+	for i := range pods.Items {
+		r.trackPod(&pods.Items[i])
+	}
+	r.pods, err = retryWatch(pods.ResourceVersion, func(opts metav1.ListOptions) (watch.Interface, error) {
+		opts.LabelSelector = selector.String()
+		return client.CoreV1().Pods(namespace).Watch(context.TODO(), opts)
+	})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+
+	// only the events from now on
+	events, err := client.CoreV1().Events(namespace).List(context.TODO(), metav1.ListOptions{Limit: 1})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+	r.events, err = retryWatch(events.ResourceVersion, func(opts metav1.ListOptions) (watch.Interface, error) {
+		return client.CoreV1().Events(namespace).Watch(context.TODO(), opts)
+	})
+	if err != nil {
+		r.stop()
+		return nil, err
+	}
+	return r, nil
+}
+
+func retryWatch(resourceVersion string, watchFunc func(metav1.ListOptions) (watch.Interface, error)) (watch.Interface, error) {
+	w, err := watchtools.NewRetryWatcher(resourceVersion, &cache.ListWatch{WatchFunc: watchFunc})
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (r *rolloutWatch) stop() {
+	for _, w := range []watch.Interface{r.deployment, r.replicaSets, r.pods, r.events} {
+		if w != nil {
+			w.Stop()
+		}
+	}
+}
+
+func (r *rolloutWatch) trackReplicaSet(rs *appsv1.ReplicaSet) {
+	if owner := metav1.GetControllerOf(rs); owner != nil && owner.UID == r.deploymentUID {
+		r.replicaSetNames[rs.Name] = true
+	}
+}
+
+func (r *rolloutWatch) trackPod(pod *corev1.Pod) {
+	if owner := metav1.GetControllerOf(pod); owner != nil && owner.Kind == "ReplicaSet" && r.replicaSetNames[owner.Name] {
+		r.podNames[pod.Name] = true
+	}
+}
+
+// concerns reports whether e is about the deployment, one of its ReplicaSets or pods.
+func (r *rolloutWatch) concerns(e *corev1.Event) bool {
+	switch e.InvolvedObject.Kind {
+	case "Deployment":
+		return e.InvolvedObject.Name == r.deploymentName
+	case "ReplicaSet":
+		return r.replicaSetNames[e.InvolvedObject.Name]
+	case "Pod":
+		return r.podNames[e.InvolvedObject.Name]
+	}
+	return false
+}
@@ -0,0 +1,291 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	"github.com/lmxia/nightwatcher/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type SecretsQuery struct {
+	k8s.ListParams
+	SecretQuery
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+type SecretQuery struct {
+	// encrypt the values with the AES key instead of masking them
+	Reveal bool `form:"reveal"`
+}
+
+type SecretUri struct {
+	Namespace  string `uri:"namespace" binding:"required"`
+	SecretName string `uri:"secretName" binding:"required"`
+}
+
+// @Summary	查看secret列表
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		query		string	false	"Namespace"
+// @Param		label			query		string	false	"Label"
+// @Param		reveal			query		bool	false	"AES encrypt the values instead of masking them"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200				{object}	app.ResponseExtra
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/secrets [get]
+func GetSecrets(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var q SecretsQuery
+
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	view, code, err := secretViewer(c.Request.Context(), q.Reveal)
+	if err != nil {
+		appG.Fail(code, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	secrets, err := k8s.NewSecretOperation(k8sClient.K8sClient).List(context.TODO(), q.Namespace, q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(secrets)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	views := &k8s.SecretViewList{ListMeta: secrets.ListMeta, Items: make([]k8s.SecretView, 0, len(secrets.Items))}
+	for i := range secrets.Items {
+		secret, err := view(&secrets.Items[i])
+		if err != nil {
+			appG.Fail(http.StatusInternalServerError, err, nil)
+			return
+		}
+		secret.CreationTimestamp = metav1.NewTime(secret.CreationTimestamp.Add(8 * time.Hour))
+		views.Items = append(views.Items, *secret)
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", views)
+}
+
+// @Summary	查看secret
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		secretName	path		string	true	"SecretName"
+// @Param		reveal		query		bool	false	"AES encrypt the values instead of masking them"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/secrets/{namespace}/{secretName} [get]
+func GetSecret(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u SecretUri
+		q SecretQuery
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	view, code, err := secretViewer(c.Request.Context(), q.Reveal)
+	if err != nil {
+		appG.Fail(code, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	secret, err := k8s.NewSecretOperation(k8sClient.K8sClient).Get(context.TODO(), u.Namespace, u.SecretName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	result, err := view(secret)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	result.CreationTimestamp = metav1.NewTime(result.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	创建secret
+// @accept		application/json
+// @Param		cluster		path		string			true	"Cluster"
+// @Param		RequestBody	body		k8s.SecretView	true	"Secret, data base64 encoded or AES-GCM encrypted"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/secrets [post]
+func PostSecret(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var body k8s.SecretView
+
+	if err := appG.C.ShouldBindJSON(&body); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	cipher, err := secretBodyCipher(&body)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	secret, err := k8s.SecretFrom(&body, nil, cipher)
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewSecretOperation(k8sClient.K8sClient).Create(context.TODO(), secret)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", k8s.MaskSecret(result))
+}
+
+// @Summary	更新secret
+// @accept		application/json
+// @Param		cluster		path		string			true	"Cluster"
+// @Param		namespace	path		string			true	"Namespace"
+// @Param		secretName	path		string			true	"SecretName"
+// @Param		RequestBody	body		k8s.SecretView	true	"Secret, data base64 encoded, AES-GCM encrypted, or masked to keep the value"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/secrets/{namespace}/{secretName} [put]
+func PutSecret(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u    SecretUri
+		body k8s.SecretView
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&body); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	cipher, err := secretBodyCipher(&body)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewSecretOperation(k8sClient.K8sClient)
+	current, err := operation.Get(context.TODO(), u.Namespace, u.SecretName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	secret, err := k8s.SecretFrom(&body, current, cipher)
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	secret.Namespace, secret.Name = u.Namespace, u.SecretName
+	if secret.ResourceVersion == "" {
+		secret.ResourceVersion = current.ResourceVersion
+	}
+	result, err := operation.Update(context.TODO(), secret)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", k8s.MaskSecret(result))
+}
+
+// @Summary	删除secret
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		secretName	path		string	true	"SecretName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/secrets/{namespace}/{secretName} [delete]
+func DeleteSecret(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u SecretUri
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewSecretOperation(k8sClient.K8sClient).Delete(context.TODO(), u.Namespace, u.SecretName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// secretViewer returns how the Secrets are served to the caller of ctx: masked, or encrypted when it
+// asks to reveal them. The apiserver already checked the caller may read them, so revealing is
+// refused to anonymous callers, when authentication is off.
+func secretViewer(ctx context.Context, reveal bool) (func(*corev1.Secret) (*k8s.SecretView, error), int, error) {
+	if !reveal {
+		return func(secret *corev1.Secret) (*k8s.SecretView, error) {
+			return k8s.MaskSecret(secret), nil
+		}, http.StatusOK, nil
+	}
+	if _, ok := utils.UserFrom(ctx); !ok {
+		return nil, http.StatusForbidden, errors.New("secrets are only revealed to authenticated callers")
+	}
+	cipher, err := k8s.NewSecretCipher()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return cipher.Encrypt, http.StatusOK, nil
+}
+
+// secretBodyCipher returns the cipher decrypting body, nil when its values are not encrypted.
+func secretBodyCipher(body *k8s.SecretView) (*k8s.SecretCipher, error) {
+	if !body.Encrypted {
+		return nil, nil
+	}
+	return k8s.NewSecretCipher()
+}
@@ -8,11 +8,15 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/lmxia/nightwatcher/app"
 	"github.com/lmxia/nightwatcher/controllers/k8s"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ServicesQuery struct {
-	Namespace string `form:"namespace"`
+	k8s.ListParams
+	Namespace  string `form:"namespace"`
+	Label      string `form:"label"`
+	Consistent bool   `form:"consistent"`
 }
 
 type ServiceQuery struct{}
@@ -30,42 +34,186 @@ func GetServices(c *gin.Context) {
 		return
 	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
 	}
 
-	services, err := k8sClient.K8sClient.CoreV1().Services(q.Namespace).List(context.TODO(), metav1.ListOptions{})
-	for i := 0; i < len(services.Items); i++ {
-		services.Items[i].CreationTimestamp = metav1.NewTime(services.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	var services *corev1.ServiceList
+	if cache, ok := k8s.UseReadCache(c.Request.Context(), k8s.ServicesResource, q.Namespace, q.Consistent || !q.Cacheable()); ok {
+		services, err = cache.ListServices(q.Namespace, q.Label)
+	} else {
+		services, err = k8sClient.K8sClient.CoreV1().Services(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
 	}
-
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", services)
+	total, err := q.Paginate(services)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(services.Items); i++ {
+		services.Items[i].CreationTimestamp = metav1.NewTime(services.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", services)
 }
 
 func GetService(c *gin.Context) {
 	appG := app.Gin{C: c}
-	var u ServiceUri
+	var (
+		u ServiceUri
+		q k8s.ExportParams
+	)
 	if err := appG.C.ShouldBindUri(&u); err != nil {
 		appG.Fail(http.StatusBadRequest, err, nil)
 		return
 	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
 
-	k8sClient, err := k8s.GetClientWithPanic()
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
 	}
 
 	service, err := k8sClient.K8sClient.CoreV1().Services(u.Namespace).Get(context.TODO(), u.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, service)
+		return
+	}
 	service.CreationTimestamp = metav1.NewTime(service.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", service)
+}
+
+// @Summary	创建service
+// @accept		application/json
+// @Param		cluster	path		string	true	"Cluster"
+// @Success	200		{object}	app.Response
+// @Failure	500		{object}	app.Response
+// @Router		/k8s/services [post]
+func PostService(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var service corev1.Service
 
+	if err := appG.C.ShouldBindJSON(&service); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
 	if err != nil {
 		appG.Fail(http.StatusInternalServerError, err, nil)
 		return
 	}
-	appG.Success(http.StatusOK, "ok", service)
+	result, err := k8s.NewServiceOperation(k8sClient.K8sClient).Create(context.TODO(), &service)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	更新service
+// @accept		application/json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		serviceName	path		string	true	"ServiceName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/services/{namespace}/{serviceName} [put]
+func PutService(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u       ServiceUri
+		service corev1.Service
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&service); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewServiceOperation(k8sClient.K8sClient).Update(context.TODO(), u.Namespace, u.ServiceName, &service)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	删除service
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		serviceName	path		string	true	"ServiceName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/services/{namespace}/{serviceName} [delete]
+func DeleteService(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u ServiceUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewServiceOperation(k8sClient.K8sClient).Delete(context.TODO(), u.Namespace, u.ServiceName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	查看service的endpoints及其pod
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		serviceName	path		string	true	"ServiceName"
+// @Success	200			{object}	app.Response{data=k8s.ServiceEndpoints}
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/services/{namespace}/{serviceName}/endpoints [get]
+func GetServiceEndpoints(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u ServiceUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewServiceOperation(k8sClient.K8sClient)
+	service, err := operation.Get(context.TODO(), u.Namespace, u.ServiceName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	endpoints, err := operation.Endpoints(context.TODO(), service)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", endpoints)
 }
@@ -0,0 +1,396 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type StatefulSetsQuery struct {
+	k8s.ListParams
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+type StatefulSetUri struct {
+	Namespace       string `uri:"namespace" binding:"required"`
+	StatefulSetName string `uri:"statefulsetName" binding:"required"`
+}
+
+type StatefulSetActionQuery struct {
+	Action string `form:"action" binding:"required,oneof=scale restart"`
+	// the replicas to scale to
+	Replicas *int32 `form:"replicas" binding:"required_if=Action scale,omitempty,min=0"`
+}
+
+var StatefulSetKind = "StatefulSet"
+
+// @Summary	查看statefulset列表
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		query		string	false	"Namespace"
+// @Param		label			query		string	false	"Label"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200				{object}	app.ResponseExtra
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulsets [get]
+func GetStatefulSets(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var q StatefulSetsQuery
+
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	statefulSets, err := k8sClient.K8sClient.AppsV1().StatefulSets(q.Namespace).List(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	total, err := q.Paginate(statefulSets)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(statefulSets.Items); i++ {
+		statefulSets.Items[i].CreationTimestamp = metav1.NewTime(statefulSets.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", statefulSets)
+}
+
+// @Summary	查看statefulset
+// @Produce	application/json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulsets/{namespace}/{statefulsetName} [get]
+func GetStatefulSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u StatefulSetUri
+		q k8s.ExportParams
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+
+	statefulSet, err := k8s.NewStatefulSetOperation(k8sClient.K8sClient).Get(context.TODO(), u.Namespace, u.StatefulSetName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, statefulSet)
+		return
+	}
+	statefulSet.TypeMeta.APIVersion = AppV1APIVersion
+	statefulSet.TypeMeta.Kind = StatefulSetKind
+	statefulSet.CreationTimestamp = metav1.NewTime(statefulSet.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", statefulSet)
+}
+
+// @Summary	创建statefulset
+// @accept		application/json
+// @Param		cluster	path		string	true	"Cluster"
+// @Success	200		{object}	app.Response
+// @Failure	500		{object}	app.Response
+// @Router		/k8s/statefulsets [post]
+func PostStatefulSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var statefulSet appsv1.StatefulSet
+
+	if err := appG.C.ShouldBindJSON(&statefulSet); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewStatefulSetOperation(k8sClient.K8sClient).Create(context.TODO(), &statefulSet)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	更新statefulset
+// @accept		application/json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulsets/{namespace}/{statefulsetName} [put]
+func PutStatefulSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u           StatefulSetUri
+		statefulSet appsv1.StatefulSet
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&statefulSet); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewStatefulSetOperation(k8sClient.K8sClient).Update(context.TODO(), u.Namespace, u.StatefulSetName, &statefulSet)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	删除statefulset
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulsets/{namespace}/{statefulsetName} [delete]
+func DeleteStatefulSet(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u StatefulSetUri
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewStatefulSetOperation(k8sClient.K8sClient).Delete(context.TODO(), u.Namespace, u.StatefulSetName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	对statefulset执行操作
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Param		action			query		string	true	"scale or restart"
+// @Param		replicas		query		int		false	"Replicas to scale to, required by scale"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulsets/{namespace}/{statefulsetName} [post]
+func StatefulSetDoAction(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u StatefulSetUri
+		q StatefulSetActionQuery
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewStatefulSetOperation(k8sClient.K8sClient)
+
+	switch q.Action {
+	case "scale":
+		err = operation.Scale(context.TODO(), u.Namespace, u.StatefulSetName, *q.Replicas)
+	case "restart":
+		_, err = operation.Restart(context.TODO(), u.Namespace, u.StatefulSetName)
+	}
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	查看statefulset的滚动更新状态
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Param		label			query		string	false	"Label, the status of every statefulset it selects"
+// @Success	200				{object}	app.Response
+// @Failure	308				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulset_status/{namespace}/{statefulsetName} [get]
+func GetStatefulSetStatus(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var (
+		u StatefulSetUri
+		q DeploymentQuery
+	)
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if q.Label == "" {
+		statefulSet, err := k8s.NewStatefulSetOperation(k8sClient.K8sClient).Get(context.TODO(), u.Namespace, u.StatefulSetName)
+		if err != nil {
+			appG.Fail(apiStatusCode(err), err, nil)
+			return
+		}
+		status, reasons, err := getStatefulSetStatus(statefulSet)
+		respondRolloutStatus(&appG, status, reasons, err)
+		return
+	}
+	statefulSets, err := k8sClient.K8sClient.AppsV1().StatefulSets(u.Namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: q.Label})
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if len(statefulSets.Items) == 0 {
+		appG.Fail(http.StatusNotFound, errors.New("statefulsets not found"), nil)
+		return
+	}
+	// the first statefulset not rolled out yet tells the status of them all
+	for i := range statefulSets.Items {
+		status, reasons, err := getStatefulSetStatus(&statefulSets.Items[i])
+		if status != http.StatusOK {
+			respondRolloutStatus(&appG, status, reasons, err)
+			return
+		}
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// getStatefulSetStatus tells whether the rolling update of statefulSet is complete, as kubectl
+// rollout status does, with the status codes of getDeploymentStatus.
+func getStatefulSetStatus(statefulSet *appsv1.StatefulSet) (status int, reasons string, err error) {
+	if statefulSet.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		return http.StatusInternalServerError, "", fmt.Errorf("rollout status is only available for %s strategy type", appsv1.RollingUpdateStatefulSetStrategyType)
+	}
+	if statefulSet.Status.ObservedGeneration == 0 || statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		return http.StatusPermanentRedirect, "Waiting for statefulset spec update to be observed...", nil
+	}
+	if statefulSet.Spec.Replicas != nil && statefulSet.Status.ReadyReplicas < *statefulSet.Spec.Replicas {
+		return http.StatusPermanentRedirect, fmt.Sprintf("Waiting for %d pods to be ready...", *statefulSet.Spec.Replicas-statefulSet.Status.ReadyReplicas), nil
+	}
+	if rollingUpdate := statefulSet.Spec.UpdateStrategy.RollingUpdate; rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		if statefulSet.Spec.Replicas != nil && statefulSet.Status.UpdatedReplicas < *statefulSet.Spec.Replicas-*rollingUpdate.Partition {
+			return http.StatusPermanentRedirect, fmt.Sprintf("Waiting for partitioned roll out to finish: %d out of %d new pods have been updated...", statefulSet.Status.UpdatedReplicas, *statefulSet.Spec.Replicas-*rollingUpdate.Partition), nil
+		}
+		return http.StatusOK, fmt.Sprintf("partitioned roll out complete: %d new pods have been updated...", statefulSet.Status.UpdatedReplicas), nil
+	}
+	if statefulSet.Status.UpdateRevision != statefulSet.Status.CurrentRevision {
+		return http.StatusPermanentRedirect, fmt.Sprintf("waiting for statefulset rolling update to complete %d pods at revision %s...", statefulSet.Status.UpdatedReplicas, statefulSet.Status.UpdateRevision), nil
+	}
+	return http.StatusOK, fmt.Sprintf("statefulset rolling update complete %d pods at revision %s...", statefulSet.Status.CurrentReplicas, statefulSet.Status.CurrentRevision), nil
+}
+
+// respondRolloutStatus answers with the result of a rollout check such as getDeploymentStatus: 200
+// once rolled out, 308 with the reasons while rolling out.
+func respondRolloutStatus(appG *app.Gin, status int, reasons string, err error) {
+	switch {
+	case err != nil:
+		appG.Fail(http.StatusInternalServerError, err, reasons)
+	case status == http.StatusPermanentRedirect:
+		appG.Fail(http.StatusPermanentRedirect, errors.New("retry"), reasons)
+	default:
+		appG.Success(http.StatusOK, reasons, nil)
+	}
+}
+
+// @Summary	查看statefulset的pod
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		path		string	true	"Namespace"
+// @Param		statefulsetName	path		string	true	"StatefulSetName"
+// @Success	200				{object}	app.Response
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/statefulset_pods/{namespace}/{statefulsetName} [get]
+func GetStatefulSetPods(c *gin.Context) {
+	appG := app.Gin{C: c}
+
+	var u StatefulSetUri
+
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	operation := k8s.NewStatefulSetOperation(k8sClient.K8sClient)
+	statefulSet, err := operation.Get(context.TODO(), u.Namespace, u.StatefulSetName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	pods, err := operation.Pods(context.TODO(), statefulSet)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", pods)
+}
@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetStatefulSetStatus(t *testing.T) {
+	replicas, partition := int32(4), int32(1)
+	newStatefulSet := func(change func(*appsv1.StatefulSet)) *appsv1.StatefulSet {
+		statefulSet := &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Generation: 2},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:       &replicas,
+				UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.RollingUpdateStatefulSetStrategyType},
+			},
+			Status: appsv1.StatefulSetStatus{
+				ObservedGeneration: 2,
+				ReadyReplicas:      4,
+				UpdatedReplicas:    4,
+				CurrentReplicas:    4,
+				CurrentRevision:    "db-2",
+				UpdateRevision:     "db-2",
+			},
+		}
+		if change != nil {
+			change(statefulSet)
+		}
+		return statefulSet
+	}
+	partitioned := func(updated int32) func(*appsv1.StatefulSet) {
+		return func(s *appsv1.StatefulSet) {
+			s.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateStatefulSetStrategy{Partition: &partition}
+			s.Status.UpdatedReplicas = updated
+			s.Status.UpdateRevision = "db-3"
+		}
+	}
+
+	tests := []struct {
+		name        string
+		statefulSet *appsv1.StatefulSet
+		status      int
+		reasons     string
+		err         bool
+	}{
+		{name: "rolled out", statefulSet: newStatefulSet(nil), status: http.StatusOK,
+			reasons: "statefulset rolling update complete 4 pods at revision db-2"},
+		{name: "on delete", statefulSet: newStatefulSet(func(s *appsv1.StatefulSet) {
+			s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+		}), status: http.StatusInternalServerError, err: true},
+		{name: "never observed", statefulSet: newStatefulSet(func(s *appsv1.StatefulSet) {
+			s.Status.ObservedGeneration = 0
+		}), status: http.StatusPermanentRedirect, reasons: "Waiting for statefulset spec update to be observed"},
+		{name: "spec not observed", statefulSet: newStatefulSet(func(s *appsv1.StatefulSet) {
+			s.Generation = 3
+		}), status: http.StatusPermanentRedirect, reasons: "Waiting for statefulset spec update to be observed"},
+		{name: "pods not ready", statefulSet: newStatefulSet(func(s *appsv1.StatefulSet) {
+			s.Status.ReadyReplicas = 1
+		}), status: http.StatusPermanentRedirect, reasons: "Waiting for 3 pods to be ready"},
+		{name: "partition rolling out", statefulSet: newStatefulSet(partitioned(2)), status: http.StatusPermanentRedirect,
+			reasons: "Waiting for partitioned roll out to finish: 2 out of 3 new pods have been updated"},
+		{name: "partition rolled out", statefulSet: newStatefulSet(partitioned(3)), status: http.StatusOK,
+			reasons: "partitioned roll out complete: 3 new pods have been updated"},
+		{name: "revisions differ", statefulSet: newStatefulSet(func(s *appsv1.StatefulSet) {
+			s.Status.UpdatedReplicas = 2
+			s.Status.UpdateRevision = "db-3"
+		}), status: http.StatusPermanentRedirect, reasons: "waiting for statefulset rolling update to complete 2 pods at revision db-3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reasons, err := getStatefulSetStatus(tt.statefulSet)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if status != tt.status || !strings.HasPrefix(reasons, tt.reasons) {
+				t.Errorf("status = %d %q, want %d %q", status, reasons, tt.status, tt.reasons)
+			}
+		})
+	}
+}
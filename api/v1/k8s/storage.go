@@ -0,0 +1,316 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ClaimsQuery struct {
+	k8s.ListParams
+	Namespace string `form:"namespace"`
+	Label     string `form:"label"`
+}
+
+type ClaimUri struct {
+	Namespace string `uri:"namespace" binding:"required"`
+	ClaimName string `uri:"claimName" binding:"required"`
+}
+
+type ClaimExpandBody struct {
+	// the new storage request, such as 20Gi
+	Storage string `json:"storage" binding:"required"`
+}
+
+type StorageQuery struct {
+	k8s.ListParams
+	Label string `form:"label"`
+}
+
+type StorageSummaryUri struct {
+	Namespace string `uri:"namespace" binding:"required"`
+}
+
+// @Summary	查看pvc列表
+// @Produce	json
+// @Param		cluster			path		string	true	"Cluster"
+// @Param		namespace		query		string	false	"Namespace"
+// @Param		label			query		string	false	"Label"
+// @Param		page			query		int		false	"Page, from 1"
+// @Param		pageSize		query		int		false	"Page size, the whole list when empty"
+// @Param		sort			query		string	false	"name, namespace or creationTimestamp, - prefix for descending"
+// @Param		fieldSelector	query		string	false	"Field selector"
+// @Param		nameContains	query		string	false	"Name substring"
+// @Param		continue		query		string	false	"Continue token of the previous page"
+// @Success	200				{object}	app.ResponseExtra
+// @Failure	500				{object}	app.Response
+// @Router		/k8s/persistentvolumeclaims [get]
+func GetPersistentVolumeClaims(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q ClaimsQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	claims, err := k8s.NewStorageOperation(k8sClient.K8sClient).ListClaims(context.TODO(), q.Namespace, q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(claims)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(claims.Items); i++ {
+		claims.Items[i].CreationTimestamp = metav1.NewTime(claims.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", claims)
+}
+
+// @Summary	查看pvc
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		claimName	path		string	true	"ClaimName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/persistentvolumeclaims/{namespace}/{claimName} [get]
+func GetPersistentVolumeClaim(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u ClaimUri
+		q k8s.ExportParams
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	claim, err := k8s.NewStorageOperation(k8sClient.K8sClient).GetClaim(context.TODO(), u.Namespace, u.ClaimName)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	if q.Exporting() {
+		exportObject(&appG, &q, claim)
+		return
+	}
+	claim.CreationTimestamp = metav1.NewTime(claim.CreationTimestamp.Add(8 * time.Hour))
+	appG.Success(http.StatusOK, "ok", claim)
+}
+
+// @Summary	创建pvc
+// @accept		application/json
+// @Param		cluster	path		string	true	"Cluster"
+// @Success	200		{object}	app.Response
+// @Failure	500		{object}	app.Response
+// @Router		/k8s/persistentvolumeclaims [post]
+func PostPersistentVolumeClaim(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var claim corev1.PersistentVolumeClaim
+	if err := appG.C.ShouldBindJSON(&claim); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewStorageOperation(k8sClient.K8sClient).CreateClaim(context.TODO(), &claim)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	删除pvc
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Param		claimName	path		string	true	"ClaimName"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/persistentvolumeclaims/{namespace}/{claimName} [delete]
+func DeletePersistentVolumeClaim(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u ClaimUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	if err := k8s.NewStorageOperation(k8sClient.K8sClient).DeleteClaim(context.TODO(), u.Namespace, u.ClaimName); err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", nil)
+}
+
+// @Summary	扩容pvc
+// @accept		application/json
+// @Param		cluster		path		string			true	"Cluster"
+// @Param		namespace	path		string			true	"Namespace"
+// @Param		claimName	path		string			true	"ClaimName"
+// @Param		RequestBody	body		v1.ClaimExpandBody	true	"RequestBody"
+// @Success	200			{object}	app.Response
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/persistentvolumeclaims/{namespace}/{claimName}/expand [post]
+func ExpandPersistentVolumeClaim(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var (
+		u ClaimUri
+		b ClaimExpandBody
+	)
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	if err := appG.C.ShouldBindJSON(&b); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	size, err := resource.ParseQuantity(b.Storage)
+	if err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	result, err := k8s.NewStorageOperation(k8sClient.K8sClient).ExpandClaim(context.TODO(), u.Namespace, u.ClaimName, size)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", result)
+}
+
+// @Summary	查看pv列表
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		label		query		string	false	"Label"
+// @Param		page		query		int		false	"Page, from 1"
+// @Param		pageSize	query		int		false	"Page size, the whole list when empty"
+// @Success	200			{object}	app.ResponseExtra
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/persistentvolumes [get]
+func GetPersistentVolumes(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q StorageQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	volumes, err := k8s.NewStorageOperation(k8sClient.K8sClient).ListVolumes(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(volumes)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(volumes.Items); i++ {
+		volumes.Items[i].CreationTimestamp = metav1.NewTime(volumes.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", volumes)
+}
+
+// @Summary	查看storageclass列表
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		label		query		string	false	"Label"
+// @Param		page		query		int		false	"Page, from 1"
+// @Param		pageSize	query		int		false	"Page size, the whole list when empty"
+// @Success	200			{object}	app.ResponseExtra
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/storageclasses [get]
+func GetStorageClasses(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var q StorageQuery
+	if err := appG.C.ShouldBindQuery(&q); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	classes, err := k8s.NewStorageOperation(k8sClient.K8sClient).ListClasses(context.TODO(), q.ListOptions(q.Label))
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	total, err := q.Paginate(classes)
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	for i := 0; i < len(classes.Items); i++ {
+		classes.Items[i].CreationTimestamp = metav1.NewTime(classes.Items[i].CreationTimestamp.Add(8 * time.Hour))
+	}
+	appG.SuccessExtra(total, q.PageNumber(), q.PageSize, http.StatusOK, "ok", classes)
+}
+
+// @Summary	查看namespace的存储概况
+// @Produce	json
+// @Param		cluster		path		string	true	"Cluster"
+// @Param		namespace	path		string	true	"Namespace"
+// @Success	200			{object}	app.Response{data=k8s.StorageSummary}
+// @Failure	500			{object}	app.Response
+// @Router		/k8s/storage_summary/{namespace} [get]
+func GetStorageSummary(c *gin.Context) {
+	appG := app.Gin{C: c}
+	var u StorageSummaryUri
+	if err := appG.C.ShouldBindUri(&u); err != nil {
+		appG.Fail(http.StatusBadRequest, err, nil)
+		return
+	}
+	k8sClient, err := k8s.GetClientForRequest(c.Request.Context())
+	if err != nil {
+		appG.Fail(http.StatusInternalServerError, err, nil)
+		return
+	}
+	summary, err := k8s.NewStorageOperation(k8sClient.K8sClient).Summary(context.TODO(), u.Namespace)
+	if err != nil {
+		appG.Fail(apiStatusCode(err), err, nil)
+		return
+	}
+	appG.Success(http.StatusOK, "ok", summary)
+}
@@ -1,10 +1,12 @@
 package app
 
 import (
+	"mime"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/marmotedu/errors"
 )
 
 type Gin struct {
@@ -46,6 +48,12 @@ func (g *Gin) SuccessExtra(total int64, page int, pageSize, httpCode int, msg st
 	})
 }
 
+// SuccessFile sends data as a file download named filename.
+func (g *Gin) SuccessFile(httpCode int, filename, contentType string, data []byte) {
+	g.C.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	g.C.Data(httpCode, contentType, data)
+}
+
 func (g *Gin) Fail(httpCode int, err error, data interface{}) {
 	errs, ok := err.(validator.ValidationErrors)
 	if !ok {
@@ -65,6 +73,17 @@ func (g *Gin) Fail(httpCode int, err error, data interface{}) {
 	})
 }
 
+// FailWithCode aborts with one of the error codes registered in utils/code.go, err must carry it,
+// e.g. errors.WithCode(utils.ErrTokenInvalid, "...").
+func (g *Gin) FailWithCode(err error, data interface{}) {
+	coder := errors.ParseCoder(err)
+	g.C.AbortWithStatusJSON(coder.HTTPStatus(), Response{
+		Code: coder.Code(),
+		Msg:  coder.String(),
+		Data: data,
+	})
+}
+
 // 去掉结构体名称标识
 func removeTopStruct(fields map[string]string) map[string]string {
 	res := map[string]string{}
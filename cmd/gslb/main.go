@@ -7,8 +7,10 @@ import (
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/coremain"
+	_ "github.com/coredns/coredns/plugin/dnssec"
 	_ "github.com/coredns/coredns/plugin/errors"
 	_ "github.com/coredns/coredns/plugin/health"
+	_ "github.com/coredns/coredns/plugin/metrics"
 	_ "github.com/coredns/coredns/plugin/ready"
 	_ "github.com/coredns/coredns/plugin/trace"
 	_ "github.com/coredns/coredns/plugin/whoami"
@@ -17,9 +19,11 @@ import (
 
 var directives = []string{
 	"trace",
+	"prometheus",
 	"errors",
 	"health",
 	"ready",
+	"dnssec",
 	"crossdns",
 	"whoami",
 }
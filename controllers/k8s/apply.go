@@ -0,0 +1,124 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// FieldManager owns the fields nightwatcher applies.
+const FieldManager = "nightwatcher"
+
+type ApplyOptions struct {
+	// Namespace of the namespaced objects that have none.
+	Namespace string
+	DryRun    bool
+	// Force takes over fields owned by other managers instead of failing on conflicts.
+	Force bool
+}
+
+// ApplyResult is the outcome of applying one object, Object is what the apiserver returned, with the
+// values of Secrets masked.
+type ApplyResult struct {
+	APIVersion string                     `json:"apiVersion"`
+	Kind       string                     `json:"kind"`
+	Namespace  string                     `json:"namespace,omitempty"`
+	Name       string                     `json:"name"`
+	Object     *unstructured.Unstructured `json:"object,omitempty"`
+	Error      string                     `json:"error,omitempty"`
+}
+
+// DecodeObjects reads the YAML or JSON documents of r, the items of List documents are returned as
+// objects of their own.
+func DecodeObjects(r io.Reader) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+	var objs []*unstructured.Unstructured
+	for i := 0; ; i++ {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		if !obj.IsList() {
+			objs = append(objs, obj)
+			continue
+		}
+		err := obj.EachListItem(func(item runtime.Object) error {
+			objs = append(objs, item.(*unstructured.Unstructured))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %v", i, err)
+		}
+	}
+}
+
+// Apply applies objs one after the other with server-side apply, a failing object doesn't stop the
+// ones after it.
+func (c *ClientManager) Apply(ctx context.Context, objs []*unstructured.Unstructured, opts ApplyOptions) []ApplyResult {
+	applyOpts := metav1.ApplyOptions{FieldManager: FieldManager, Force: opts.Force}
+	if opts.DryRun {
+		applyOpts.DryRun = []string{metav1.DryRunAll}
+	}
+
+	results := make([]ApplyResult, len(objs))
+	for i, obj := range objs {
+		result := &results[i]
+		result.APIVersion, result.Kind, result.Name = obj.GetAPIVersion(), obj.GetKind(), obj.GetName()
+
+		mapping, err := c.restMapping(obj)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			if obj.GetNamespace() == "" {
+				obj.SetNamespace(opts.Namespace)
+			}
+			result.Namespace = obj.GetNamespace()
+		} else {
+			obj.SetNamespace("")
+		}
+		// the apiserver refuses to apply objects carrying managed fields, exported ones do
+		obj.SetManagedFields(nil)
+
+		applied, err := c.K8sDynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()).Apply(ctx, obj.GetName(), obj, applyOpts)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		MaskSecretObject(applied)
+		result.Object = applied
+	}
+	return results
+}
+
+// restMapping maps the kind of obj to its resource. Discovery is refreshed once on a miss, the kind
+// may come from a CRD applied just before.
+func (c *ClientManager) restMapping(obj *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := obj.GroupVersionKind()
+	if gvk.Kind == "" || gvk.Version == "" {
+		return nil, errors.New("the object has no apiVersion or kind")
+	}
+	if obj.GetName() == "" {
+		return nil, errors.New("the object has no name")
+	}
+	mapping, err := c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		c.Mapper.Reset()
+		mapping, err = c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	return mapping, err
+}
@@ -0,0 +1,58 @@
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeObjects(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string // kind/name of the objects
+		err   string
+	}{
+		{name: "nothing", input: ""},
+		{name: "yaml documents", input: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n---\n" +
+			"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n",
+			want: []string{"ConfigMap/web", "Deployment/web"}},
+		{name: "empty documents", input: "---\n# nothing here\n---\n\n---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n---\n",
+			want: []string{"Service/web"}},
+		{name: "json", input: `{"apiVersion":"v1","kind":"Secret","metadata":{"name":"db"}}`,
+			want: []string{"Secret/db"}},
+		{name: "list", input: "apiVersion: v1\nkind: List\nitems:\n" +
+			"- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: a\n" +
+			"- apiVersion: v1\n  kind: ConfigMap\n  metadata:\n    name: b\n---\n" +
+			"apiVersion: v1\nkind: Namespace\nmetadata:\n  name: shop\n",
+			want: []string{"ConfigMap/a", "ConfigMap/b", "Namespace/shop"}},
+		{name: "typed list", input: `{"apiVersion":"v1","kind":"ServiceList","items":[{"apiVersion":"v1","kind":"Service","metadata":{"name":"web"}}]}`,
+			want: []string{"Service/web"}},
+		{name: "empty list", input: "apiVersion: v1\nkind: List\nitems: []\n"},
+		{name: "invalid document", input: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\n---\nkind: [\n",
+			err: "document 1:"},
+		{name: "invalid list item", input: "apiVersion: v1\nkind: List\nitems:\n- not an object\n",
+			err: "document 0:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs, err := DecodeObjects(strings.NewReader(tt.input))
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, obj := range objs {
+				got = append(got, obj.GetKind()+"/"+obj.GetName())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("objects = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package k8s
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	appv1alpha1 "github.com/lmxia/gaia/pkg/apis/apps/v1alpha1"
+	platformv1alpha1 "github.com/lmxia/gaia/pkg/apis/platform/v1alpha1"
+	gaiainformers "github.com/lmxia/gaia/pkg/generated/informers/externalversions"
+	gaialisters "github.com/lmxia/gaia/pkg/generated/listers/apps/v1alpha1"
+	platformlisters "github.com/lmxia/gaia/pkg/generated/listers/platform/v1alpha1"
+	"github.com/lmxia/nightwatcher/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/lru"
+)
+
+// The resources the read cache serves lists of.
+var (
+	PodsResource            = schema.GroupResource{Resource: "pods"}
+	ServicesResource        = schema.GroupResource{Resource: "services"}
+	DeploymentsResource     = schema.GroupResource{Group: "apps", Resource: "deployments"}
+	JobsResource            = schema.GroupResource{Group: "batch", Resource: "jobs"}
+	CronJobsResource        = schema.GroupResource{Group: "batch", Resource: "cronjobs"}
+	DescriptionsResource    = schema.GroupResource{Group: "apps.gaia.io", Resource: "descriptions"}
+	ManagedClustersResource = schema.GroupResource{Group: "platform.gaia.io", Resource: "managedclusters"}
+)
+
+const (
+	readCacheResync = 10 * time.Minute
+	// how long an access review of a caller is trusted.
+	accessReviewTTL       = 30 * time.Second
+	accessReviewCacheSize = 4096
+)
+
+// ReadCache serves lists of the local cluster from shared informers instead of the apiserver.
+type ReadCache struct {
+	synced map[schema.GroupResource]cache.InformerSynced
+
+	pods            corelisters.PodLister
+	services        corelisters.ServiceLister
+	deployments     appslisters.DeploymentLister
+	jobs            batchlisters.JobLister
+	cronJobs        batchlisters.CronJobLister
+	descriptions    gaialisters.DescriptionLister
+	managedClusters platformlisters.ManagedClusterLister
+
+	reviews *lru.Cache
+}
+
+var (
+	readCacheOnce sync.Once
+	readCache     *ReadCache
+)
+
+// GetReadCache starts the read cache on first use. It returns nil when READ_CACHE is "false" or the
+// local clients are unavailable.
+func GetReadCache() *ReadCache {
+	readCacheOnce.Do(func() {
+		if utils.GetEnvDefault("READ_CACHE", "true") == "false" {
+			return
+		}
+		clients, err := GetClientWithPanic()
+		if err != nil {
+			klog.Errorf("read cache is disabled: %v", err)
+			return
+		}
+		readCache = newReadCache(clients)
+	})
+	return readCache
+}
+
+func newReadCache(clients *ClientManager) *ReadCache {
+	kubeFactory := informers.NewSharedInformerFactory(clients.K8sClient, readCacheResync)
+	gaiaFactory := gaiainformers.NewSharedInformerFactory(clients.Gaiaclient, readCacheResync)
+
+	pods := kubeFactory.Core().V1().Pods()
+	services := kubeFactory.Core().V1().Services()
+	deployments := kubeFactory.Apps().V1().Deployments()
+	jobs := kubeFactory.Batch().V1().Jobs()
+	descriptions := gaiaFactory.Apps().V1alpha1().Descriptions()
+	managedClusters := gaiaFactory.Platform().V1alpha1().ManagedClusters()
+
+	rc := &ReadCache{
+		synced: map[schema.GroupResource]cache.InformerSynced{
+			PodsResource:            pods.Informer().HasSynced,
+			ServicesResource:        services.Informer().HasSynced,
+			DeploymentsResource:     deployments.Informer().HasSynced,
+			JobsResource:            jobs.Informer().HasSynced,
+			DescriptionsResource:    descriptions.Informer().HasSynced,
+			ManagedClustersResource: managedClusters.Informer().HasSynced,
+		},
+		pods:            pods.Lister(),
+		services:        services.Lister(),
+		deployments:     deployments.Lister(),
+		jobs:            jobs.Lister(),
+		descriptions:    descriptions.Lister(),
+		managedClusters: managedClusters.Lister(),
+		reviews:         lru.New(accessReviewCacheSize),
+	}
+	// clusters older than 1.21 only serve batch/v1beta1 CronJobs, their lists go to the apiserver
+	if version, err := clients.CronJobVersion(); err == nil && version == batchv1.SchemeGroupVersion {
+		cronJobs := kubeFactory.Batch().V1().CronJobs()
+		rc.synced[CronJobsResource] = cronJobs.Informer().HasSynced
+		rc.cronJobs = cronJobs.Lister()
+	}
+	kubeFactory.Start(nil)
+	gaiaFactory.Start(nil)
+	return rc
+}
+
+// Synced reports whether the cache of resource holds a full copy of the cluster.
+func (rc *ReadCache) Synced(resource schema.GroupResource) bool {
+	synced, ok := rc.synced[resource]
+	return ok && synced()
+}
+
+// UseReadCache returns the read cache when a list of resource in namespace can be served from it:
+// the caller didn't ask for a consistent read, the request targets the local cluster, the cache has
+// synced, and the caller is allowed to list the resource there. Otherwise the list has to go to the
+// apiserver, which also reports any authorization error the usual way.
+func UseReadCache(ctx context.Context, resource schema.GroupResource, namespace string, consistent bool) (*ReadCache, bool) {
+	if consistent {
+		return nil, false
+	}
+	if _, ok := utils.ClusterFrom(ctx); ok {
+		return nil, false
+	}
+	rc := GetReadCache()
+	if rc == nil || !rc.Synced(resource) {
+		return nil, false
+	}
+	if user, ok := utils.UserFrom(ctx); ok && !rc.canList(ctx, user, resource, namespace) {
+		return nil, false
+	}
+	return rc, true
+}
+
+type accessReview struct {
+	allowed bool
+	expires time.Time
+}
+
+// canList asks the apiserver whether user may list resource in namespace, since the cache itself was
+// filled with the service account's permissions.
+func (rc *ReadCache) canList(ctx context.Context, user *authenticationv1.UserInfo, resource schema.GroupResource, namespace string) bool {
+	key := accessReviewKey(user, resource, namespace)
+	cached, ok := rc.reviews.Get(key)
+	if ok && time.Now().Before(cached.(*accessReview).expires) {
+		return cached.(*accessReview).allowed
+	}
+
+	clients, err := GetClientWithPanic()
+	if err != nil {
+		return false
+	}
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	review, err := clients.K8sClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user.Username,
+			UID:    user.UID,
+			Groups: user.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "list",
+				Group:     resource.Group,
+				Resource:  resource.Resource,
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		klog.Errorf("access review of %s failed: %v", user.Username, err)
+		return false
+	}
+
+	rc.reviews.Add(key, &accessReview{allowed: review.Status.Allowed, expires: time.Now().Add(accessReviewTTL)})
+	return review.Status.Allowed
+}
+
+// accessReviewKey identifies a review of user listing resource in namespace. The user is keyed as its
+// impersonated clients are, so reviews of other groups or extra attributes are not shared.
+func accessReviewKey(user *authenticationv1.UserInfo, resource schema.GroupResource, namespace string) string {
+	return strings.Join([]string{impersonationKey(user), resource.String(), namespace}, "|")
+}
+
+// The list functions below take a label selector in the same syntax as the apiserver and return deep
+// copies, callers are free to modify them.
+
+func (rc *ReadCache) ListPods(namespace, label string) (*corev1.PodList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.pods.Pods(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PodList{Items: make([]corev1.Pod, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListServices(namespace, label string) (*corev1.ServiceList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.services.Services(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.ServiceList{Items: make([]corev1.Service, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListDeployments(namespace, label string) (*appsv1.DeploymentList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.deployments.Deployments(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &appsv1.DeploymentList{Items: make([]appsv1.Deployment, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListJobs(namespace, label string) (*batchv1.JobList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.jobs.Jobs(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &batchv1.JobList{Items: make([]batchv1.Job, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListCronJobs(namespace, label string) (*batchv1.CronJobList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.cronJobs.CronJobs(namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &batchv1.CronJobList{Items: make([]batchv1.CronJob, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListDescriptions(label string) (*appv1alpha1.DescriptionList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.descriptions.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &appv1alpha1.DescriptionList{Items: make([]appv1alpha1.Description, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
+
+func (rc *ReadCache) ListManagedClusters(label string) (*platformv1alpha1.ManagedClusterList, error) {
+	selector, err := labels.Parse(label)
+	if err != nil {
+		return nil, err
+	}
+	items, err := rc.managedClusters.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &platformv1alpha1.ManagedClusterList{Items: make([]platformv1alpha1.ManagedCluster, 0, len(items))}
+	for _, item := range items {
+		list.Items = append(list.Items, *item.DeepCopy())
+	}
+	return list, nil
+}
@@ -0,0 +1,32 @@
+package k8s
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestAccessReviewKey(t *testing.T) {
+	alice := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"dev", "ops"},
+		Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read", "write"}}}
+	key := accessReviewKey(alice, PodsResource, "shop")
+
+	reordered := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"ops", "dev"},
+		Extra: map[string]authenticationv1.ExtraValue{"scopes": {"write", "read"}}}
+	if accessReviewKey(reordered, PodsResource, "shop") != key {
+		t.Errorf("groups or extra in another order miss the review")
+	}
+
+	otherScopes := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"dev", "ops"},
+		Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read"}}}
+	for name, other := range map[string]string{
+		"other extra":     accessReviewKey(otherScopes, PodsResource, "shop"),
+		"other resource":  accessReviewKey(alice, DeploymentsResource, "shop"),
+		"other namespace": accessReviewKey(alice, PodsResource, "web"),
+		"all namespaces":  accessReviewKey(alice, PodsResource, ""),
+	} {
+		if other == key {
+			t.Errorf("%s shares the review", name)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+const (
+	// RolloutAnnotation keeps the status of the rollout of a deployment, as JSON.
+	RolloutAnnotation = "nightwatcher.io/rollout"
+	// RolloutLabel names the deployment whose rollout created, or rerouted, the object.
+	RolloutLabel = "nightwatcher.io/rollout"
+	// RolloutTrackLabel tells the new pods of a rollout apart, its value is a track.
+	RolloutTrackLabel = "nightwatcher.io/rollout-track"
+	// RolloutSelectorAnnotation keeps the own selector of a service rerouted by a rollout, as JSON.
+	RolloutSelectorAnnotation = "nightwatcher.io/rollout-selector"
+)
+
+// Tracks of the new pods of a rollout.
+const (
+	// TrackCanary pods take their share of the traffic next to the stable ones.
+	TrackCanary = "canary"
+	// TrackGreen pods take no traffic until the services are switched over to them.
+	TrackGreen = "green"
+)
+
+// RolloutTarget returns the deployment running replicas new pods next to stable, with containers
+// updated. Its pods carry the labels of the stable ones but hidden, so that the selectors using them
+// leave the new pods out.
+func RolloutTarget(stable *appsv1.Deployment, track string, replicas int32, containers map[string]ContainerUpdate, hidden sets.Set[string]) (*appsv1.Deployment, error) {
+	rolloutLabels := map[string]string{RolloutLabel: stable.Name, RolloutTrackLabel: track}
+
+	target := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      stable.Name + "-" + track,
+			Namespace: stable.Namespace,
+			Labels:    labels.Merge(stable.Labels, rolloutLabels),
+		},
+		Spec: *stable.Spec.DeepCopy(),
+	}
+	target.Spec.Replicas = &replicas
+	target.Spec.Paused = false
+
+	template := &target.Spec.Template
+	template.Labels = labels.Merge(template.Labels, rolloutLabels)
+	selector := target.Spec.Selector
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+		target.Spec.Selector = selector
+	}
+	expressions := selector.MatchExpressions[:0]
+	for _, expression := range selector.MatchExpressions {
+		if !hidden.Has(expression.Key) {
+			expressions = append(expressions, expression)
+		}
+	}
+	selector.MatchExpressions = expressions
+	for key := range hidden {
+		delete(template.Labels, key)
+		delete(selector.MatchLabels, key)
+	}
+	selector.MatchLabels = labels.Merge(selector.MatchLabels, rolloutLabels)
+
+	patch, err := ContainersPatch(&template.Spec, containers, nil, "spec", "template")
+	if err != nil {
+		return nil, err
+	}
+	original, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, &appsv1.Deployment{})
+	if err != nil {
+		return nil, err
+	}
+	result := &appsv1.Deployment{}
+	if err := json.Unmarshal(patched, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RolloutAccess is what a rollout of a deployment of namespace does after its request returned, the
+// services are only rerouted by a blue/green one.
+func RolloutAccess(namespace string, blueGreen bool) []authorizationv1.ResourceAttributes {
+	access := []authorizationv1.ResourceAttributes{
+		{Namespace: namespace, Verb: "get", Group: "apps", Resource: "deployments"},
+		{Namespace: namespace, Verb: "patch", Group: "apps", Resource: "deployments"},
+		{Namespace: namespace, Verb: "delete", Group: "apps", Resource: "deployments"},
+		{Namespace: namespace, Verb: "list", Group: "apps", Resource: "replicasets"},
+		{Namespace: namespace, Verb: "list", Resource: "pods"},
+		{Namespace: namespace, Verb: "list", Resource: "services"},
+	}
+	if blueGreen {
+		access = append(access, authorizationv1.ResourceAttributes{Namespace: namespace, Verb: "patch", Resource: "services"})
+	}
+	return access
+}
+
+// Authorize fails with a Forbidden error unless the user of the clients may do all of access.
+func (c *ClientManager) Authorize(ctx context.Context, access []authorizationv1.ResourceAttributes) error {
+	for i := range access {
+		review, err := c.K8sClient.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &access[i]},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return err
+		}
+		if !review.Status.Allowed {
+			resource := schema.GroupResource{Group: access[i].Group, Resource: access[i].Resource}
+			return apierrors.NewForbidden(resource, access[i].Name,
+				fmt.Errorf("cannot %s %s in the namespace %q", access[i].Verb, resource, access[i].Namespace))
+		}
+	}
+	return nil
+}
+
+// RoutingServices returns the services of the namespace of deployment sending traffic to its pods.
+func (c *ClientManager) RoutingServices(ctx context.Context, deployment *appsv1.Deployment) ([]corev1.Service, error) {
+	services, err := c.K8sClient.CoreV1().Services(deployment.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	podLabels := labels.Set(deployment.Spec.Template.Labels)
+	var routing []corev1.Service
+	for _, service := range services.Items {
+		if len(service.Spec.Selector) > 0 && labels.SelectorFromSet(service.Spec.Selector).Matches(podLabels) {
+			routing = append(routing, service)
+		}
+	}
+	return routing, nil
+}
+
+// RouteServices points services at the pods of the rollout of deployment, they keep their own
+// selector for RestoreServices.
+func (c *ClientManager) RouteServices(ctx context.Context, deployment string, services []corev1.Service, selector map[string]string) error {
+	for _, service := range services {
+		patch := map[string]interface{}{
+			"spec": map[string]interface{}{"selector": replaceSelector(service.Spec.Selector, selector)},
+		}
+		// rerouted already, the selector is not its own
+		if _, ok := service.Annotations[RolloutSelectorAnnotation]; !ok {
+			own, err := json.Marshal(service.Spec.Selector)
+			if err != nil {
+				return err
+			}
+			patch["metadata"] = map[string]interface{}{
+				"labels":      map[string]interface{}{RolloutLabel: deployment},
+				"annotations": map[string]interface{}{RolloutSelectorAnnotation: string(own)},
+			}
+		}
+		if err := c.patchService(ctx, &service, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreServices points the services rerouted by the rollout of deployment back at their own pods.
+func (c *ClientManager) RestoreServices(ctx context.Context, namespace, deployment string) error {
+	services, err := c.K8sClient.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{RolloutLabel: deployment}.String(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, service := range services.Items {
+		var own map[string]string
+		if err := json.Unmarshal([]byte(service.Annotations[RolloutSelectorAnnotation]), &own); err != nil {
+			return err
+		}
+		patch := map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels":      map[string]interface{}{RolloutLabel: nil},
+				"annotations": map[string]interface{}{RolloutSelectorAnnotation: nil},
+			},
+			"spec": map[string]interface{}{"selector": replaceSelector(service.Spec.Selector, own)},
+		}
+		if err := c.patchService(ctx, &service, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *ClientManager) patchService(ctx context.Context, service *corev1.Service, patch map[string]interface{}) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = c.K8sClient.CoreV1().Services(service.Namespace).Patch(ctx, service.Name, types.MergePatchType, data, metav1.PatchOptions{})
+	return err
+}
+
+// replaceSelector returns the merge patch turning the selector current into selector.
+func replaceSelector(current, selector map[string]string) map[string]interface{} {
+	patch := make(map[string]interface{}, len(current)+len(selector))
+	for key := range current {
+		patch[key] = nil
+	}
+	for key, value := range selector {
+		patch[key] = value
+	}
+	return patch
+}
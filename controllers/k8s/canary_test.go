@@ -0,0 +1,166 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	restclient "k8s.io/client-go/rest"
+)
+
+func newStableDeployment() *appsv1.Deployment {
+	replicas := int32(4)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "shop",
+			Name:            "web",
+			Labels:          map[string]string{"team": "shop"},
+			ResourceVersion: "7",
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Paused:   true,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": "web", "tier": "front"},
+				MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "env", Operator: metav1.LabelSelectorOpIn, Values: []string{"prod"}},
+					{Key: "zone", Operator: metav1.LabelSelectorOpExists},
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web", "tier": "front", "env": "prod", "zone": "a"}},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "web", Image: "web:1"},
+					{Name: "sidecar", Image: "envoy:1"},
+				}},
+			},
+		},
+	}
+}
+
+func TestRolloutTarget(t *testing.T) {
+	containers := map[string]ContainerUpdate{"web": {Image: "web:2"}}
+
+	tests := []struct {
+		name         string
+		track        string
+		replicas     int32
+		hidden       sets.Set[string]
+		labels       map[string]string
+		matchLabels  map[string]string
+		expressions  []string
+		templateKeys []string
+	}{
+		{name: "canary", track: TrackCanary, replicas: 1,
+			labels:       map[string]string{"team": "shop", RolloutLabel: "web", RolloutTrackLabel: TrackCanary},
+			matchLabels:  map[string]string{"app": "web", "tier": "front", RolloutLabel: "web", RolloutTrackLabel: TrackCanary},
+			expressions:  []string{"env", "zone"},
+			templateKeys: []string{"app", "env", RolloutLabel, RolloutTrackLabel, "tier", "zone"}},
+		{name: "green", track: TrackGreen, replicas: 4, hidden: sets.New("app", "env"),
+			labels:       map[string]string{"team": "shop", RolloutLabel: "web", RolloutTrackLabel: TrackGreen},
+			matchLabels:  map[string]string{"tier": "front", RolloutLabel: "web", RolloutTrackLabel: TrackGreen},
+			expressions:  []string{"zone"},
+			templateKeys: []string{RolloutLabel, RolloutTrackLabel, "tier", "zone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stable := newStableDeployment()
+			target, err := RolloutTarget(stable, tt.track, tt.replicas, containers, tt.hidden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stable, newStableDeployment()) {
+				t.Errorf("the stable deployment was changed: %+v", stable)
+			}
+
+			if target.Name != "web-"+tt.track || target.Namespace != "shop" || target.ResourceVersion != "" {
+				t.Errorf("target = %s/%s, resource version %q", target.Namespace, target.Name, target.ResourceVersion)
+			}
+			if !reflect.DeepEqual(target.Labels, tt.labels) {
+				t.Errorf("labels = %v, want %v", target.Labels, tt.labels)
+			}
+			if *target.Spec.Replicas != tt.replicas || target.Spec.Paused {
+				t.Errorf("replicas = %d, paused %v", *target.Spec.Replicas, target.Spec.Paused)
+			}
+			if !reflect.DeepEqual(target.Spec.Selector.MatchLabels, tt.matchLabels) {
+				t.Errorf("match labels = %v, want %v", target.Spec.Selector.MatchLabels, tt.matchLabels)
+			}
+			var expressions []string
+			for _, expression := range target.Spec.Selector.MatchExpressions {
+				expressions = append(expressions, expression.Key)
+			}
+			if !reflect.DeepEqual(expressions, tt.expressions) {
+				t.Errorf("match expressions = %v, want %v", expressions, tt.expressions)
+			}
+			if keys := sets.List(sets.KeySet(target.Spec.Template.Labels)); !reflect.DeepEqual(keys, tt.templateKeys) {
+				t.Errorf("template labels = %v, want %v", keys, tt.templateKeys)
+			}
+
+			want := []corev1.Container{{Name: "web", Image: "web:2"}, {Name: "sidecar", Image: "envoy:1"}}
+			if !reflect.DeepEqual(target.Spec.Template.Spec.Containers, want) {
+				t.Errorf("containers = %+v, want %+v", target.Spec.Template.Spec.Containers, want)
+			}
+		})
+	}
+}
+
+func TestRolloutTargetWithoutSelector(t *testing.T) {
+	stable := newStableDeployment()
+	stable.Spec.Selector = nil
+	target, err := RolloutTarget(stable, TrackCanary, 1, map[string]ContainerUpdate{"web": {Image: "web:2"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{RolloutLabel: "web", RolloutTrackLabel: TrackCanary}
+	if !reflect.DeepEqual(target.Spec.Selector.MatchLabels, want) {
+		t.Errorf("match labels = %v, want %v", target.Spec.Selector.MatchLabels, want)
+	}
+}
+
+func TestRolloutTargetUnknownContainer(t *testing.T) {
+	if _, err := RolloutTarget(newStableDeployment(), TrackCanary, 1, map[string]ContainerUpdate{"api": {Image: "api:2"}}, nil); err == nil {
+		t.Errorf("a container missing from the deployment was rolled out")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	// the caller may do anything but patch services.
+	var reviewed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var review authorizationv1.SelfSubjectAccessReview
+		if r.URL.Path != "/apis/authorization.k8s.io/v1/selfsubjectaccessreviews" || json.NewDecoder(r.Body).Decode(&review) != nil {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		attributes := review.Spec.ResourceAttributes
+		reviewed = append(reviewed, attributes.Verb+" "+attributes.Resource)
+		review.Status.Allowed = attributes.Verb != "patch" || attributes.Resource != "services"
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&review)
+	}))
+	defer srv.Close()
+	clients, err := NewClientManager(&restclient.Config{Host: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := clients.Authorize(context.Background(), RolloutAccess("shop", false)); err != nil {
+		t.Errorf("canary: %v", err)
+	}
+	if len(reviewed) != len(RolloutAccess("shop", false)) {
+		t.Errorf("reviewed %v", reviewed)
+	}
+	err = clients.Authorize(context.Background(), RolloutAccess("shop", true))
+	if !apierrors.IsForbidden(err) {
+		t.Errorf("blue/green: err = %v, want forbidden", err)
+	}
+}
@@ -0,0 +1,144 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lmxia/nightwatcher/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// the secret in a ManagedCluster's namespace holding the credentials to reach it.
+	clusterSecretKubeConfig    = "kubeconfig"
+	clusterSecretToken         = corev1.ServiceAccountTokenKey
+	clusterSecretCACert        = corev1.ServiceAccountRootCAKey
+	clusterSecretAPIServerURL  = "apiserver-advertise-url"
+	clusterHealthCheckInterval = 30 * time.Second
+	clusterHealthCheckTimeout  = 5 * time.Second
+	// clients unused for this long are evicted.
+	clusterClientIdleTimeout = 30 * time.Minute
+)
+
+type clusterClient struct {
+	clients  *ClientManager
+	lastUsed time.Time
+}
+
+var (
+	clusterClientsLock  sync.Mutex
+	clusterClients      = make(map[string]*clusterClient)
+	clusterHealthChecks sync.Once
+)
+
+// GetClusterClient returns the clients of the named ManagedCluster, built from the credentials secret
+// in the ManagedCluster's namespace and cached until they turn unhealthy or idle.
+func GetClusterClient(ctx context.Context, cluster string) (*ClientManager, error) {
+	clusterHealthChecks.Do(func() {
+		go wait.Until(checkClusterClients, clusterHealthCheckInterval, wait.NeverStop)
+	})
+
+	clusterClientsLock.Lock()
+	if cached, ok := clusterClients[cluster]; ok {
+		cached.lastUsed = time.Now()
+		clusterClientsLock.Unlock()
+		return cached.clients, nil
+	}
+	clusterClientsLock.Unlock()
+
+	config, err := clusterRestConfig(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := NewClientManager(config)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterClientsLock.Lock()
+	defer clusterClientsLock.Unlock()
+	if cached, ok := clusterClients[cluster]; ok {
+		// built concurrently by another request.
+		return cached.clients, nil
+	}
+	clusterClients[cluster] = &clusterClient{clients: clients, lastUsed: time.Now()}
+	return clients, nil
+}
+
+func clusterRestConfig(ctx context.Context, cluster string) (*restclient.Config, error) {
+	local, err := GetClientWithPanic()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := local.Gaiaclient.PlatformV1alpha1().ManagedClusters(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	namespace := ""
+	for _, item := range clusters.Items {
+		if item.Name == cluster {
+			namespace = item.Namespace
+			break
+		}
+	}
+	if namespace == "" {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Group: "platform.gaia.io", Resource: "managedclusters"}, cluster)
+	}
+
+	secretName := utils.GetEnvDefault("CLUSTER_CREDENTIALS_SECRET", "child-cluster-deployer")
+	secret, err := local.K8sClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("can't get credentials of cluster %s: %v", cluster, err)
+	}
+	if kubeConfig, ok := secret.Data[clusterSecretKubeConfig]; ok {
+		return clientcmd.RESTConfigFromKubeConfig(kubeConfig)
+	}
+	serverURL, token := string(secret.Data[clusterSecretAPIServerURL]), string(secret.Data[clusterSecretToken])
+	if serverURL == "" || token == "" {
+		return nil, fmt.Errorf("secret %s/%s holds neither %s nor %s and %s", namespace, secretName,
+			clusterSecretKubeConfig, clusterSecretAPIServerURL, clusterSecretToken)
+	}
+	return utils.GenerateKubeConfigFromToken(serverURL, token, secret.Data[clusterSecretCACert], 1)
+}
+
+// checkClusterClients evicts the clients of clusters which are idle or fail their health check, the
+// next request rebuilds them, picking up rotated credentials.
+func checkClusterClients() {
+	clusterClientsLock.Lock()
+	snapshot := make(map[string]*clusterClient, len(clusterClients))
+	for cluster, cached := range clusterClients {
+		snapshot[cluster] = cached
+	}
+	clusterClientsLock.Unlock()
+
+	for cluster, cached := range snapshot {
+		evict := false
+		if time.Since(cached.lastUsed) > clusterClientIdleTimeout {
+			evict = true
+		} else {
+			ctx, cancel := context.WithTimeout(context.Background(), clusterHealthCheckTimeout)
+			err := cached.clients.K8sClient.Discovery().RESTClient().Get().AbsPath("/healthz").Do(ctx).Error()
+			cancel()
+			if err != nil {
+				klog.Warningf("cluster %s is unhealthy, evicting its clients: %v", cluster, err)
+				evict = true
+			}
+		}
+		if evict {
+			clusterClientsLock.Lock()
+			if clusterClients[cluster] == cached {
+				delete(clusterClients, cluster)
+			}
+			clusterClientsLock.Unlock()
+		}
+	}
+}
@@ -3,17 +3,23 @@ package k8s
 import (
 	"context"
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 )
 
 type ConfigmapInterface interface {
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) (*v1.ConfigMapList, error)
 	Create(ctx context.Context, confMap *v1.ConfigMap) (*v1.ConfigMap, error)
 	Delete(ctx context.Context, namespace, name string) error
 	Get(ctx context.Context, namespace, name string) (*v1.ConfigMap, error)
 	Update(ctx context.Context, namespace, name string, configMap *v1.ConfigMap) (*v1.ConfigMap, error)
+	// DryRunUpdate returns the live ConfigMap and the one Update would store, without storing it.
+	DryRunUpdate(ctx context.Context, namespace, name string, configMap *v1.ConfigMap) (live, updated *v1.ConfigMap, err error)
 }
 
 type ConfigmapOperation struct {
@@ -26,6 +32,10 @@ func NewConfigmapOperation(client *kubernetes.Clientset) ConfigmapInterface {
 	}
 }
 
+func (c ConfigmapOperation) List(ctx context.Context, namespace string, opts metav1.ListOptions) (*v1.ConfigMapList, error) {
+	return c.clientSet.CoreV1().ConfigMaps(namespace).List(ctx, opts)
+}
+
 func (c ConfigmapOperation) Create(ctx context.Context, confMap *v1.ConfigMap) (*v1.ConfigMap, error) {
 	return c.clientSet.CoreV1().ConfigMaps(confMap.Namespace).Create(ctx, confMap, metav1.CreateOptions{})
 }
@@ -57,3 +67,42 @@ func (c ConfigmapOperation) Update(ctx context.Context, namespace, name string,
 	configMap.Name = name
 	return c.clientSet.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, metav1.UpdateOptions{})
 }
+
+func (c ConfigmapOperation) DryRunUpdate(ctx context.Context, namespace, name string, configMap *v1.ConfigMap) (*v1.ConfigMap, *v1.ConfigMap, error) {
+	live, err := c.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Get() configmap failed, err: %w", err)
+	}
+	configMap.Namespace = namespace
+	configMap.Name = name
+	updated, err := c.clientSet.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, metav1.UpdateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return nil, nil, err
+	}
+	return live, updated, nil
+}
+
+// ConfigmapFromFiles returns the ConfigMap holding files keyed by file name, as kubectl create configmap
+// --from-file does: UTF-8 files go to data, the others to binaryData.
+func ConfigmapFromFiles(namespace, name string, files map[string][]byte) (*v1.ConfigMap, error) {
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+	}
+	for key, content := range files {
+		if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+			return nil, fmt.Errorf("%q is not a valid key name for a ConfigMap: %s", key, strings.Join(errs, ", "))
+		}
+		if utf8.Valid(content) {
+			if configMap.Data == nil {
+				configMap.Data = make(map[string]string)
+			}
+			configMap.Data[key] = string(content)
+		} else {
+			if configMap.BinaryData == nil {
+				configMap.BinaryData = make(map[string][]byte)
+			}
+			configMap.BinaryData[key] = content
+		}
+	}
+	return configMap, nil
+}
@@ -0,0 +1,81 @@
+package k8s
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfigmapFromFiles(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}
+	tests := []struct {
+		name   string
+		files  map[string][]byte
+		data   map[string]string
+		binary map[string][]byte
+		err    string
+	}{
+		{name: "no files"},
+		{name: "text", files: map[string][]byte{"app.yaml": []byte("port: 80\n"), "empty.txt": {}},
+			data: map[string]string{"app.yaml": "port: 80\n", "empty.txt": ""}},
+		{name: "utf-8", files: map[string][]byte{"greeting.txt": []byte("你好\n")},
+			data: map[string]string{"greeting.txt": "你好\n"}},
+		{name: "binary", files: map[string][]byte{"logo.png": binary},
+			binary: map[string][]byte{"logo.png": binary}},
+		{name: "text and binary", files: map[string][]byte{"app.yaml": []byte("port: 80\n"), "logo.png": binary},
+			data: map[string]string{"app.yaml": "port: 80\n"}, binary: map[string][]byte{"logo.png": binary}},
+		{name: "invalid key", files: map[string][]byte{"conf/app.yaml": []byte("port: 80\n")},
+			err: `"conf/app.yaml" is not a valid key name for a ConfigMap`},
+		{name: "dot key", files: map[string][]byte{"..": []byte("x")},
+			err: `".." is not a valid key name for a ConfigMap`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configMap, err := ConfigmapFromFiles("shop", "web", tt.files)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(configMap.ObjectMeta, metav1.ObjectMeta{Namespace: "shop", Name: "web"}) {
+				t.Errorf("metadata = %+v", configMap.ObjectMeta)
+			}
+			if !reflect.DeepEqual(configMap.Data, tt.data) || !reflect.DeepEqual(configMap.BinaryData, tt.binary) {
+				t.Errorf("data = %q, binary data = %q, want %q, %q", configMap.Data, configMap.BinaryData, tt.data, tt.binary)
+			}
+		})
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	live := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", ResourceVersion: "3", UID: "web-uid"},
+		Data:       map[string]string{"mode": "prod", "port": "80"},
+	}
+	updated := live.DeepCopy()
+	updated.ResourceVersion = "4"
+	updated.Data["port"] = "8080"
+
+	diff, err := DiffObjects(live, updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- live\n+++ updated\n@@ -1,7 +1,7 @@\n apiVersion: v1\n data:\n   mode: prod\n-  port: \"80\"\n+  port: \"8080\"\n kind: ConfigMap\n metadata:\n   name: web\n"
+	if diff != want {
+		t.Errorf("diff =\n%s\nwant\n%s", diff, want)
+	}
+
+	// only what the cluster filled in differs.
+	updated = live.DeepCopy()
+	updated.ResourceVersion = "4"
+	if diff, err := DiffObjects(live, updated); err != nil || diff != "" {
+		t.Errorf("diff = %q, %v, want none", diff, err)
+	}
+}
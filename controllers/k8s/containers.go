@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ContainerUpdate changes one container of a pod template, fields left empty keep their value. Env
+// entries are merged by name, resources by key, probes are replaced whole.
+type ContainerUpdate struct {
+	Image          string                       `json:"image,omitempty"`
+	Env            []corev1.EnvVar              `json:"env,omitempty"`
+	Resources      *corev1.ResourceRequirements `json:"resources,omitempty"`
+	LivenessProbe  *corev1.Probe                `json:"livenessProbe,omitempty"`
+	ReadinessProbe *corev1.Probe                `json:"readinessProbe,omitempty"`
+	StartupProbe   *corev1.Probe                `json:"startupProbe,omitempty"`
+}
+
+// UpdateResult is the outcome of updating one of the objects picked by a label selector.
+type UpdateResult struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// ContainersPatch returns the strategic merge patch applying containers, keyed by container name, and
+// the pod template annotations to the pod template found at path of an object, e.g. "spec",
+// "template" for deployments. spec is the current pod spec, every container named must be in it.
+func ContainersPatch(spec *corev1.PodSpec, containers map[string]ContainerUpdate, annotations map[string]string, path ...string) ([]byte, error) {
+	lists := make(map[string]string, len(spec.InitContainers)+len(spec.Containers))
+	for _, container := range spec.InitContainers {
+		lists[container.Name] = "initContainers"
+	}
+	for _, container := range spec.Containers {
+		lists[container.Name] = "containers"
+	}
+
+	names := make([]string, 0, len(containers))
+	for name := range containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	podSpec := make(map[string]interface{})
+	for _, name := range names {
+		list, ok := lists[name]
+		if !ok {
+			return nil, fmt.Errorf("no container named %s", name)
+		}
+		update := containers[name]
+		entry := map[string]interface{}{"name": name}
+		if update.Image != "" {
+			entry["image"] = update.Image
+		}
+		if len(update.Env) > 0 {
+			entry["env"] = update.Env
+		}
+		if update.Resources != nil {
+			entry["resources"] = update.Resources
+		}
+		for field, probe := range map[string]*corev1.Probe{
+			"livenessProbe":  update.LivenessProbe,
+			"readinessProbe": update.ReadinessProbe,
+			"startupProbe":   update.StartupProbe,
+		} {
+			if probe == nil {
+				continue
+			}
+			replaced, err := replaceDirective(probe)
+			if err != nil {
+				return nil, err
+			}
+			entry[field] = replaced
+		}
+		entries, _ := podSpec[list].([]interface{})
+		podSpec[list] = append(entries, entry)
+	}
+
+	var patch interface{} = map[string]interface{}{"spec": podSpec}
+	if len(annotations) > 0 {
+		patch.(map[string]interface{})["metadata"] = map[string]interface{}{"annotations": annotations}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		patch = map[string]interface{}{path[i]: patch}
+	}
+	return json.Marshal(patch)
+}
+
+// replaceDirective turns v into a patch that replaces the field instead of merging into it.
+func replaceDirective(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m["$patch"] = "replace"
+	return m, nil
+}
+
+// ResolveContainers returns the container updates of a request, containers when given, otherwise the
+// image of the first container of spec.
+func ResolveContainers(spec *corev1.PodSpec, image string, containers map[string]ContainerUpdate) (map[string]ContainerUpdate, error) {
+	if len(containers) > 0 || image == "" {
+		return containers, nil
+	}
+	if len(spec.Containers) == 0 {
+		return nil, errors.New("no container to update")
+	}
+	return map[string]ContainerUpdate{spec.Containers[0].Name: {Image: image}}, nil
+}
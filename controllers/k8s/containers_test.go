@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+func newContainersSpec() *corev1.PodSpec {
+	return &corev1.PodSpec{
+		InitContainers: []corev1.Container{{Name: "migrate", Image: "migrate:1"}},
+		Containers: []corev1.Container{
+			{
+				Name:  "web",
+				Image: "web:1",
+				Env:   []corev1.EnvVar{{Name: "MODE", Value: "prod"}, {Name: "LEVEL", Value: "info"}},
+				Resources: corev1.ResourceRequirements{Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    resource.MustParse("1"),
+					corev1.ResourceMemory: resource.MustParse("1Gi"),
+				}},
+				LivenessProbe: &corev1.Probe{
+					ProbeHandler:     corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz"}},
+					FailureThreshold: 5,
+				},
+			},
+			{Name: "sidecar", Image: "envoy:1"},
+		},
+	}
+}
+
+func TestContainersPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		containers  map[string]ContainerUpdate
+		annotations map[string]string
+		path        []string
+		want        string
+		err         bool
+	}{
+		{name: "image", containers: map[string]ContainerUpdate{"web": {Image: "web:2"}},
+			want: `{"spec":{"containers":[{"image":"web:2","name":"web"}]}}`},
+		{name: "init and regular containers", containers: map[string]ContainerUpdate{
+			"sidecar": {Image: "envoy:2"}, "migrate": {Image: "migrate:2"}, "web": {Image: "web:2"}},
+			want: `{"spec":{"containers":[{"image":"envoy:2","name":"sidecar"},{"image":"web:2","name":"web"}],` +
+				`"initContainers":[{"image":"migrate:2","name":"migrate"}]}}`},
+		{name: "env and resources", containers: map[string]ContainerUpdate{"web": {
+			Env:       []corev1.EnvVar{{Name: "LEVEL", Value: "debug"}},
+			Resources: &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+		}}, want: `{"spec":{"containers":[{"env":[{"name":"LEVEL","value":"debug"}],"name":"web","resources":{"limits":{"cpu":"2"}}}]}}`},
+		{name: "probe", containers: map[string]ContainerUpdate{"web": {
+			ReadinessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}},
+		}}, want: `{"spec":{"containers":[{"name":"web","readinessProbe":{"$patch":"replace","exec":{"command":["true"]}}}]}}`},
+		{name: "annotations and path", containers: map[string]ContainerUpdate{"web": {Image: "web:2"}},
+			annotations: map[string]string{"restartedAt": "1"}, path: []string{"spec", "template"},
+			want: `{"spec":{"template":{"metadata":{"annotations":{"restartedAt":"1"}},"spec":{"containers":[{"image":"web:2","name":"web"}]}}}}`},
+		{name: "nothing to change", want: `{"spec":{}}`},
+		{name: "unknown container", containers: map[string]ContainerUpdate{"api": {Image: "api:2"}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := ContainersPatch(newContainersSpec(), tt.containers, tt.annotations, tt.path...)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("patch = %s, want an error", patch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(patch) != tt.want {
+				t.Errorf("patch = %s\nwant %s", patch, tt.want)
+			}
+		})
+	}
+}
+
+// TestContainersPatchApply checks the patches against the strategic merge the apiserver does: named
+// containers change, their other fields and the other containers are kept.
+func TestContainersPatchApply(t *testing.T) {
+	spec := newContainersSpec()
+	patch, err := ContainersPatch(spec, map[string]ContainerUpdate{"web": {
+		Image:         "web:2",
+		Env:           []corev1.EnvVar{{Name: "LEVEL", Value: "debug"}, {Name: "TRACE", Value: "on"}},
+		Resources:     &corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2")}},
+		LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/livez"}}},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := json.Marshal(corev1.Pod{Spec: *spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	patched, err := strategicpatch.StrategicMergePatch(original, patch, corev1.Pod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pod corev1.Pod
+	if err := json.Unmarshal(patched, &pod); err != nil {
+		t.Fatal(err)
+	}
+
+	want := newContainersSpec()
+	web := &want.Containers[0]
+	web.Image = "web:2"
+	web.Env = []corev1.EnvVar{{Name: "MODE", Value: "prod"}, {Name: "LEVEL", Value: "debug"}, {Name: "TRACE", Value: "on"}}
+	web.Resources.Limits[corev1.ResourceCPU] = resource.MustParse("2")
+	// probes are replaced whole, the failure threshold is not kept.
+	web.LivenessProbe = &corev1.Probe{ProbeHandler: corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/livez"}}}
+	if !reflect.DeepEqual(pod.Spec.InitContainers, want.InitContainers) || !reflect.DeepEqual(pod.Spec.Containers[1], want.Containers[1]) {
+		t.Errorf("other containers changed: %+v", pod.Spec)
+	}
+	got := pod.Spec.Containers[0]
+	if got.Image != web.Image || !reflect.DeepEqual(got.Env, web.Env) || !reflect.DeepEqual(got.LivenessProbe, web.LivenessProbe) ||
+		!got.Resources.Limits.Cpu().Equal(resource.MustParse("2")) || !got.Resources.Limits.Memory().Equal(resource.MustParse("1Gi")) {
+		t.Errorf("web = %+v\nwant %+v", got, *web)
+	}
+}
+
+func TestResolveContainers(t *testing.T) {
+	containers := map[string]ContainerUpdate{"sidecar": {Image: "envoy:2"}}
+	tests := []struct {
+		name       string
+		spec       *corev1.PodSpec
+		image      string
+		containers map[string]ContainerUpdate
+		want       map[string]ContainerUpdate
+		err        bool
+	}{
+		{name: "containers", spec: newContainersSpec(), image: "web:2", containers: containers, want: containers},
+		{name: "image of several containers", spec: newContainersSpec(), image: "web:2",
+			want: map[string]ContainerUpdate{"web": {Image: "web:2"}}},
+		{name: "image of one container", spec: &corev1.PodSpec{Containers: []corev1.Container{{Name: "api"}}}, image: "api:2",
+			want: map[string]ContainerUpdate{"api": {Image: "api:2"}}},
+		{name: "nothing", spec: newContainersSpec()},
+		{name: "no container", spec: &corev1.PodSpec{}, image: "web:2", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveContainers(tt.spec, tt.image, tt.containers)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("containers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"context"
 	"errors"
 
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/dynamic"
 )
 
@@ -14,6 +16,8 @@ type CRDInterface interface {
 	Create(ctx context.Context, gvk schema.GroupVersionResource, data map[string]interface{}) (*unstructured.Unstructured, error)
 	Delete(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string) error
 	Get(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string) (*unstructured.Unstructured, error)
+	List(ctx context.Context, gvk schema.GroupVersionResource, namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Patch(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error)
 	Update(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string, data map[string]interface{}) (*unstructured.Unstructured, error)
 }
 
@@ -29,13 +33,18 @@ func (o *CRDOperation) Get(ctx context.Context, gvk schema.GroupVersionResource,
 	return o.dyn.Resource(gvk).Namespace(namespace).Get(ctx, name, metav1.GetOptions{})
 }
 
+func (o *CRDOperation) List(ctx context.Context, gvk schema.GroupVersionResource, namespace string, opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	return o.dyn.Resource(gvk).Namespace(namespace).List(ctx, opts)
+}
+
 func (o *CRDOperation) Create(ctx context.Context, gvk schema.GroupVersionResource, data map[string]interface{}) (*unstructured.Unstructured, error) {
 	metadata, ok := data["metadata"].(map[string]interface{})
 	if !ok {
 		return nil, errors.New("converting data metadata to map failed")
 	}
+	// cluster scoped resources have no namespace
 	namespace, ok := metadata["namespace"].(string)
-	if !ok {
+	if !ok && metadata["namespace"] != nil {
 		return nil, errors.New("converting data namespace to string failed")
 	}
 	obj := unstructured.Unstructured{Object: data}
@@ -53,6 +62,33 @@ func (o *CRDOperation) Update(ctx context.Context, gvk schema.GroupVersionResour
 	return o.dyn.Resource(gvk).Namespace(namespace).Update(ctx, &obj, metav1.UpdateOptions{})
 }
 
+func (o *CRDOperation) Patch(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string, pt types.PatchType, data []byte) (*unstructured.Unstructured, error) {
+	return o.dyn.Resource(gvk).Namespace(namespace).Patch(ctx, name, pt, data, metav1.PatchOptions{})
+}
+
 func (o *CRDOperation) Delete(ctx context.Context, gvk schema.GroupVersionResource, namespace, name string) error {
 	return o.dyn.Resource(gvk).Namespace(namespace).Delete(ctx, name, metav1.DeleteOptions{})
 }
+
+// ResolveResource looks gvr up in the discovery of the apiserver and returns its kind and whether it is
+// namespaced. Discovery is refreshed once on a miss, the resource may come from a CRD created since it
+// was cached. Unknown resources give a NoResourceMatchError, see meta.IsNoMatchError.
+func (c *ClientManager) ResolveResource(gvr schema.GroupVersionResource) (schema.GroupVersionKind, bool, error) {
+	mapping, err := c.resourceMapping(gvr)
+	if meta.IsNoMatchError(err) {
+		c.Mapper.Reset()
+		mapping, err = c.resourceMapping(gvr)
+	}
+	if err != nil {
+		return schema.GroupVersionKind{}, false, err
+	}
+	return mapping.GroupVersionKind, mapping.Scope.Name() == meta.RESTScopeNameNamespace, nil
+}
+
+func (c *ClientManager) resourceMapping(gvr schema.GroupVersionResource) (*meta.RESTMapping, error) {
+	gvk, err := c.Mapper.KindFor(gvr)
+	if err != nil {
+		return nil, err
+	}
+	return c.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+}
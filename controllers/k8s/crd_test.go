@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/restmapper"
+)
+
+func TestResolveResource(t *testing.T) {
+	discovery := fake.NewSimpleClientset().Discovery().(*fakediscovery.FakeDiscovery)
+	discovery.Resources = []*metav1.APIResourceList{{
+		GroupVersion: "v1",
+		APIResources: []metav1.APIResource{
+			{Name: "configmaps", Kind: "ConfigMap", Namespaced: true, Verbs: metav1.Verbs{"get"}},
+			{Name: "nodes", Kind: "Node", Verbs: metav1.Verbs{"get"}},
+		},
+	}}
+	c := &ClientManager{Mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discovery))}
+
+	tests := []struct {
+		gvr        schema.GroupVersionResource
+		kind       string
+		namespaced bool
+	}{
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, kind: "ConfigMap", namespaced: true},
+		{gvr: schema.GroupVersionResource{Version: "v1", Resource: "nodes"}, kind: "Node"},
+	}
+	for _, tt := range tests {
+		gvk, namespaced, err := c.ResolveResource(tt.gvr)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.gvr, err)
+		}
+		if gvk.Kind != tt.kind || namespaced != tt.namespaced {
+			t.Errorf("%v = %v, namespaced %v, want %s, namespaced %v", tt.gvr, gvk, namespaced, tt.kind, tt.namespaced)
+		}
+	}
+
+	widgets := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+	if _, _, err := c.ResolveResource(widgets); !meta.IsNoMatchError(err) {
+		t.Fatalf("err = %v, want a no match error", err)
+	}
+
+	// a CRD created after discovery was cached is found without restarting.
+	discovery.Resources = append(discovery.Resources, &metav1.APIResourceList{
+		GroupVersion: "example.com/v1",
+		APIResources: []metav1.APIResource{{Name: "widgets", Kind: "Widget", Namespaced: true, Verbs: metav1.Verbs{"get"}}},
+	})
+	gvk, namespaced, err := c.ResolveResource(widgets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gvk != (schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}) || !namespaced {
+		t.Errorf("widgets = %v, namespaced %v", gvk, namespaced)
+	}
+}
@@ -0,0 +1,231 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// CronJobKind is the kind of the CronJobs in both of their versions.
+var CronJobKind = schema.GroupKind{Group: batchv1.GroupName, Kind: "CronJob"}
+
+// InstantiateAnnotation tells the Jobs created by hand from a CronJob, as kubectl create job --from does.
+const InstantiateAnnotation = "cronjob.kubernetes.io/instantiate"
+
+// CronJobInterface serves the CronJobs of a cluster as batch/v1 ones, whatever version the apiserver
+// serves.
+type CronJobInterface interface {
+	// Version is the version of the CronJobs served by the apiserver.
+	Version() schema.GroupVersion
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) (*batchv1.CronJobList, error)
+	Get(ctx context.Context, namespace, name string) (*batchv1.CronJob, error)
+	Create(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.CronJob, error)
+	Update(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.CronJob, error)
+	Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte) (*batchv1.CronJob, error)
+	Delete(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error
+	// Served converts cronjob to the version served by the apiserver.
+	Served(cronjob *batchv1.CronJob) (runtime.Object, error)
+	// Trigger runs cronjob once now.
+	Trigger(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.Job, error)
+	Suspend(ctx context.Context, namespace, name string, suspend bool) (*batchv1.CronJob, error)
+}
+
+type CronJobOperation struct {
+	clientSet *kubernetes.Clientset
+	version   schema.GroupVersion
+}
+
+// NewCronJobOperation negotiates the version of the CronJobs through discovery: batch/v1 when the
+// apiserver serves it, batch/v1beta1, removed in Kubernetes 1.25, on older clusters.
+func NewCronJobOperation(c *ClientManager) (CronJobInterface, error) {
+	version, err := c.CronJobVersion()
+	if err != nil {
+		return nil, err
+	}
+	return CronJobOperation{
+		clientSet: c.K8sClient,
+		version:   version,
+	}, nil
+}
+
+// CronJobVersion returns the version of the CronJobs the apiserver serves, batch/v1 when it serves
+// several.
+func (c *ClientManager) CronJobVersion() (schema.GroupVersion, error) {
+	versions := []string{batchv1.SchemeGroupVersion.Version, batchv1beta1.SchemeGroupVersion.Version}
+	mapping, err := c.Mapper.RESTMapping(CronJobKind, versions...)
+	if meta.IsNoMatchError(err) {
+		c.Mapper.Reset()
+		mapping, err = c.Mapper.RESTMapping(CronJobKind, versions...)
+	}
+	if err != nil {
+		return schema.GroupVersion{}, err
+	}
+	return mapping.GroupVersionKind.GroupVersion(), nil
+}
+
+func (o CronJobOperation) beta() bool {
+	return o.version == batchv1beta1.SchemeGroupVersion
+}
+
+func (o CronJobOperation) Version() schema.GroupVersion {
+	return o.version
+}
+
+func (o CronJobOperation) List(ctx context.Context, namespace string, opts metav1.ListOptions) (*batchv1.CronJobList, error) {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(namespace).List(ctx, opts)
+	}
+	list, err := o.clientSet.BatchV1beta1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	converted := &batchv1.CronJobList{}
+	if err := convertCronJob(list, converted); err != nil {
+		return nil, err
+	}
+	converted.TypeMeta = metav1.TypeMeta{}
+	return converted, nil
+}
+
+func (o CronJobOperation) Get(ctx context.Context, namespace, name string) (*batchv1.CronJob, error) {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	cronjob, err := o.clientSet.BatchV1beta1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	return fromBeta(cronjob, err)
+}
+
+func (o CronJobOperation) Create(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.CronJob, error) {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(cronjob.Namespace).Create(ctx, cronjob, metav1.CreateOptions{})
+	}
+	beta, err := toBeta(cronjob)
+	if err != nil {
+		return nil, err
+	}
+	return fromBeta(o.clientSet.BatchV1beta1().CronJobs(cronjob.Namespace).Create(ctx, beta, metav1.CreateOptions{}))
+}
+
+func (o CronJobOperation) Update(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.CronJob, error) {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(cronjob.Namespace).Update(ctx, cronjob, metav1.UpdateOptions{})
+	}
+	beta, err := toBeta(cronjob)
+	if err != nil {
+		return nil, err
+	}
+	return fromBeta(o.clientSet.BatchV1beta1().CronJobs(cronjob.Namespace).Update(ctx, beta, metav1.UpdateOptions{}))
+}
+
+// Patch patches a CronJob, the fields of both versions have the same paths.
+func (o CronJobOperation) Patch(ctx context.Context, namespace, name string, pt types.PatchType, data []byte) (*batchv1.CronJob, error) {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(namespace).Patch(ctx, name, pt, data, metav1.PatchOptions{})
+	}
+	return fromBeta(o.clientSet.BatchV1beta1().CronJobs(namespace).Patch(ctx, name, pt, data, metav1.PatchOptions{}))
+}
+
+func (o CronJobOperation) Delete(ctx context.Context, namespace, name string, opts metav1.DeleteOptions) error {
+	if !o.beta() {
+		return o.clientSet.BatchV1().CronJobs(namespace).Delete(ctx, name, opts)
+	}
+	return o.clientSet.BatchV1beta1().CronJobs(namespace).Delete(ctx, name, opts)
+}
+
+func (o CronJobOperation) Served(cronjob *batchv1.CronJob) (runtime.Object, error) {
+	if !o.beta() {
+		return cronjob, nil
+	}
+	return toBeta(cronjob)
+}
+
+// Trigger creates a Job from the template of cronjob, owned by it like the scheduled ones.
+func (o CronJobOperation) Trigger(ctx context.Context, cronjob *batchv1.CronJob) (*batchv1.Job, error) {
+	template := cronjob.Spec.JobTemplate
+	annotations := make(map[string]string, len(template.Annotations)+1)
+	for k, v := range template.Annotations {
+		annotations[k] = v
+	}
+	annotations[InstantiateAnnotation] = "manual"
+
+	// the name of a Job goes in the labels of its pods, at most 63 characters
+	prefix := cronjob.Name
+	if len(prefix) > 50 {
+		prefix = prefix[:50]
+	}
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        prefix + "-manual-" + utilrand.String(5),
+			Namespace:   cronjob.Namespace,
+			Labels:      template.Labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronjob, o.version.WithKind(CronJobKind.Kind)),
+			},
+		},
+		Spec: template.Spec,
+	}
+	return o.clientSet.BatchV1().Jobs(cronjob.Namespace).Create(ctx, job, metav1.CreateOptions{})
+}
+
+func (o CronJobOperation) Suspend(ctx context.Context, namespace, name string, suspend bool) (*batchv1.CronJob, error) {
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{"suspend": suspend},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.Patch(ctx, namespace, name, types.MergePatchType, patch)
+}
+
+// OwnedBy keeps the Jobs of jobs controlled by the object uid, such as the scheduled and the triggered
+// Jobs of a CronJob.
+func OwnedBy(jobs *batchv1.JobList, uid types.UID) {
+	owned := jobs.Items[:0]
+	for _, job := range jobs.Items {
+		if owner := metav1.GetControllerOf(&job); owner != nil && owner.UID == uid {
+			owned = append(owned, job)
+		}
+	}
+	jobs.Items = owned
+}
+
+func toBeta(cronjob *batchv1.CronJob) (*batchv1beta1.CronJob, error) {
+	converted := &batchv1beta1.CronJob{}
+	if err := convertCronJob(cronjob, converted); err != nil {
+		return nil, err
+	}
+	converted.TypeMeta = metav1.TypeMeta{}
+	return converted, nil
+}
+
+func fromBeta(cronjob *batchv1beta1.CronJob, err error) (*batchv1.CronJob, error) {
+	if err != nil {
+		return nil, err
+	}
+	converted := &batchv1.CronJob{}
+	if err := convertCronJob(cronjob, converted); err != nil {
+		return nil, err
+	}
+	converted.TypeMeta = metav1.TypeMeta{}
+	return converted, nil
+}
+
+// convertCronJob converts between the versions of CronJobs and of their lists, which only differ in
+// their apiVersion.
+func convertCronJob(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
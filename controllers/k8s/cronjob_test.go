@@ -0,0 +1,116 @@
+package k8s
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestCronJob() *batchv1.CronJob {
+	suspend, history, deadline := true, int32(2), int64(60)
+	timeZone := "Asia/Shanghai"
+	lastSchedule := metav1.Unix(1672531200, 0)
+	return &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "shop",
+			Name:            "report",
+			UID:             "report-uid",
+			ResourceVersion: "11",
+			Labels:          map[string]string{"app": "report"},
+			Annotations:     map[string]string{"team": "shop"},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   "*/5 * * * *",
+			TimeZone:                   &timeZone,
+			StartingDeadlineSeconds:    &deadline,
+			ConcurrencyPolicy:          batchv1.ForbidConcurrent,
+			Suspend:                    &suspend,
+			SuccessfulJobsHistoryLimit: &history,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "report"}},
+				Spec: batchv1.JobSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyOnFailure,
+					Containers:    []corev1.Container{{Name: "report", Image: "report:1", Args: []string{"--daily"}}},
+				}}},
+			},
+		},
+		Status: batchv1.CronJobStatus{
+			Active:           []corev1.ObjectReference{{Kind: "Job", Namespace: "shop", Name: "report-1"}},
+			LastScheduleTime: &lastSchedule,
+		},
+	}
+}
+
+func TestCronJobBetaRoundTrip(t *testing.T) {
+	cronjob := newTestCronJob()
+	beta, err := toBeta(cronjob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if beta.TypeMeta != (metav1.TypeMeta{}) {
+		t.Errorf("type meta = %v, want it empty for the beta client to fill", beta.TypeMeta)
+	}
+	if beta.Name != "report" || beta.ResourceVersion != "11" || beta.Spec.Schedule != "*/5 * * * *" ||
+		beta.Spec.ConcurrencyPolicy != batchv1beta1.ForbidConcurrent || *beta.Spec.TimeZone != "Asia/Shanghai" ||
+		beta.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Image != "report:1" || len(beta.Status.Active) != 1 {
+		t.Errorf("beta = %+v", beta)
+	}
+
+	back, err := fromBeta(beta, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := newTestCronJob()
+	want.TypeMeta = metav1.TypeMeta{}
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("round trip = %+v\nwant %+v", back, want)
+	}
+}
+
+func TestFromBetaError(t *testing.T) {
+	failed := errors.New("not found")
+	if cronjob, err := fromBeta(nil, failed); cronjob != nil || err != failed {
+		t.Errorf("fromBeta = %v, %v, want the error of the beta client", cronjob, err)
+	}
+}
+
+func TestConvertCronJobList(t *testing.T) {
+	beta := &batchv1beta1.CronJobList{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1beta1", Kind: "CronJobList"},
+		ListMeta: metav1.ListMeta{ResourceVersion: "12", Continue: "next"},
+	}
+	for _, name := range []string{"report", "cleanup"} {
+		item, err := toBeta(newTestCronJob())
+		if err != nil {
+			t.Fatal(err)
+		}
+		item.Name = name
+		beta.Items = append(beta.Items, *item)
+	}
+
+	list := &batchv1.CronJobList{}
+	if err := convertCronJob(beta, list); err != nil {
+		t.Fatal(err)
+	}
+	if list.ResourceVersion != "12" || list.Continue != "next" || len(list.Items) != 2 {
+		t.Fatalf("list = %+v", list)
+	}
+	for i, name := range []string{"report", "cleanup"} {
+		want := newTestCronJob()
+		want.TypeMeta = metav1.TypeMeta{}
+		want.Name = name
+		if !reflect.DeepEqual(&list.Items[i], want) {
+			t.Errorf("item %d = %+v\nwant %+v", i, list.Items[i], want)
+		}
+	}
+
+	if err := convertCronJob(make(chan int), list); err == nil {
+		t.Errorf("an unencodable value was converted")
+	}
+}
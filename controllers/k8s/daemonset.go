@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+type DaemonSetInterface interface {
+	Get(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error)
+	Create(ctx context.Context, daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error)
+	Update(ctx context.Context, namespace, name string, daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error)
+	Delete(ctx context.Context, namespace, name string) error
+	Restart(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error)
+	// Pods returns the pods the DaemonSet controls.
+	Pods(ctx context.Context, daemonSet *appsv1.DaemonSet) (*corev1.PodList, error)
+}
+
+type DaemonSetOperation struct {
+	clientSet *kubernetes.Clientset
+}
+
+func NewDaemonSetOperation(client *kubernetes.Clientset) DaemonSetInterface {
+	return DaemonSetOperation{
+		clientSet: client,
+	}
+}
+
+func (o DaemonSetOperation) Get(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error) {
+	return o.clientSet.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o DaemonSetOperation) Create(ctx context.Context, daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	return o.clientSet.AppsV1().DaemonSets(daemonSet.Namespace).Create(ctx, daemonSet, metav1.CreateOptions{})
+}
+
+// Update replaces the DaemonSet namespace/name with daemonSet.
+func (o DaemonSetOperation) Update(ctx context.Context, namespace, name string, daemonSet *appsv1.DaemonSet) (*appsv1.DaemonSet, error) {
+	daemonSet.Namespace, daemonSet.Name = namespace, name
+	return o.clientSet.AppsV1().DaemonSets(namespace).Update(ctx, daemonSet, metav1.UpdateOptions{})
+}
+
+func (o DaemonSetOperation) Delete(ctx context.Context, namespace, name string) error {
+	return o.clientSet.AppsV1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (o DaemonSetOperation) Restart(ctx context.Context, namespace, name string) (*appsv1.DaemonSet, error) {
+	patch, err := restartPatch()
+	if err != nil {
+		return nil, err
+	}
+	return o.clientSet.AppsV1().DaemonSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+}
+
+func (o DaemonSetOperation) Pods(ctx context.Context, daemonSet *appsv1.DaemonSet) (*corev1.PodList, error) {
+	return controlledPods(ctx, o.clientSet, daemonSet, daemonSet.Spec.Selector)
+}
@@ -2,21 +2,58 @@ package k8s
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	RevisionAnnotation    = "deployment.kubernetes.io/revision"
+	ChangeCauseAnnotation = "kubernetes.io/change-cause"
+)
+
+// ErrRollbackSkipped is returned when the deployment already runs the revision to roll back to.
+var ErrRollbackSkipped = errors.New("skipped rollback")
+
+// DeploymentRevision is a revision of a deployment, kept as one of its ReplicaSets.
+type DeploymentRevision struct {
+	Revision          int64             `json:"revision"`
+	ReplicaSet        string            `json:"replicaSet"`
+	ChangeCause       string            `json:"changeCause,omitempty"`
+	Images            map[string]string `json:"images"`
+	ImageChanges      []ImageChange     `json:"imageChanges,omitempty"`
+	Replicas          int32             `json:"replicas"`
+	Current           bool              `json:"current"`
+	CreationTimestamp metav1.Time       `json:"creationTimestamp"`
+}
+
+// ImageChange is a container whose image differs from the revision before, From is empty for new containers.
+type ImageChange struct {
+	Container string `json:"container"`
+	From      string `json:"from,omitempty"`
+	To        string `json:"to"`
+}
+
 type DeploymentInterface interface {
 	Get(ctx context.Context, namespace, name string) (*appsv1.Deployment, error)
 	Create(ctx context.Context, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
 	Update(ctx context.Context, namespace, name string, deployment *appsv1.Deployment) (*appsv1.Deployment, error)
+	History(ctx context.Context, namespace, name string) ([]DeploymentRevision, error)
+	Rollback(ctx context.Context, namespace, name string, toRevision int64) (*appsv1.Deployment, error)
+	PatchContainers(ctx context.Context, deployment *appsv1.Deployment, containers map[string]ContainerUpdate, annotations map[string]string) (*appsv1.Deployment, error)
 }
 
 type DeploymentOperation struct {
-	clientSet *kubernetes.Clientset
+	clientSet kubernetes.Interface
 }
 
 func NewDeploymentOperation(client *kubernetes.Clientset) DeploymentInterface {
@@ -45,3 +82,169 @@ func (o DeploymentOperation) Update(ctx context.Context, namespace, name string,
 	}
 	return deployment, nil
 }
+
+// History returns the revisions of a deployment, oldest first.
+func (o DeploymentOperation) History(ctx context.Context, namespace, name string) ([]DeploymentRevision, error) {
+	deployment, err := o.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	replicaSets, err := o.replicaSets(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]DeploymentRevision, 0, len(replicaSets))
+	var previous map[string]string
+	for _, rs := range replicaSets {
+		revision := DeploymentRevision{
+			Revision:          revisionOf(rs),
+			ReplicaSet:        rs.Name,
+			ChangeCause:       rs.Annotations[ChangeCauseAnnotation],
+			Images:            podImages(&rs.Spec.Template.Spec),
+			Replicas:          rs.Status.Replicas,
+			Current:           rs.Annotations[RevisionAnnotation] == deployment.Annotations[RevisionAnnotation],
+			CreationTimestamp: rs.CreationTimestamp,
+		}
+		if previous != nil {
+			for container, image := range revision.Images {
+				if previous[container] != image {
+					revision.ImageChanges = append(revision.ImageChanges, ImageChange{Container: container, From: previous[container], To: image})
+				}
+			}
+			sort.Slice(revision.ImageChanges, func(i, j int) bool {
+				return revision.ImageChanges[i].Container < revision.ImageChanges[j].Container
+			})
+		}
+		previous = revision.Images
+		revisions = append(revisions, revision)
+	}
+	return revisions, nil
+}
+
+// PatchContainers updates containers of deployment, and its pod template annotations, with a strategic
+// merge patch.
+func (o DeploymentOperation) PatchContainers(ctx context.Context, deployment *appsv1.Deployment, containers map[string]ContainerUpdate, annotations map[string]string) (*appsv1.Deployment, error) {
+	patch, err := ContainersPatch(&deployment.Spec.Template.Spec, containers, annotations, "spec", "template")
+	if err != nil {
+		return nil, err
+	}
+	return o.clientSet.AppsV1().Deployments(deployment.Namespace).Patch(ctx, deployment.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+}
+
+// rollbackSkippedAnnotations are the annotations of a ReplicaSet not copied back to its deployment on
+// a rollback, as kubectl rollout undo does.
+var rollbackSkippedAnnotations = map[string]bool{
+	corev1.LastAppliedConfigAnnotation:          true,
+	RevisionAnnotation:                          true,
+	"deployment.kubernetes.io/revision-history": true,
+	"deployment.kubernetes.io/desired-replicas": true,
+	"deployment.kubernetes.io/max-replicas":     true,
+	appsv1.DeprecatedRollbackTo:                 true,
+}
+
+// Rollback rolls a deployment back to the pod template of toRevision, or of the revision before the
+// current one when toRevision is 0, like kubectl rollout undo.
+func (o DeploymentOperation) Rollback(ctx context.Context, namespace, name string, toRevision int64) (*appsv1.Deployment, error) {
+	deployment, err := o.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if deployment.Spec.Paused {
+		return nil, errors.New("can't rollback paused deployment (run rollout resume first)")
+	}
+	replicaSets, err := o.replicaSets(ctx, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *appsv1.ReplicaSet
+	if toRevision == 0 {
+		// the one before the newest
+		if len(replicaSets) < 2 {
+			return nil, errors.New("no rollout history found")
+		}
+		target = replicaSets[len(replicaSets)-2]
+	} else {
+		for _, rs := range replicaSets {
+			if revisionOf(rs) == toRevision {
+				target = rs
+				break
+			}
+		}
+		if target == nil {
+			return nil, fmt.Errorf("unable to find specified revision %d in history", toRevision)
+		}
+	}
+	if equalIgnoreHash(&target.Spec.Template, &deployment.Spec.Template) {
+		return deployment, fmt.Errorf("%w (current template already matches revision %d)", ErrRollbackSkipped, revisionOf(target))
+	}
+
+	template := target.Spec.Template.DeepCopy()
+	delete(template.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	annotations := make(map[string]string, len(deployment.Annotations))
+	for k, v := range deployment.Annotations {
+		annotations[k] = v
+	}
+	for k, v := range target.Annotations {
+		if !rollbackSkippedAnnotations[k] {
+			annotations[k] = v
+		}
+	}
+	patch, err := json.Marshal([]interface{}{
+		map[string]interface{}{"op": "replace", "path": "/spec/template", "value": template},
+		// add replaces the annotations as well as creating them on a deployment without any.
+		map[string]interface{}{"op": "add", "path": "/metadata/annotations", "value": annotations},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.clientSet.AppsV1().Deployments(namespace).Patch(ctx, name, types.JSONPatchType, patch, metav1.PatchOptions{})
+}
+
+// replicaSets returns the ReplicaSets controlled by deployment, oldest revision first.
+func (o DeploymentOperation) replicaSets(ctx context.Context, deployment *appsv1.Deployment) ([]*appsv1.ReplicaSet, error) {
+	selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	list, err := o.clientSet.AppsV1().ReplicaSets(deployment.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	var replicaSets []*appsv1.ReplicaSet
+	for i := range list.Items {
+		rs := &list.Items[i]
+		if owner := metav1.GetControllerOf(rs); owner != nil && owner.UID == deployment.UID {
+			replicaSets = append(replicaSets, rs)
+		}
+	}
+	sort.Slice(replicaSets, func(i, j int) bool {
+		return revisionOf(replicaSets[i]) < revisionOf(replicaSets[j])
+	})
+	return replicaSets, nil
+}
+
+func revisionOf(rs *appsv1.ReplicaSet) int64 {
+	revision, _ := strconv.ParseInt(rs.Annotations[RevisionAnnotation], 10, 64)
+	return revision
+}
+
+func podImages(spec *corev1.PodSpec) map[string]string {
+	images := make(map[string]string, len(spec.InitContainers)+len(spec.Containers))
+	for _, container := range spec.InitContainers {
+		images[container.Name] = container.Image
+	}
+	for _, container := range spec.Containers {
+		images[container.Name] = container.Image
+	}
+	return images
+}
+
+// equalIgnoreHash compares two pod templates regardless of the hash label the deployment controller adds.
+func equalIgnoreHash(a, b *corev1.PodTemplateSpec) bool {
+	a, b = a.DeepCopy(), b.DeepCopy()
+	delete(a.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	delete(b.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return apiequality.Semantic.DeepEqual(a, b)
+}
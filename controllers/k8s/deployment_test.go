@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newRollbackTemplate(image string) corev1.PodTemplateSpec {
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Image: image}}},
+	}
+}
+
+func newRollbackReplicaSet(deployment *appsv1.Deployment, revision int64, image string, annotations map[string]string) *appsv1.ReplicaSet {
+	rs := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   deployment.Namespace,
+			Name:        deployment.Name + "-" + strconv.FormatInt(revision, 10),
+			Labels:      map[string]string{"app": "web"},
+			Annotations: map[string]string{RevisionAnnotation: strconv.FormatInt(revision, 10)},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(deployment,
+				appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: newRollbackTemplate(image)},
+	}
+	rs.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey] = strconv.FormatInt(revision, 10)
+	for k, v := range annotations {
+		rs.Annotations[k] = v
+	}
+	return rs
+}
+
+// strictJSONPatch fails JSON patches replacing the annotations of a deployment without any, as RFC 6902
+// requires of replace and the object tracker of the fake clientset doesn't check.
+func strictJSONPatch(deployment *appsv1.Deployment) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patch := action.(k8stesting.PatchAction)
+		if patch.GetPatchType() != types.JSONPatchType || len(deployment.Annotations) > 0 {
+			return false, nil, nil
+		}
+		var ops []struct{ Op, Path string }
+		if err := json.Unmarshal(patch.GetPatch(), &ops); err != nil {
+			return true, nil, err
+		}
+		for _, op := range ops {
+			if op.Op == "replace" && op.Path == "/metadata/annotations" {
+				return true, nil, fmt.Errorf("replace operation does not apply: doc is missing path: %s", op.Path)
+			}
+		}
+		return false, nil, nil
+	}
+}
+
+func TestDeploymentRollback(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		toRevision  int64
+		image       string
+		want        map[string]string
+		err         error
+	}{
+		{name: "deployment without annotations", image: "web:1",
+			want: map[string]string{ChangeCauseAnnotation: "deploy web:1"}},
+		{name: "deployment with annotations", annotations: map[string]string{RevisionAnnotation: "2", "team": "shop"}, image: "web:1",
+			want: map[string]string{RevisionAnnotation: "2", "team": "shop", ChangeCauseAnnotation: "deploy web:1"}},
+		{name: "to a revision", toRevision: 1, image: "web:1",
+			want: map[string]string{ChangeCauseAnnotation: "deploy web:1"}},
+		{name: "to the current revision", toRevision: 2, image: "web:2", err: ErrRollbackSkipped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web", UID: types.UID("web-uid"), Annotations: tt.annotations},
+				Spec: appsv1.DeploymentSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					Template: newRollbackTemplate("web:2"),
+				},
+			}
+			objects := []runtime.Object{
+				deployment,
+				newRollbackReplicaSet(deployment, 1, "web:1", map[string]string{ChangeCauseAnnotation: "deploy web:1"}),
+				newRollbackReplicaSet(deployment, 2, "web:2", nil),
+			}
+			client := fake.NewSimpleClientset(objects...)
+			client.PrependReactor("patch", "deployments", strictJSONPatch(deployment))
+			o := DeploymentOperation{clientSet: client}
+
+			rolled, err := o.Rollback(context.Background(), "shop", "web", tt.toRevision)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if image := rolled.Spec.Template.Spec.Containers[0].Image; image != tt.image {
+				t.Errorf("image = %s, want %s", image, tt.image)
+			}
+			if _, ok := rolled.Spec.Template.Labels[appsv1.DefaultDeploymentUniqueLabelKey]; ok {
+				t.Errorf("the hash label of the ReplicaSet was copied to the deployment")
+			}
+			if len(rolled.Annotations) != len(tt.want) {
+				t.Errorf("annotations = %v, want %v", rolled.Annotations, tt.want)
+			}
+			for k, v := range tt.want {
+				if rolled.Annotations[k] != v {
+					t.Errorf("annotations = %v, want %v", rolled.Annotations, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package k8s
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"path"
+	"time"
+
+	"github.com/lmxia/gaia/pkg/common"
+	gaiascheme "github.com/lmxia/gaia/pkg/generated/clientset/versioned/scheme"
+	"github.com/lmxia/nightwatcher/utils"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+// exportScheme knows the kinds of the typed objects the handlers export, they come back from the
+// apiserver without one.
+var exportScheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(exportScheme))
+	utilruntime.Must(gaiascheme.AddToScheme(exportScheme))
+}
+
+// ExportParams are the query parameters of the get endpoints that export their object. With a format
+// the object is returned as a bare manifest, clean strips what the cluster filled in.
+type ExportParams struct {
+	Format string `form:"format" binding:"omitempty,oneof=json yaml"`
+	Clean  bool   `form:"clean"`
+}
+
+// Exporting reports whether the object has to be exported rather than returned as is.
+func (p *ExportParams) Exporting() bool {
+	return p.Format != "" || p.Clean
+}
+
+// Export converts obj to unstructured with its apiVersion and kind set, cleaned if asked to.
+func (p *ExportParams) Export(obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvks, _, err := exportScheme.ObjectKinds(obj)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	exported := &unstructured.Unstructured{Object: content}
+	exported.SetGroupVersionKind(gvks[0])
+	if p.Clean {
+		CleanObject(exported)
+	}
+	return exported, nil
+}
+
+// Encode encodes obj in the requested format, YAML when none.
+func (p *ExportParams) Encode(obj *unstructured.Unstructured) ([]byte, error) {
+	if p.Format == "json" {
+		return json.MarshalIndent(obj.Object, "", "  ")
+	}
+	return yaml.Marshal(obj.Object)
+}
+
+// ContentType is the content type of the encoded objects.
+func (p *ExportParams) ContentType() string {
+	if p.Format == "json" {
+		return "application/json"
+	}
+	return "application/yaml"
+}
+
+// Ext is the file extension of the encoded objects.
+func (p *ExportParams) Ext() string {
+	if p.Format == "json" {
+		return ".json"
+	}
+	return ".yaml"
+}
+
+// DiffObjects returns the unified diff of the YAML manifests of live and updated, without what the
+// cluster filled in. Manifests too large to compare give utils.ErrDiffTooLarge.
+func DiffObjects(live, updated runtime.Object) (string, error) {
+	p := &ExportParams{Format: "yaml", Clean: true}
+	var manifests [2]string
+	for i, obj := range []runtime.Object{live, updated} {
+		exported, err := p.Export(obj)
+		if err != nil {
+			return "", err
+		}
+		data, err := p.Encode(exported)
+		if err != nil {
+			return "", err
+		}
+		manifests[i] = string(data)
+	}
+	return utils.UnifiedDiff("live", "updated", manifests[0], manifests[1])
+}
+
+// clusterAnnotations are set by the cluster and mean nothing elsewhere.
+var clusterAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+}
+
+// CleanObject strips what the cluster filled in from obj, so that it can be applied elsewhere.
+func CleanObject(obj *unstructured.Unstructured) {
+	for _, field := range []string{"managedFields", "resourceVersion", "uid", "selfLink", "generation", "creationTimestamp", "ownerReferences"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	unstructured.RemoveNestedField(obj.Object, "status")
+
+	annotations := obj.GetAnnotations()
+	for _, key := range clusterAnnotations {
+		delete(annotations, key)
+	}
+	if len(annotations) == 0 {
+		annotations = nil
+	}
+	obj.SetAnnotations(annotations)
+
+	switch obj.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "Service"}:
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+		unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+	case schema.GroupKind{Kind: "Pod"}:
+		unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+	case schema.GroupKind{Group: "batch", Kind: "Job"}:
+		// generated from the uid of the job
+		unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+		for _, label := range JobControllerLabels {
+			unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", label)
+		}
+	}
+}
+
+// exportedResources are the resources of a namespace a bulk export gathers.
+var exportedResources = []schema.GroupResource{
+	{Resource: "configmaps"},
+	{Resource: "services"},
+	{Resource: "persistentvolumeclaims"},
+	{Group: "apps", Resource: "deployments"},
+	{Group: "apps", Resource: "statefulsets"},
+	{Group: "apps", Resource: "daemonsets"},
+	{Group: "batch", Resource: "jobs"},
+	{Group: "batch", Resource: "cronjobs"},
+	{Group: "networking.k8s.io", Resource: "ingresses"},
+}
+
+// ExportNamespace gathers the resources of namespace, all of them when empty, matching label. Objects controlled by another
+// one, and the ones the cluster creates in every namespace, are left out: applying their owners brings
+// them back. Resources the apiserver doesn't serve are skipped.
+func (c *ClientManager) ExportNamespace(ctx context.Context, namespace, label string, p *ExportParams) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	for _, gr := range exportedResources {
+		gvr, err := c.Mapper.ResourceFor(gr.WithVersion(""))
+		if meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		gvk, err := c.Mapper.KindFor(gvr)
+		if err != nil {
+			return nil, err
+		}
+		list, err := c.K8sDynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: label})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			obj.SetGroupVersionKind(gvk)
+			if metav1.GetControllerOf(obj) != nil || isClusterCreated(obj) {
+				continue
+			}
+			if p.Clean {
+				CleanObject(obj)
+			}
+			objs = append(objs, obj)
+		}
+	}
+	return objs, nil
+}
+
+// ExportDescription gathers a Description and the resources gaia deployed for it in this cluster.
+func (c *ClientManager) ExportDescription(ctx context.Context, namespace, name string, p *ExportParams) ([]*unstructured.Unstructured, error) {
+	description, err := c.Gaiaclient.AppsV1alpha1().Descriptions(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	exported, err := p.Export(description)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := c.ExportNamespace(ctx, metav1.NamespaceAll, labels.Set{common.GaiaDescriptionLabel: name}.String(), p)
+	if err != nil {
+		return nil, err
+	}
+	return append([]*unstructured.Unstructured{exported}, objs...), nil
+}
+
+func isClusterCreated(obj *unstructured.Unstructured) bool {
+	switch {
+	case obj.GetKind() == "ConfigMap" && obj.GetName() == "kube-root-ca.crt":
+		return true
+	case obj.GetKind() == "Service" && obj.GetNamespace() == metav1.NamespaceDefault && obj.GetName() == "kubernetes":
+		return true
+	}
+	return false
+}
+
+// Archive formats of the bulk exports.
+const (
+	ArchiveTar = "tar"
+	ArchiveZip = "zip"
+)
+
+// ArchiveParams are the query parameters of the bulk exports.
+type ArchiveParams struct {
+	ExportParams
+	// gzipped tar when empty
+	Archive string `form:"archive" binding:"omitempty,oneof=tar zip"`
+}
+
+// Pack archives objs, one <namespace>/<kind>/<name> file per object, and returns the archive with its
+// file extension and content type.
+func (p *ArchiveParams) Pack(objs []*unstructured.Unstructured) ([]byte, string, string, error) {
+	var buf bytes.Buffer
+	now := time.Now()
+	if p.Archive == ArchiveZip {
+		zw := zip.NewWriter(&buf)
+		for _, obj := range objs {
+			data, err := p.Encode(obj)
+			if err != nil {
+				return nil, "", "", err
+			}
+			f, err := zw.CreateHeader(&zip.FileHeader{Name: p.archivePath(obj), Method: zip.Deflate, Modified: now})
+			if err != nil {
+				return nil, "", "", err
+			}
+			if _, err := f.Write(data); err != nil {
+				return nil, "", "", err
+			}
+		}
+		if err := zw.Close(); err != nil {
+			return nil, "", "", err
+		}
+		return buf.Bytes(), ".zip", "application/zip", nil
+	}
+
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, obj := range objs {
+		data, err := p.Encode(obj)
+		if err != nil {
+			return nil, "", "", err
+		}
+		header := &tar.Header{Name: p.archivePath(obj), Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, "", "", err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, "", "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, "", "", err
+	}
+	return buf.Bytes(), ".tar.gz", "application/gzip", nil
+}
+
+func (p *ArchiveParams) archivePath(obj *unstructured.Unstructured) string {
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	return path.Join(namespace, obj.GetKind(), obj.GetName()+p.Ext())
+}
@@ -0,0 +1,192 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// BackendHealthAnnotation is set on the Ingresses served by the API, never stored, with the health of
+// their backends as JSON.
+const BackendHealthAnnotation = "nightwatcher.io/backend-health"
+
+// BackendHealth is the health of an Ingress backend, the endpoints of its service port.
+type BackendHealth struct {
+	Host     string `json:"host,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Service  string `json:"service"`
+	Port     string `json:"port"`
+	Ready    int    `json:"ready"`
+	NotReady int    `json:"notReady"`
+	Error    string `json:"error,omitempty"`
+}
+
+type IngressInterface interface {
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) (*networkingv1.IngressList, error)
+	Get(ctx context.Context, namespace, name string) (*networkingv1.Ingress, error)
+	Create(ctx context.Context, ingress *networkingv1.Ingress) (*networkingv1.Ingress, error)
+	Update(ctx context.Context, namespace, name string, ingress *networkingv1.Ingress) (*networkingv1.Ingress, error)
+	Delete(ctx context.Context, namespace, name string) error
+	// AnnotateBackendHealth sets the BackendHealthAnnotation of ingresses. Only the namespaces of ingresses
+	// are looked up, a failed lookup is the error of the backends it leaves unknown.
+	AnnotateBackendHealth(ctx context.Context, ingresses []networkingv1.Ingress)
+}
+
+type IngressOperation struct {
+	clientSet kubernetes.Interface
+}
+
+func NewIngressOperation(client *kubernetes.Clientset) IngressInterface {
+	return IngressOperation{
+		clientSet: client,
+	}
+}
+
+func (o IngressOperation) List(ctx context.Context, namespace string, opts metav1.ListOptions) (*networkingv1.IngressList, error) {
+	return o.clientSet.NetworkingV1().Ingresses(namespace).List(ctx, opts)
+}
+
+func (o IngressOperation) Get(ctx context.Context, namespace, name string) (*networkingv1.Ingress, error) {
+	return o.clientSet.NetworkingV1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o IngressOperation) Create(ctx context.Context, ingress *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	return o.clientSet.NetworkingV1().Ingresses(ingress.Namespace).Create(ctx, ingress, metav1.CreateOptions{})
+}
+
+// Update replaces the Ingress namespace/name with ingress.
+func (o IngressOperation) Update(ctx context.Context, namespace, name string, ingress *networkingv1.Ingress) (*networkingv1.Ingress, error) {
+	ingress.Namespace, ingress.Name = namespace, name
+	// read back from the API, it is not the Ingress's own
+	delete(ingress.Annotations, BackendHealthAnnotation)
+	if ingress.ResourceVersion == "" {
+		current, err := o.Get(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		ingress.ResourceVersion = current.ResourceVersion
+	}
+	return o.clientSet.NetworkingV1().Ingresses(namespace).Update(ctx, ingress, metav1.UpdateOptions{})
+}
+
+func (o IngressOperation) Delete(ctx context.Context, namespace, name string) error {
+	return o.clientSet.NetworkingV1().Ingresses(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (o IngressOperation) AnnotateBackendHealth(ctx context.Context, ingresses []networkingv1.Ingress) {
+	lookups := map[string]*backendLookup{}
+	for i := range ingresses {
+		ingress := &ingresses[i]
+		lookup, ok := lookups[ingress.Namespace]
+		if !ok {
+			lookup = o.lookupBackends(ctx, ingress.Namespace)
+			lookups[ingress.Namespace] = lookup
+		}
+
+		var health []BackendHealth
+		check := func(host, path string, backend *networkingv1.IngressBackend) {
+			if backend == nil || backend.Service == nil {
+				return
+			}
+			h := BackendHealth{Host: host, Path: path, Service: backend.Service.Name, Port: backend.Service.Port.Name}
+			if h.Port == "" {
+				h.Port = strconv.Itoa(int(backend.Service.Port.Number))
+			}
+			named, found := lookup.ports[backend.Service.Name]
+			portName, exposed := named[h.Port]
+			switch {
+			case lookup.err != nil:
+				h.Error = lookup.err.Error()
+			case !found:
+				h.Error = "service not found"
+			case !exposed:
+				h.Error = "port not exposed by the service"
+			default:
+				h.Ready, h.NotReady = countEndpoints(lookup.slices[backend.Service.Name], portName)
+				if h.Ready == 0 {
+					h.Error = "no ready endpoints"
+				}
+			}
+			health = append(health, h)
+		}
+		check("", "", ingress.Spec.DefaultBackend)
+		for _, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				check(rule.Host, path.Path, &path.Backend)
+			}
+		}
+		data, _ := json.Marshal(health)
+		if ingress.Annotations == nil {
+			ingress.Annotations = map[string]string{}
+		}
+		ingress.Annotations[BackendHealthAnnotation] = string(data)
+	}
+}
+
+// backendLookup is what the backends of the Ingresses of a namespace are checked against: the ports of
+// its services by name and number, and their EndpointSlices, both by service name. err is why they
+// couldn't be listed.
+type backendLookup struct {
+	ports  map[string]map[string]string
+	slices map[string][]discoveryv1.EndpointSlice
+	err    error
+}
+
+func (o IngressOperation) lookupBackends(ctx context.Context, namespace string) *backendLookup {
+	lookup := &backendLookup{ports: map[string]map[string]string{}, slices: map[string][]discoveryv1.EndpointSlice{}}
+	services, err := o.clientSet.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		lookup.err = fmt.Errorf("list services: %v", err)
+		return lookup
+	}
+	slices, err := o.clientSet.DiscoveryV1().EndpointSlices(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		lookup.err = fmt.Errorf("list endpointslices: %v", err)
+		return lookup
+	}
+	for _, service := range services.Items {
+		named := map[string]string{}
+		for _, port := range service.Spec.Ports {
+			named[port.Name] = port.Name
+			named[strconv.Itoa(int(port.Port))] = port.Name
+		}
+		lookup.ports[service.Name] = named
+	}
+	for _, slice := range slices.Items {
+		name := slice.Labels[discoveryv1.LabelServiceName]
+		lookup.slices[name] = append(lookup.slices[name], slice)
+	}
+	return lookup
+}
+
+// countEndpoints counts the endpoints of slices serving the service port named port.
+func countEndpoints(slices []discoveryv1.EndpointSlice, port string) (ready, notReady int) {
+	for _, slice := range slices {
+		served := false
+		for _, p := range slice.Ports {
+			if p.Name != nil && *p.Name == port {
+				served = true
+			}
+		}
+		if !served {
+			continue
+		}
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				ready++
+			} else {
+				notReady++
+			}
+		}
+	}
+	return ready, notReady
+}
@@ -0,0 +1,136 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestSlice(namespace, service, name string, ports []string, ready ...*bool) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta:  metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	for i := range ports {
+		slice.Ports = append(slice.Ports, discoveryv1.EndpointPort{Name: &ports[i]})
+	}
+	for _, r := range ready {
+		slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: r}})
+	}
+	return slice
+}
+
+func TestCountEndpoints(t *testing.T) {
+	yes, no := true, false
+	slices := []discoveryv1.EndpointSlice{
+		*newTestSlice("shop", "web", "web-a", []string{"http", "metrics"}, &yes, &no, nil),
+		*newTestSlice("shop", "web", "web-b", []string{"http"}, &yes),
+		*newTestSlice("shop", "web", "web-c", []string{"metrics"}, &no),
+		*newTestSlice("shop", "web", "web-d", []string{""}, &yes),
+		{ObjectMeta: metav1.ObjectMeta{Name: "web-e"}, Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.9"}}}},
+	}
+	tests := []struct {
+		port            string
+		ready, notReady int
+	}{
+		{port: "http", ready: 3, notReady: 1},
+		{port: "metrics", ready: 2, notReady: 2},
+		{port: "", ready: 1},
+		{port: "grpc"},
+	}
+	for _, tt := range tests {
+		ready, notReady := countEndpoints(slices, tt.port)
+		if ready != tt.ready || notReady != tt.notReady {
+			t.Errorf("port %q: %d ready, %d not ready, want %d, %d", tt.port, ready, notReady, tt.ready, tt.notReady)
+		}
+	}
+}
+
+func TestAnnotateBackendHealth(t *testing.T) {
+	yes, no := true, false
+	backend := func(service, port string, number int32) networkingv1.IngressBackend {
+		return networkingv1.IngressBackend{Service: &networkingv1.IngressServiceBackend{
+			Name: service, Port: networkingv1.ServiceBackendPort{Name: port, Number: number}}}
+	}
+	newIngress := func(namespace, name string, defaultBackend *networkingv1.IngressBackend, paths ...networkingv1.HTTPIngressPath) networkingv1.Ingress {
+		ingress := networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		ingress.Spec.DefaultBackend = defaultBackend
+		if len(paths) > 0 {
+			ingress.Spec.Rules = []networkingv1.IngressRule{{Host: "shop.example.com",
+				IngressRuleValue: networkingv1.IngressRuleValue{HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths}}}}
+		}
+		return ingress
+	}
+	objects := []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "api"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 8080}}}},
+		newTestSlice("shop", "web", "web-a", []string{"http"}, &yes, &no),
+		newTestSlice("shop", "api", "api-a", []string{"http"}, &no),
+		// a service of the same name in another namespace doesn't count.
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "cart"},
+			Spec: corev1.ServiceSpec{Ports: []corev1.ServicePort{{Name: "http", Port: 80}}}},
+	}
+	client := fake.NewSimpleClientset(objects...)
+	var listed []string
+	client.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		listed = append(listed, action.GetResource().Resource+" in "+action.GetNamespace())
+		if action.GetNamespace() == "locked" && action.GetResource().Resource == "endpointslices" {
+			return true, nil, errors.New("endpointslices is forbidden")
+		}
+		return false, nil, nil
+	})
+
+	web := backend("web", "http", 0)
+	ingresses := []networkingv1.Ingress{
+		newIngress("shop", "shop", &web,
+			networkingv1.HTTPIngressPath{Path: "/", Backend: backend("web", "", 80)},
+			networkingv1.HTTPIngressPath{Path: "/api", Backend: backend("api", "http", 0)},
+			networkingv1.HTTPIngressPath{Path: "/admin", Backend: backend("web", "admin", 0)},
+			networkingv1.HTTPIngressPath{Path: "/cart", Backend: backend("cart", "", 80)},
+			networkingv1.HTTPIngressPath{Path: "/static", Backend: networkingv1.IngressBackend{
+				Resource: &corev1.TypedLocalObjectReference{Kind: "StorageBucket", Name: "static"}}},
+		),
+		newIngress("shop", "empty", nil),
+		newIngress("locked", "web", &web),
+	}
+	IngressOperation{clientSet: client}.AnnotateBackendHealth(context.Background(), ingresses)
+
+	want := map[string][]BackendHealth{
+		"shop/shop": {
+			{Service: "web", Port: "http", Ready: 1, NotReady: 1},
+			{Host: "shop.example.com", Path: "/", Service: "web", Port: "80", Ready: 1, NotReady: 1},
+			{Host: "shop.example.com", Path: "/api", Service: "api", Port: "http", NotReady: 1, Error: "no ready endpoints"},
+			{Host: "shop.example.com", Path: "/admin", Service: "web", Port: "admin", Error: "port not exposed by the service"},
+			{Host: "shop.example.com", Path: "/cart", Service: "cart", Port: "80", Error: "service not found"},
+		},
+		"shop/empty": nil,
+		"locked/web": {{Service: "web", Port: "http", Error: "list endpointslices: endpointslices is forbidden"}},
+	}
+	for _, ingress := range ingresses {
+		var health []BackendHealth
+		if err := json.Unmarshal([]byte(ingress.Annotations[BackendHealthAnnotation]), &health); err != nil {
+			t.Fatalf("%s/%s: %v", ingress.Namespace, ingress.Name, err)
+		}
+		if key := ingress.Namespace + "/" + ingress.Name; !reflect.DeepEqual(health, want[key]) {
+			t.Errorf("health of %s = %+v\nwant %+v", key, health, want[key])
+		}
+	}
+
+	// each namespace of the ingresses is looked up once, never the whole cluster.
+	wantListed := []string{"services in shop", "endpointslices in shop", "services in locked", "endpointslices in locked"}
+	if !reflect.DeepEqual(listed, wantListed) {
+		t.Errorf("listed %v, want %v", listed, wantListed)
+	}
+}
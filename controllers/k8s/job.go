@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"context"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/client-go/kubernetes"
+)
+
+// JobControllerLabels are set by the apiserver on the pods of a Job to select them.
+var JobControllerLabels = []string{
+	"controller-uid",
+	"batch.kubernetes.io/controller-uid",
+	"job-name",
+	"batch.kubernetes.io/job-name",
+}
+
+// jobTrackingAnnotation is set by the Job controller on the Jobs it tracks with finalizers.
+const jobTrackingAnnotation = "batch.kubernetes.io/job-tracking"
+
+// JobPodStatus sums up a pod of a Job.
+type JobPodStatus struct {
+	Name     string          `json:"name"`
+	Phase    corev1.PodPhase `json:"phase"`
+	Ready    bool            `json:"ready"`
+	Restarts int32           `json:"restarts"`
+	// why a container is waiting or terminated, if one is
+	Reason    string       `json:"reason,omitempty"`
+	NodeName  string       `json:"nodeName,omitempty"`
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// JobWithPods is a Job with the status of its pods.
+type JobWithPods struct {
+	batchv1.Job
+	Pods []JobPodStatus `json:"pods"`
+}
+
+type JobInterface interface {
+	Create(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error)
+	// Rerun creates a copy of a Job under a new name.
+	Rerun(ctx context.Context, namespace, name string) (*batchv1.Job, error)
+	// Pods returns the pods of job.
+	Pods(ctx context.Context, job *batchv1.Job) (*corev1.PodList, error)
+	// WithPods adds the status of their pods to jobs.
+	WithPods(ctx context.Context, jobs []batchv1.Job) ([]JobWithPods, error)
+}
+
+type JobOperation struct {
+	clientSet kubernetes.Interface
+}
+
+func NewJobOperation(client *kubernetes.Clientset) JobInterface {
+	return JobOperation{
+		clientSet: client,
+	}
+}
+
+func (o JobOperation) Create(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	return o.clientSet.BatchV1().Jobs(job.Namespace).Create(ctx, job, metav1.CreateOptions{})
+}
+
+// Rerun copies the spec of the Job with its labels and owners, leaving out what the apiserver generated
+// to select its pods, which the copy gets anew. The controller of the Job, a CronJob say, does not
+// control the copy, it would count it as one of its own Jobs and clean it up.
+func (o JobOperation) Rerun(ctx context.Context, namespace, name string) (*batchv1.Job, error) {
+	job, err := o.clientSet.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// the name of a Job goes in the labels of its pods, at most 63 characters
+	prefix := job.Name
+	if len(prefix) > 50 {
+		prefix = prefix[:50]
+	}
+	rerun := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        prefix + "-rerun-" + utilrand.String(5),
+			Namespace:   job.Namespace,
+			Labels:      job.Labels,
+			Annotations: job.Annotations,
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	for _, owner := range job.OwnerReferences {
+		if owner.Controller == nil || !*owner.Controller {
+			rerun.OwnerReferences = append(rerun.OwnerReferences, owner)
+		}
+	}
+	delete(rerun.Annotations, jobTrackingAnnotation)
+	if rerun.Spec.ManualSelector == nil || !*rerun.Spec.ManualSelector {
+		rerun.Spec.Selector = nil
+		for _, label := range JobControllerLabels {
+			delete(rerun.Labels, label)
+			delete(rerun.Spec.Template.Labels, label)
+		}
+	}
+	return o.Create(ctx, rerun)
+}
+
+func (o JobOperation) Pods(ctx context.Context, job *batchv1.Job) (*corev1.PodList, error) {
+	if job.Spec.Selector == nil {
+		return &corev1.PodList{}, nil
+	}
+	return o.clientSet.CoreV1().Pods(job.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(job.Spec.Selector),
+	})
+}
+
+func (o JobOperation) WithPods(ctx context.Context, jobs []batchv1.Job) ([]JobWithPods, error) {
+	result := make([]JobWithPods, 0, len(jobs))
+	for _, job := range jobs {
+		withPods := JobWithPods{Job: job, Pods: []JobPodStatus{}}
+		pods, err := o.Pods(ctx, &job)
+		if err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			withPods.Pods = append(withPods.Pods, jobPodStatus(&pods.Items[i]))
+		}
+		result = append(result, withPods)
+	}
+	return result, nil
+}
+
+func jobPodStatus(pod *corev1.Pod) JobPodStatus {
+	status := JobPodStatus{
+		Name:      pod.Name,
+		Phase:     pod.Status.Phase,
+		NodeName:  pod.Spec.NodeName,
+		StartTime: pod.Status.StartTime,
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			status.Ready = condition.Status == corev1.ConditionTrue
+		}
+	}
+	for _, container := range pod.Status.ContainerStatuses {
+		status.Restarts += container.RestartCount
+		if status.Reason != "" {
+			continue
+		}
+		if waiting := container.State.Waiting; waiting != nil {
+			status.Reason = waiting.Reason
+		} else if terminated := container.State.Terminated; terminated != nil {
+			status.Reason = terminated.Reason
+		}
+	}
+	return status
+}
@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestJob(manualSelector bool) *batchv1.Job {
+	controller := true
+	generated := map[string]string{
+		"controller-uid": "job-uid", "batch.kubernetes.io/controller-uid": "job-uid",
+		"job-name": "backup", "batch.kubernetes.io/job-name": "backup",
+	}
+	podLabels := map[string]string{"app": "backup"}
+	for key, value := range generated {
+		podLabels[key] = value
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "shop",
+			Name:        "backup",
+			UID:         "job-uid",
+			Labels:      map[string]string{"app": "backup", "controller-uid": "job-uid", "job-name": "backup"},
+			Annotations: map[string]string{jobTrackingAnnotation: "", "team": "shop"},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "batch/v1", Kind: "CronJob", Name: "nightly", UID: "cronjob-uid", Controller: &controller},
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "backup-config", UID: "configmap-uid"},
+			},
+		},
+		Spec: batchv1.JobSpec{
+			ManualSelector: &manualSelector,
+			Selector:       &metav1.LabelSelector{MatchLabels: map[string]string{"controller-uid": "job-uid"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "backup", Image: "backup:1"}}},
+			},
+		},
+	}
+}
+
+func TestJobRerun(t *testing.T) {
+	tests := []struct {
+		name      string
+		job       *batchv1.Job
+		labels    map[string]string
+		podLabels map[string]string
+		selector  *metav1.LabelSelector
+	}{
+		{name: "generated selector", job: newTestJob(false),
+			labels: map[string]string{"app": "backup"}, podLabels: map[string]string{"app": "backup"}},
+		{name: "manual selector", job: newTestJob(true),
+			labels: newTestJob(true).Labels, podLabels: newTestJob(true).Spec.Template.Labels,
+			selector: newTestJob(true).Spec.Selector},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tt.job)
+			rerun, err := JobOperation{clientSet: client}.Rerun(context.Background(), "shop", "backup")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(rerun.Name, "backup-rerun-") || rerun.Namespace != "shop" {
+				t.Errorf("rerun is %s/%s", rerun.Namespace, rerun.Name)
+			}
+			if !reflect.DeepEqual(rerun.Labels, tt.labels) || !reflect.DeepEqual(rerun.Spec.Template.Labels, tt.podLabels) {
+				t.Errorf("labels = %v, pod labels = %v", rerun.Labels, rerun.Spec.Template.Labels)
+			}
+			if !reflect.DeepEqual(rerun.Spec.Selector, tt.selector) {
+				t.Errorf("selector = %v, want %v", rerun.Spec.Selector, tt.selector)
+			}
+			if !reflect.DeepEqual(rerun.Annotations, map[string]string{"team": "shop"}) {
+				t.Errorf("annotations = %v", rerun.Annotations)
+			}
+			// the CronJob doesn't control the copy, other owners still own it.
+			if len(rerun.OwnerReferences) != 1 || rerun.OwnerReferences[0].Kind != "ConfigMap" {
+				t.Errorf("owners = %v", rerun.OwnerReferences)
+			}
+
+			original, err := client.BatchV1().Jobs("shop").Get(context.Background(), "backup", metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(original, tt.job) {
+				t.Errorf("the rerun Job was changed: %+v", original)
+			}
+		})
+	}
+}
@@ -1,16 +1,23 @@
 package k8s
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"log"
+	"sort"
 	"sync"
 
 	"github.com/lmxia/nightwatcher/utils"
 
 	gaiaclientset "github.com/lmxia/gaia/pkg/generated/clientset/versioned"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/client-go/discovery/cached/memory"
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/utils/lru"
 )
 
 type ClientManager struct {
@@ -18,6 +25,12 @@ type ClientManager struct {
 	K8sDynamicClient *dynamic.DynamicClient
 	Gaiaclient       *gaiaclientset.Clientset
 	RestConfig       *restclient.Config
+	// Mapper resolves kinds and resources through a cached discovery of the apiserver.
+	Mapper *restmapper.DeferredDiscoveryRESTMapper
+
+	// impersonated caches the clients impersonating callers through these clients, so they are
+	// dropped together with them when a cluster's clients are evicted or rebuilt.
+	impersonated *impersonatedClients
 }
 
 var (
@@ -33,14 +46,12 @@ func GetClientWithPanic() (*ClientManager, error) {
 			log.Println("we can't get k8s client" + err.Error())
 		}
 	})
-	if k8sClients == nil {
+	if k8sClient == nil {
 		return nil, errors.New("can't get k8s client")
 	}
 	return k8sClient, nil
 }
 
-var k8sClients = &sync.Map{} // 并发map
-
 func GetClient() (*ClientManager, error) {
 	// By default we get in cluster config.
 	//localKubeConfig, err := utils.LoadsKubeConfig("/Users/lumingming/.kube/qingGlobal/gloal", 1)
@@ -48,17 +59,115 @@ func GetClient() (*ClientManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	localKubeClientSet := kubernetes.NewForConfigOrDie(localKubeConfig)
-	localGaiaClientSet := gaiaclientset.NewForConfigOrDie(localKubeConfig)
-	localK8sDynamicClient, _ := dynamic.NewForConfig(localKubeConfig)
+	return NewClientManager(localKubeConfig)
+}
+
+// NewClientManager builds all clients from config.
+func NewClientManager(config *restclient.Config) (*ClientManager, error) {
+	localKubeClientSet, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	localGaiaClientSet, err := gaiaclientset.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	localK8sDynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
 
 	return &ClientManager{
 		K8sClient:        localKubeClientSet,
 		Gaiaclient:       localGaiaClientSet,
 		K8sDynamicClient: localK8sDynamicClient,
+		RestConfig:       config,
+		Mapper:           restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(localKubeClientSet.Discovery())),
+		impersonated:     &impersonatedClients{cache: lru.New(impersonatedClientCacheSize)},
 	}, nil
 }
 
+// impersonatedClientCacheSize bounds how many callers keep their clients around per cluster.
+const impersonatedClientCacheSize = 256
+
+type impersonatedClients struct {
+	lock  sync.Mutex
+	cache *lru.Cache
+}
+
+// GetClientForRequest returns the clients to serve a request with. They talk to the ManagedCluster
+// targeted by the request, see utils.WithCluster, or the local cluster. When the request carries an
+// authenticated caller, see utils.WithUser, the clients impersonate it so cluster RBAC decides what
+// the caller may do; otherwise the service account's own clients are returned.
+func GetClientForRequest(ctx context.Context) (*ClientManager, error) {
+	base, err := GetServiceClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, ok := utils.UserFrom(ctx)
+	if !ok {
+		return base, nil
+	}
+	return impersonate(base, user)
+}
+
+// GetServiceClient returns the service account's own clients of the cluster targeted by ctx, for work
+// carried on after the request of an authorized caller returned.
+func GetServiceClient(ctx context.Context) (*ClientManager, error) {
+	if cluster, ok := utils.ClusterFrom(ctx); ok {
+		return GetClusterClient(ctx, cluster)
+	}
+	return GetClientWithPanic()
+}
+
+func impersonate(base *ClientManager, user *authenticationv1.UserInfo) (*ClientManager, error) {
+	key := impersonationKey(user)
+
+	base.impersonated.lock.Lock()
+	defer base.impersonated.lock.Unlock()
+	if cached, ok := base.impersonated.cache.Get(key); ok {
+		return cached.(*ClientManager), nil
+	}
+
+	config := restclient.CopyConfig(base.RestConfig)
+	config.Impersonate = restclient.ImpersonationConfig{
+		UserName: user.Username,
+		UID:      user.UID,
+		Groups:   user.Groups,
+		Extra:    make(map[string][]string, len(user.Extra)),
+	}
+	for k, v := range user.Extra {
+		config.Impersonate.Extra[k] = v
+	}
+	clients, err := NewClientManager(config)
+	if err != nil {
+		return nil, err
+	}
+	base.impersonated.cache.Add(key, clients)
+	return clients, nil
+}
+
+// impersonationKey identifies the clients of one caller, every attribute impersonated is part of it.
+func impersonationKey(user *authenticationv1.UserInfo) string {
+	sorted := func(values []string) []string {
+		values = append([]string{}, values...)
+		sort.Strings(values)
+		return values
+	}
+	extra := make(map[string][]string, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = sorted(v)
+	}
+	// json keeps names containing separators apart, and sorts the extra keys.
+	key, _ := json.Marshal(struct {
+		Username string
+		UID      string
+		Groups   []string
+		Extra    map[string][]string
+	}{user.Username, user.UID, sorted(user.Groups), extra})
+	return string(key)
+}
+
 /*
 const (
 	// High enough QPS to fit all expected use cases.
@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	restclient "k8s.io/client-go/rest"
+)
+
+func TestImpersonationKey(t *testing.T) {
+	alice := &authenticationv1.UserInfo{
+		Username: "alice",
+		UID:      "1",
+		Groups:   []string{"dev", "ops"},
+		Extra:    map[string]authenticationv1.ExtraValue{"scopes": {"read", "write"}, "tenant": {"a"}},
+	}
+	same := []*authenticationv1.UserInfo{
+		alice,
+		{Username: "alice", UID: "1", Groups: []string{"ops", "dev"},
+			Extra: map[string]authenticationv1.ExtraValue{"tenant": {"a"}, "scopes": {"write", "read"}}},
+	}
+	different := []*authenticationv1.UserInfo{
+		{Username: "alice", UID: "1", Groups: []string{"dev", "ops"}},
+		{Username: "alice", UID: "1", Groups: []string{"dev", "ops"},
+			Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read"}, "tenant": {"a"}}},
+		{Username: "alice", UID: "1", Groups: []string{"dev", "ops"},
+			Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read", "write"}, "tenant": {"b"}}},
+		{Username: "alice", UID: "1", Groups: []string{"dev"},
+			Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read", "write"}, "tenant": {"a"}}},
+		{Username: "alice", UID: "2", Groups: []string{"dev", "ops"},
+			Extra: map[string]authenticationv1.ExtraValue{"scopes": {"read", "write"}, "tenant": {"a"}}},
+	}
+	for _, user := range same {
+		if impersonationKey(user) != impersonationKey(alice) {
+			t.Errorf("%v and %v have different keys", user, alice)
+		}
+	}
+	for _, user := range different {
+		if impersonationKey(user) == impersonationKey(alice) {
+			t.Errorf("%v and %v share a key", user, alice)
+		}
+	}
+
+	// separators within names don't make callers collide.
+	a := &authenticationv1.UserInfo{Username: "a|b", UID: "c"}
+	b := &authenticationv1.UserInfo{Username: "a", UID: "b|c"}
+	if impersonationKey(a) == impersonationKey(b) {
+		t.Errorf("%v and %v share a key", a, b)
+	}
+	c := &authenticationv1.UserInfo{Username: "a", Groups: []string{"b,c"}}
+	d := &authenticationv1.UserInfo{Username: "a", Groups: []string{"b", "c"}}
+	if impersonationKey(c) == impersonationKey(d) {
+		t.Errorf("%v and %v share a key", c, d)
+	}
+}
+
+func TestImpersonate(t *testing.T) {
+	newBase := func() *ClientManager {
+		base, err := NewClientManager(&restclient.Config{Host: "https://cluster-a.example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base
+	}
+	base := newBase()
+	alice := &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"dev"},
+		Extra: map[string]authenticationv1.ExtraValue{"tenant": {"a"}}}
+
+	clients, err := impersonate(base, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonated := clients.RestConfig.Impersonate
+	if impersonated.UserName != "alice" || impersonated.UID != "1" || len(impersonated.Groups) != 1 ||
+		len(impersonated.Extra["tenant"]) != 1 {
+		t.Errorf("impersonation config = %+v", impersonated)
+	}
+	if base.RestConfig.Impersonate.UserName != "" {
+		t.Errorf("the base config was changed")
+	}
+
+	again, _ := impersonate(base, alice)
+	if again != clients {
+		t.Errorf("clients of the same caller were not reused")
+	}
+	other, _ := impersonate(base, &authenticationv1.UserInfo{Username: "alice", UID: "1", Groups: []string{"dev"},
+		Extra: map[string]authenticationv1.ExtraValue{"tenant": {"b"}}})
+	if other == clients {
+		t.Errorf("clients were shared by callers with different extra")
+	}
+
+	// rebuilt clients of the same apiserver start without the callers of the old ones.
+	rebuilt, _ := impersonate(newBase(), alice)
+	if rebuilt == clients {
+		t.Errorf("clients were reused across rebuilt cluster clients")
+	}
+}
@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ListParams are the query parameters shared by the list endpoints. Without pageSize the whole list is
+// returned.
+type ListParams struct {
+	Page          int    `form:"page" binding:"omitempty,min=1"`
+	PageSize      int    `form:"pageSize" binding:"omitempty,min=1,max=1000"`
+	Sort          string `form:"sort" binding:"omitempty,oneof=name -name namespace -namespace creationTimestamp -creationTimestamp"`
+	FieldSelector string `form:"fieldSelector"`
+	NameContains  string `form:"nameContains"`
+	Continue      string `form:"continue"`
+
+	// whether the apiserver cut the page
+	serverSide bool
+}
+
+// PageNumber returns the requested page, counting from 1.
+func (p *ListParams) PageNumber() int {
+	if p.Page < 1 {
+		return 1
+	}
+	return p.Page
+}
+
+// Cacheable reports whether the read cache can answer these parameters, it can't evaluate field
+// selectors or continue tokens.
+func (p *ListParams) Cacheable() bool {
+	return p.FieldSelector == "" && p.Continue == ""
+}
+
+// ListOptions returns the options of a live list. The apiserver cuts the page itself when nothing has
+// to be sorted or filtered here and the page is either the first one or follows the continue token
+// returned with the previous one.
+func (p *ListParams) ListOptions(label string) metav1.ListOptions {
+	opts := metav1.ListOptions{LabelSelector: label, FieldSelector: p.FieldSelector}
+	if p.PageSize > 0 && p.Sort == "" && p.NameContains == "" && (p.Continue != "" || p.PageNumber() == 1) {
+		opts.Limit = int64(p.PageSize)
+		opts.Continue = p.Continue
+		p.serverSide = true
+	}
+	return opts
+}
+
+// Paginate filters, sorts and cuts list to the requested page in place, unless the apiserver already
+// did, and returns the total number of items. The total is -1 when the apiserver can't tell, the list's
+// continue token then leads to the next page.
+func (p *ListParams) Paginate(list runtime.Object) (int64, error) {
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return 0, err
+	}
+	if p.serverSide {
+		seen := int64(p.PageNumber()-1)*int64(p.PageSize) + int64(meta.LenList(list))
+		if listMeta.GetContinue() == "" {
+			return seen, nil
+		}
+		if remaining := listMeta.GetRemainingItemCount(); remaining != nil {
+			return seen + *remaining, nil
+		}
+		return -1, nil
+	}
+
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return 0, err
+	}
+	objs := make([]metav1.Object, 0, len(items))
+	kept := items[:0]
+	for _, item := range items {
+		obj, err := meta.Accessor(item)
+		if err != nil {
+			return 0, err
+		}
+		if p.NameContains != "" && !strings.Contains(obj.GetName(), p.NameContains) {
+			continue
+		}
+		objs = append(objs, obj)
+		kept = append(kept, item)
+	}
+	if p.Sort != "" {
+		sort.Stable(byKey{key: strings.TrimPrefix(p.Sort, "-"), desc: strings.HasPrefix(p.Sort, "-"), objs: objs, items: kept})
+	}
+
+	total := len(kept)
+	if p.PageSize > 0 {
+		start := (p.PageNumber() - 1) * p.PageSize
+		if start > total {
+			start = total
+		}
+		end := start + p.PageSize
+		if end > total {
+			end = total
+		}
+		kept = kept[start:end]
+	}
+	if err := meta.SetList(list, kept); err != nil {
+		return 0, err
+	}
+	listMeta.SetContinue("")
+	listMeta.SetRemainingItemCount(nil)
+	return int64(total), nil
+}
+
+type byKey struct {
+	key   string
+	desc  bool
+	objs  []metav1.Object
+	items []runtime.Object
+}
+
+func (s byKey) Len() int { return len(s.objs) }
+
+func (s byKey) Swap(i, j int) {
+	s.objs[i], s.objs[j] = s.objs[j], s.objs[i]
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+}
+
+func (s byKey) Less(i, j int) bool {
+	if s.desc {
+		i, j = j, i
+	}
+	a, b := s.objs[i], s.objs[j]
+	switch s.key {
+	case "namespace":
+		if a.GetNamespace() != b.GetNamespace() {
+			return a.GetNamespace() < b.GetNamespace()
+		}
+	case "creationTimestamp":
+		at, bt := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+		if !at.Equal(&bt) {
+			return at.Before(&bt)
+		}
+	}
+	if a.GetName() != b.GetName() {
+		return a.GetName() < b.GetName()
+	}
+	return a.GetNamespace() < b.GetNamespace()
+}
@@ -0,0 +1,171 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newPodList(pods ...corev1.Pod) *corev1.PodList {
+	return &corev1.PodList{Items: pods, ListMeta: metav1.ListMeta{Continue: "stale", ResourceVersion: "42"}}
+}
+
+func newListPod(namespace, name string, age time.Duration) corev1.Pod {
+	created := metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age))
+	return corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, CreationTimestamp: created}}
+}
+
+func podNames(list *corev1.PodList) []string {
+	names := make([]string, 0, len(list.Items))
+	for _, pod := range list.Items {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	return names
+}
+
+func TestListParamsPaginate(t *testing.T) {
+	pods := func() *corev1.PodList {
+		return newPodList(
+			newListPod("b", "web-2", 3*time.Hour),
+			newListPod("a", "web-1", time.Hour),
+			newListPod("a", "db-1", 2*time.Hour),
+			newListPod("b", "web-1", 4*time.Hour),
+			newListPod("c", "cache-1", time.Hour),
+		)
+	}
+
+	tests := []struct {
+		name   string
+		params ListParams
+		want   []string
+		total  int64
+	}{
+		{name: "everything", params: ListParams{}, total: 5,
+			want: []string{"b/web-2", "a/web-1", "a/db-1", "b/web-1", "c/cache-1"}},
+		{name: "name contains", params: ListParams{NameContains: "web"}, total: 3,
+			want: []string{"b/web-2", "a/web-1", "b/web-1"}},
+		{name: "by name", params: ListParams{Sort: "name"}, total: 5,
+			want: []string{"c/cache-1", "a/db-1", "a/web-1", "b/web-1", "b/web-2"}},
+		{name: "by name descending", params: ListParams{Sort: "-name"}, total: 5,
+			want: []string{"b/web-2", "b/web-1", "a/web-1", "a/db-1", "c/cache-1"}},
+		{name: "by namespace", params: ListParams{Sort: "namespace"}, total: 5,
+			want: []string{"a/db-1", "a/web-1", "b/web-1", "b/web-2", "c/cache-1"}},
+		{name: "by namespace descending", params: ListParams{Sort: "-namespace"}, total: 5,
+			want: []string{"c/cache-1", "b/web-2", "b/web-1", "a/web-1", "a/db-1"}},
+		{name: "oldest first, ties by name", params: ListParams{Sort: "creationTimestamp"}, total: 5,
+			want: []string{"b/web-1", "b/web-2", "a/db-1", "c/cache-1", "a/web-1"}},
+		{name: "newest first", params: ListParams{Sort: "-creationTimestamp"}, total: 5,
+			want: []string{"a/web-1", "c/cache-1", "a/db-1", "b/web-2", "b/web-1"}},
+		{name: "first page", params: ListParams{Sort: "name", PageSize: 2}, total: 5,
+			want: []string{"c/cache-1", "a/db-1"}},
+		{name: "last page", params: ListParams{Sort: "name", Page: 3, PageSize: 2}, total: 5,
+			want: []string{"b/web-2"}},
+		{name: "past the last page", params: ListParams{Sort: "name", Page: 4, PageSize: 2}, total: 5,
+			want: []string{}},
+		{name: "filtered page", params: ListParams{NameContains: "web", Sort: "-name", Page: 2, PageSize: 2}, total: 3,
+			want: []string{"a/web-1"}},
+		{name: "nothing matches", params: ListParams{NameContains: "api", PageSize: 2}, total: 0,
+			want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := pods()
+			total, err := tt.params.Paginate(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.total {
+				t.Errorf("total = %d, want %d", total, tt.total)
+			}
+			if got := podNames(list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("items = %v, want %v", got, tt.want)
+			}
+			if list.Continue != "" || list.RemainingItemCount != nil {
+				t.Errorf("continue = %q, remaining = %v, want them cleared", list.Continue, list.RemainingItemCount)
+			}
+		})
+	}
+}
+
+func TestListParamsServerSide(t *testing.T) {
+	remaining := func(n int64) *int64 { return &n }
+
+	tests := []struct {
+		name       string
+		params     ListParams
+		limit      int64
+		continued  string
+		remaining  *int64
+		total      int64
+		serverSide bool
+	}{
+		{name: "whole list", params: ListParams{}, total: 2},
+		{name: "first page", params: ListParams{PageSize: 2}, limit: 2, serverSide: true,
+			continued: "next", remaining: remaining(7), total: 9},
+		{name: "first page without remaining count", params: ListParams{PageSize: 2}, limit: 2, serverSide: true,
+			continued: "next", total: -1},
+		{name: "only page", params: ListParams{PageSize: 2}, limit: 2, serverSide: true, total: 2},
+		{name: "continued page", params: ListParams{Page: 3, PageSize: 2, Continue: "next"}, limit: 2, serverSide: true,
+			continued: "after", remaining: remaining(1), total: 7},
+		{name: "last continued page", params: ListParams{Page: 3, PageSize: 2, Continue: "next"}, limit: 2, serverSide: true,
+			total: 6},
+		{name: "later page without continue", params: ListParams{Page: 2, PageSize: 2}, total: 2},
+		{name: "sorted", params: ListParams{PageSize: 2, Sort: "name"}, total: 2},
+		{name: "filtered by name", params: ListParams{PageSize: 2, NameContains: "web"}, total: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := tt.params
+			opts := params.ListOptions("app=web")
+			if opts.LabelSelector != "app=web" || opts.Limit != tt.limit || opts.Continue != tt.params.Continue && tt.serverSide {
+				t.Fatalf("list options = %+v", opts)
+			}
+			if params.serverSide != tt.serverSide {
+				t.Fatalf("server side = %v, want %v", params.serverSide, tt.serverSide)
+			}
+			if !tt.serverSide {
+				// the page is cut here, from the whole list.
+				return
+			}
+
+			list := newPodList(newListPod("a", "web-1", 0), newListPod("a", "web-2", 0))
+			list.Continue, list.RemainingItemCount = tt.continued, tt.remaining
+			total, err := params.Paginate(list)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != tt.total {
+				t.Errorf("total = %d, want %d", total, tt.total)
+			}
+			if len(list.Items) != 2 || list.Continue != tt.continued {
+				t.Errorf("server side page was changed: %v, continue %q", podNames(list), list.Continue)
+			}
+		})
+	}
+}
+
+func TestListParamsCacheable(t *testing.T) {
+	for _, tt := range []struct {
+		params ListParams
+		want   bool
+	}{
+		{params: ListParams{}, want: true},
+		{params: ListParams{Sort: "name", NameContains: "web", Page: 2, PageSize: 10}, want: true},
+		{params: ListParams{FieldSelector: "status.phase=Running"}},
+		{params: ListParams{Continue: "next"}},
+	} {
+		if got := tt.params.Cacheable(); got != tt.want {
+			t.Errorf("%+v cacheable = %v, want %v", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestListParamsPaginateNotAList(t *testing.T) {
+	pod := newListPod("a", "web-1", 0)
+	if _, err := (&ListParams{}).Paginate(&pod); err == nil {
+		t.Errorf("a pod was paginated")
+	}
+}
@@ -0,0 +1,182 @@
+package k8s
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/lmxia/nightwatcher/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// SecretMask stands for the values of a Secret that are not revealed. Written back, it keeps the
+// current value.
+const SecretMask = "******"
+
+// ErrSecretKey is returned when the AES key revealing Secrets is not configured.
+var ErrSecretKey = errors.New("SECRET_AES_KEY must be set to an AES key of 16, 24 or 32 bytes")
+
+// SecretView is a Secret as the API serves and takes it: the values of data are masked, or AES-GCM
+// encrypted when encrypted is set, and otherwise base64 encoded like in a manifest on writes.
+type SecretView struct {
+	corev1.Secret
+	Data      map[string]string `json:"data,omitempty"`
+	Encrypted bool              `json:"encrypted,omitempty"`
+}
+
+type SecretViewList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SecretView `json:"items"`
+}
+
+type SecretInterface interface {
+	List(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.SecretList, error)
+	Get(ctx context.Context, namespace, name string) (*corev1.Secret, error)
+	Create(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error)
+	Update(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error)
+	Delete(ctx context.Context, namespace, name string) error
+}
+
+type SecretOperation struct {
+	clientSet *kubernetes.Clientset
+}
+
+func NewSecretOperation(client *kubernetes.Clientset) SecretInterface {
+	return SecretOperation{
+		clientSet: client,
+	}
+}
+
+func (o SecretOperation) List(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.SecretList, error) {
+	return o.clientSet.CoreV1().Secrets(namespace).List(ctx, opts)
+}
+
+func (o SecretOperation) Get(ctx context.Context, namespace, name string) (*corev1.Secret, error) {
+	return o.clientSet.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o SecretOperation) Create(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	return o.clientSet.CoreV1().Secrets(secret.Namespace).Create(ctx, secret, metav1.CreateOptions{})
+}
+
+func (o SecretOperation) Update(ctx context.Context, secret *corev1.Secret) (*corev1.Secret, error) {
+	return o.clientSet.CoreV1().Secrets(secret.Namespace).Update(ctx, secret, metav1.UpdateOptions{})
+}
+
+func (o SecretOperation) Delete(ctx context.Context, namespace, name string) error {
+	return o.clientSet.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+// SecretCipher encrypts the values of Secrets for the callers allowed to read them, and decrypts the
+// ones they send, with the AES key of this deployment of nightwatcher.
+type SecretCipher struct {
+	key []byte
+}
+
+// NewSecretCipher reads the key from the SECRET_AES_KEY env.
+func NewSecretCipher() (*SecretCipher, error) {
+	key := utils.GetEnvDefault("SECRET_AES_KEY", "")
+	switch len(key) {
+	case 16, 24, 32:
+		return &SecretCipher{key: []byte(key)}, nil
+	default:
+		return nil, ErrSecretKey
+	}
+}
+
+// Encrypt returns the view of secret with its values encrypted, each under a nonce of its own and bound
+// to its key, so that values can't be moved between keys.
+func (c *SecretCipher) Encrypt(secret *corev1.Secret) (*SecretView, error) {
+	view := newSecretView(secret)
+	view.Encrypted = true
+	for key, value := range secret.Data {
+		encrypted, err := utils.AesGCMEncrypt(value, c.key, []byte(key))
+		if err != nil {
+			return nil, err
+		}
+		view.Data[key] = encrypted
+	}
+	return view, nil
+}
+
+// MaskSecret returns the view of secret with its values masked.
+func MaskSecret(secret *corev1.Secret) *SecretView {
+	view := newSecretView(secret)
+	for key := range secret.Data {
+		view.Data[key] = SecretMask
+	}
+	return view
+}
+
+// MaskSecretObject masks the values of obj in place, as MaskSecret does, when it is a Secret.
+func MaskSecretObject(obj *unstructured.Unstructured) {
+	if obj.GroupVersionKind().GroupKind() != (schema.GroupKind{Kind: "Secret"}) {
+		return
+	}
+	if data, ok := obj.Object["data"].(map[string]interface{}); ok {
+		for key := range data {
+			data[key] = SecretMask
+		}
+	}
+	unstructured.RemoveNestedField(obj.Object, "stringData")
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		annotations[corev1.LastAppliedConfigAnnotation] = SecretMask
+		obj.SetAnnotations(annotations)
+	}
+}
+
+func newSecretView(secret *corev1.Secret) *SecretView {
+	view := &SecretView{Secret: *secret.DeepCopy(), Data: make(map[string]string, len(secret.Data))}
+	view.Secret.Data = nil
+	// kubectl apply keeps the whole manifest there, values included
+	if _, ok := view.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+		view.Annotations[corev1.LastAppliedConfigAnnotation] = SecretMask
+	}
+	return view
+}
+
+// SecretFrom returns the Secret written by view. Masked values are taken from current, the Secret
+// being updated, which is nil on creation. cipher decrypts encrypted views, it may be nil otherwise.
+func SecretFrom(view *SecretView, current *corev1.Secret, cipher *SecretCipher) (*corev1.Secret, error) {
+	if view.Encrypted && cipher == nil {
+		return nil, ErrSecretKey
+	}
+	secret := view.Secret.DeepCopy()
+	secret.Data = make(map[string][]byte, len(view.Data))
+	for key, value := range view.Data {
+		switch {
+		case value == SecretMask:
+			if current == nil || current.Data[key] == nil {
+				return nil, fmt.Errorf("key %s is masked but has no current value", key)
+			}
+			secret.Data[key] = current.Data[key]
+		case view.Encrypted:
+			decrypted, err := utils.AesGCMDecrypt(value, cipher.key, []byte(key))
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %v", key, err)
+			}
+			secret.Data[key] = decrypted
+		default:
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %v", key, err)
+			}
+			secret.Data[key] = decoded
+		}
+	}
+	if secret.Annotations[corev1.LastAppliedConfigAnnotation] == SecretMask {
+		delete(secret.Annotations, corev1.LastAppliedConfigAnnotation)
+		if current != nil {
+			if last, ok := current.Annotations[corev1.LastAppliedConfigAnnotation]; ok {
+				secret.Annotations[corev1.LastAppliedConfigAnnotation] = last
+			}
+		}
+	}
+	return secret, nil
+}
@@ -0,0 +1,263 @@
+package k8s
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const testAESKey = "0123456789abcdef0123456789abcdef"
+
+func newTestSecret() *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "shop",
+			Name:        "db",
+			Annotations: map[string]string{corev1.LastAppliedConfigAnnotation: `{"data":{"password":"aHVudGVyMg=="}}`, "team": "shop"},
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{"user": []byte("admin"), "password": []byte("hunter2")},
+	}
+}
+
+func newTestCipher(t *testing.T) *SecretCipher {
+	t.Helper()
+	t.Setenv("SECRET_AES_KEY", testAESKey)
+	cipher, err := NewSecretCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestNewSecretCipher(t *testing.T) {
+	for _, key := range []string{"", "short", testAESKey + "0"} {
+		t.Setenv("SECRET_AES_KEY", key)
+		if _, err := NewSecretCipher(); err != ErrSecretKey {
+			t.Errorf("key of %d bytes: err = %v, want ErrSecretKey", len(key), err)
+		}
+	}
+	for _, key := range []string{testAESKey[:16], testAESKey[:24], testAESKey} {
+		t.Setenv("SECRET_AES_KEY", key)
+		if _, err := NewSecretCipher(); err != nil {
+			t.Errorf("key of %d bytes: %v", len(key), err)
+		}
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	secret := newTestSecret()
+	view := MaskSecret(secret)
+	if !reflect.DeepEqual(view.Data, map[string]string{"user": SecretMask, "password": SecretMask}) {
+		t.Errorf("data = %v", view.Data)
+	}
+	if view.Secret.Data != nil || view.Annotations[corev1.LastAppliedConfigAnnotation] != SecretMask || view.Annotations["team"] != "shop" {
+		t.Errorf("view = %+v", view.Secret)
+	}
+	if !reflect.DeepEqual(secret, newTestSecret()) {
+		t.Errorf("the secret was changed: %+v", secret)
+	}
+}
+
+func TestSecretCipherEncrypt(t *testing.T) {
+	cipher := newTestCipher(t)
+	secret := newTestSecret()
+	view, err := cipher.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !view.Encrypted || view.Annotations[corev1.LastAppliedConfigAnnotation] != SecretMask {
+		t.Errorf("view = %+v", view)
+	}
+	for key, value := range view.Data {
+		if strings.Contains(value, string(secret.Data[key])) {
+			t.Errorf("%s = %s holds its value in clear", key, value)
+		}
+	}
+
+	// every value gets a nonce of its own, equal values don't look alike.
+	secret.Data["copy"] = secret.Data["password"]
+	view, err = cipher.Encrypt(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view.Data["copy"] == view.Data["password"] {
+		t.Errorf("equal values were encrypted alike")
+	}
+}
+
+func TestSecretFrom(t *testing.T) {
+	cipher := newTestCipher(t)
+	encrypted, err := cipher.Encrypt(newTestSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCipher := &SecretCipher{key: []byte(strings.Repeat("k", 32))}
+	otherEncrypted, err := otherCipher.Encrypt(newTestSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := newTestSecret()
+	current.Data["password"] = []byte("current")
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		view    func() *SecretView
+		current *corev1.Secret
+		cipher  *SecretCipher
+		want    map[string][]byte
+		err     string
+	}{
+		{name: "base64", view: func() *SecretView {
+			return &SecretView{Data: map[string]string{"user": b64([]byte("root")), "password": b64([]byte("s3cr3t"))}}
+		}, want: map[string][]byte{"user": []byte("root"), "password": []byte("s3cr3t")}},
+		{name: "masked keeps the current value", current: current, view: func() *SecretView {
+			return &SecretView{Data: map[string]string{"user": b64([]byte("root")), "password": SecretMask}}
+		}, want: map[string][]byte{"user": []byte("root"), "password": []byte("current")}},
+		{name: "masked on create", view: func() *SecretView {
+			return &SecretView{Data: map[string]string{"password": SecretMask}}
+		}, err: "key password is masked but has no current value"},
+		{name: "masked new key", current: current, view: func() *SecretView {
+			return &SecretView{Data: map[string]string{"token": SecretMask}}
+		}, err: "key token is masked but has no current value"},
+		{name: "invalid base64", view: func() *SecretView {
+			return &SecretView{Data: map[string]string{"user": "not base64!"}}
+		}, err: "key user:"},
+		{name: "encrypted", cipher: cipher, view: func() *SecretView {
+			view := *encrypted
+			return &view
+		}, want: map[string][]byte{"user": []byte("admin"), "password": []byte("hunter2")}},
+		{name: "encrypted and masked", cipher: cipher, current: current, view: func() *SecretView {
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": encrypted.Data["user"], "password": SecretMask}}
+		}, want: map[string][]byte{"user": []byte("admin"), "password": []byte("current")}},
+		{name: "encrypted without a key", view: func() *SecretView {
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": encrypted.Data["user"]}}
+		}, err: ErrSecretKey.Error()},
+		{name: "moved to another key", cipher: cipher, view: func() *SecretView {
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": encrypted.Data["password"]}}
+		}, err: "key user: aes: decryption failed"},
+		{name: "tampered with", cipher: cipher, view: func() *SecretView {
+			sealed, _ := base64.URLEncoding.DecodeString(encrypted.Data["user"])
+			sealed[len(sealed)-1] ^= 1
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": base64.URLEncoding.EncodeToString(sealed)}}
+		}, err: "key user: aes: decryption failed"},
+		{name: "truncated", cipher: cipher, view: func() *SecretView {
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": encrypted.Data["user"][:8]}}
+		}, err: "key user: aes: decryption failed"},
+		{name: "not base64", cipher: cipher, view: func() *SecretView {
+			return &SecretView{Encrypted: true, Data: map[string]string{"user": "not base64!"}}
+		}, err: "key user: aes: decryption failed"},
+		{name: "encrypted with another key", cipher: cipher, view: func() *SecretView {
+			view := *otherEncrypted
+			return &view
+		}, err: "aes: decryption failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := SecretFrom(tt.view(), tt.current, tt.cipher)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(secret.Data, tt.want) {
+				t.Errorf("data = %q, want %q", secret.Data, tt.want)
+			}
+		})
+	}
+}
+
+func TestSecretFromLastApplied(t *testing.T) {
+	current := newTestSecret()
+	view := MaskSecret(current)
+
+	secret, err := SecretFrom(view, current, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(secret.Annotations, current.Annotations) || !reflect.DeepEqual(secret.Data, current.Data) {
+		t.Errorf("a masked secret written back changed: %+v", secret)
+	}
+
+	// a masked last applied configuration can't be kept on creation, it is dropped.
+	secret, err = SecretFrom(&SecretView{Secret: view.Secret}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := secret.Annotations[corev1.LastAppliedConfigAnnotation]; ok || secret.Annotations["team"] != "shop" {
+		t.Errorf("annotations = %v", secret.Annotations)
+	}
+}
+
+func TestMaskSecretObject(t *testing.T) {
+	list := &unstructured.UnstructuredList{}
+	err := list.UnmarshalJSON([]byte(`{"apiVersion":"v1","kind":"SecretList","metadata":{},"items":[
+		{"metadata":{"name":"db","annotations":{"kubectl.kubernetes.io/last-applied-configuration":"{}","team":"shop"}},
+		 "data":{"password":"aHVudGVyMg=="},"stringData":{"user":"admin"},"type":"Opaque"},
+		{"metadata":{"name":"empty"},"type":"Opaque"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range list.Items {
+		MaskSecretObject(&list.Items[i])
+	}
+	db := list.Items[0].Object
+	if !reflect.DeepEqual(db["data"], map[string]interface{}{"password": SecretMask}) || db["stringData"] != nil {
+		t.Errorf("db = %v", db)
+	}
+	annotations := list.Items[0].GetAnnotations()
+	if annotations[corev1.LastAppliedConfigAnnotation] != SecretMask || annotations["team"] != "shop" {
+		t.Errorf("annotations = %v", annotations)
+	}
+	if _, ok := list.Items[1].Object["data"]; ok {
+		t.Errorf("empty = %v", list.Items[1].Object)
+	}
+
+	configMap := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1", "kind": "ConfigMap", "data": map[string]interface{}{"password": "hunter2"},
+	}}
+	MaskSecretObject(configMap)
+	if configMap.Object["data"].(map[string]interface{})["password"] != "hunter2" {
+		t.Errorf("a ConfigMap was masked")
+	}
+	// a Secret of another group is not one.
+	other := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1", "kind": "Secret", "data": map[string]interface{}{"password": "hunter2"},
+	}}
+	MaskSecretObject(other)
+	if other.Object["data"].(map[string]interface{})["password"] != "hunter2" {
+		t.Errorf("a Secret of example.com was masked")
+	}
+}
+
+func TestSecretFromErrorsAlike(t *testing.T) {
+	cipher := newTestCipher(t)
+	encrypted, err := cipher.Encrypt(newTestSecret())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, _ := base64.URLEncoding.DecodeString(encrypted.Data["user"])
+	var errs []error
+	// the nonce, the ciphertext and the tag tampered with.
+	for _, i := range []int{0, 12, len(sealed) - 1} {
+		tampered := append([]byte(nil), sealed...)
+		tampered[i] ^= 0x80
+		_, err := SecretFrom(&SecretView{Encrypted: true, Data: map[string]string{"user": base64.URLEncoding.EncodeToString(tampered)}}, nil, cipher)
+		errs = append(errs, err)
+	}
+	for _, err := range errs[1:] {
+		if err == nil || err.Error() != errs[0].Error() {
+			t.Errorf("errors = %v, want them alike", errs)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ServiceEndpoints is the endpoints of a service joined to the pods behind them, with the reasons why
+// they may be missing.
+type ServiceEndpoints struct {
+	Selector map[string]string `json:"selector,omitempty"`
+	// Ready and NotReady count the endpoints
+	Ready     int        `json:"ready"`
+	NotReady  int        `json:"notReady"`
+	Endpoints []Endpoint `json:"endpoints"`
+	// Unlisted are the pods the selector matches that are in no EndpointSlice, most often before they
+	// got an IP.
+	Unlisted []EndpointPod `json:"unlisted,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+}
+
+// Endpoint is an endpoint of an EndpointSlice and its pod, if it is one.
+type Endpoint struct {
+	Slice       string                     `json:"slice"`
+	Addresses   []string                   `json:"addresses"`
+	Ports       []discoveryv1.EndpointPort `json:"ports,omitempty"`
+	Ready       bool                       `json:"ready"`
+	Serving     bool                       `json:"serving"`
+	Terminating bool                       `json:"terminating"`
+	NodeName    string                     `json:"nodeName,omitempty"`
+	Zone        string                     `json:"zone,omitempty"`
+	Pod         *EndpointPod               `json:"pod,omitempty"`
+}
+
+// EndpointPod is the state of the pod behind an endpoint.
+type EndpointPod struct {
+	Name     string          `json:"name"`
+	Phase    corev1.PodPhase `json:"phase"`
+	Ready    bool            `json:"ready"`
+	PodIP    string          `json:"podIP,omitempty"`
+	NodeName string          `json:"nodeName,omitempty"`
+	// the reason of the pod's Ready condition when not ready
+	Reason string `json:"reason,omitempty"`
+}
+
+type ServiceInterface interface {
+	Get(ctx context.Context, namespace, name string) (*corev1.Service, error)
+	Create(ctx context.Context, service *corev1.Service) (*corev1.Service, error)
+	Update(ctx context.Context, namespace, name string, service *corev1.Service) (*corev1.Service, error)
+	Delete(ctx context.Context, namespace, name string) error
+	Endpoints(ctx context.Context, service *corev1.Service) (*ServiceEndpoints, error)
+}
+
+type ServiceOperation struct {
+	clientSet kubernetes.Interface
+}
+
+func NewServiceOperation(client *kubernetes.Clientset) ServiceInterface {
+	return ServiceOperation{
+		clientSet: client,
+	}
+}
+
+func (o ServiceOperation) Get(ctx context.Context, namespace, name string) (*corev1.Service, error) {
+	return o.clientSet.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o ServiceOperation) Create(ctx context.Context, service *corev1.Service) (*corev1.Service, error) {
+	return o.clientSet.CoreV1().Services(service.Namespace).Create(ctx, service, metav1.CreateOptions{})
+}
+
+// Update replaces the service namespace/name with service, keeping the cluster IPs allocated to it
+// when service leaves them empty, since they can't change.
+func (o ServiceOperation) Update(ctx context.Context, namespace, name string, service *corev1.Service) (*corev1.Service, error) {
+	current, err := o.Get(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	service.Namespace, service.Name = namespace, name
+	if service.ResourceVersion == "" {
+		service.ResourceVersion = current.ResourceVersion
+	}
+	if service.Spec.ClusterIP == "" {
+		service.Spec.ClusterIP, service.Spec.ClusterIPs = current.Spec.ClusterIP, current.Spec.ClusterIPs
+	}
+	return o.clientSet.CoreV1().Services(namespace).Update(ctx, service, metav1.UpdateOptions{})
+}
+
+func (o ServiceOperation) Delete(ctx context.Context, namespace, name string) error {
+	return o.clientSet.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (o ServiceOperation) Endpoints(ctx context.Context, service *corev1.Service) (*ServiceEndpoints, error) {
+	slices, err := o.clientSet.DiscoveryV1().EndpointSlices(service.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.Set{discoveryv1.LabelServiceName: service.Name}.String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &ServiceEndpoints{Selector: service.Spec.Selector, Endpoints: []Endpoint{}}
+
+	pods := map[string]*corev1.Pod{}
+	if len(service.Spec.Selector) > 0 {
+		list, err := o.clientSet.CoreV1().Pods(service.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(service.Spec.Selector).String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			pods[list.Items[i].Name] = &list.Items[i]
+		}
+	}
+
+	listed := map[string]bool{}
+	for _, slice := range slices.Items {
+		for _, endpoint := range slice.Endpoints {
+			view := Endpoint{
+				Slice:       slice.Name,
+				Addresses:   endpoint.Addresses,
+				Ports:       slice.Ports,
+				Ready:       endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready,
+				Serving:     endpoint.Conditions.Serving == nil || *endpoint.Conditions.Serving,
+				Terminating: endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating,
+			}
+			if endpoint.NodeName != nil {
+				view.NodeName = *endpoint.NodeName
+			}
+			if endpoint.Zone != nil {
+				view.Zone = *endpoint.Zone
+			}
+			if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+				listed[ref.Name] = true
+				if pod, ok := pods[ref.Name]; ok {
+					view.Pod = endpointPod(pod)
+				} else {
+					view.Pod = &EndpointPod{Name: ref.Name}
+				}
+			}
+			if view.Ready {
+				result.Ready++
+			} else {
+				result.NotReady++
+			}
+			result.Endpoints = append(result.Endpoints, view)
+		}
+	}
+	for name, pod := range pods {
+		if !listed[name] {
+			result.Unlisted = append(result.Unlisted, *endpointPod(pod))
+		}
+	}
+	sort.Slice(result.Unlisted, func(i, j int) bool { return result.Unlisted[i].Name < result.Unlisted[j].Name })
+
+	result.Warnings = endpointsWarnings(service, pods, result)
+	return result, nil
+}
+
+// endpointsWarnings explains the usual reasons why a service has no ready endpoints.
+func endpointsWarnings(service *corev1.Service, pods map[string]*corev1.Pod, endpoints *ServiceEndpoints) []string {
+	var warnings []string
+	switch {
+	case service.Spec.Type == corev1.ServiceTypeExternalName:
+		return []string{"service of type ExternalName has no endpoints"}
+	case len(service.Spec.Selector) == 0:
+		warnings = append(warnings, "service has no selector, its endpoints are managed by hand")
+	case len(pods) == 0:
+		warnings = append(warnings, fmt.Sprintf("no pod matches the selector %s", labels.Set(service.Spec.Selector)))
+	case endpoints.Ready == 0:
+		warnings = append(warnings, fmt.Sprintf("none of the %d pods matching the selector is ready", len(pods)))
+	}
+	for _, port := range service.Spec.Ports {
+		if port.TargetPort.Type != intstr.String || len(pods) == 0 {
+			continue
+		}
+		if !podsDeclarePort(pods, port.TargetPort.StrVal) {
+			warnings = append(warnings, fmt.Sprintf("target port %s of port %d is declared by no container", port.TargetPort.StrVal, port.Port))
+		}
+	}
+	return warnings
+}
+
+func podsDeclarePort(pods map[string]*corev1.Pod, name string) bool {
+	for _, pod := range pods {
+		for _, container := range pod.Spec.Containers {
+			for _, port := range container.Ports {
+				if port.Name == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func endpointPod(pod *corev1.Pod) *EndpointPod {
+	view := &EndpointPod{Name: pod.Name, Phase: pod.Status.Phase, PodIP: pod.Status.PodIP, NodeName: pod.Spec.NodeName}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			view.Ready = condition.Status == corev1.ConditionTrue
+			if !view.Ready {
+				view.Reason = condition.Reason
+			}
+		}
+	}
+	return view
+}
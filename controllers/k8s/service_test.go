@@ -0,0 +1,147 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceEndpoints(t *testing.T) {
+	yes, no := true, false
+	node := "node-1"
+	newPod := func(name string, ready bool, ports ...string) *corev1.Pod {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name, Labels: map[string]string{"app": "web"}},
+			Spec:       corev1.PodSpec{NodeName: node, Containers: []corev1.Container{{Name: "web"}}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		}
+		if !ready {
+			pod.Status.Conditions[0] = corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionFalse, Reason: "ContainersNotReady"}
+		}
+		for _, port := range ports {
+			pod.Spec.Containers[0].Ports = append(pod.Spec.Containers[0].Ports, corev1.ContainerPort{Name: port})
+		}
+		return pod
+	}
+	endpoint := func(pod string, ready *bool) discoveryv1.Endpoint {
+		return discoveryv1.Endpoint{Addresses: []string{"10.0.0.1"}, NodeName: &node,
+			Conditions: discoveryv1.EndpointConditions{Ready: ready},
+			TargetRef:  &corev1.ObjectReference{Kind: "Pod", Name: pod}}
+	}
+	newSlice := func(service string, endpoints ...discoveryv1.Endpoint) *discoveryv1.EndpointSlice {
+		return &discoveryv1.EndpointSlice{
+			ObjectMeta:  metav1.ObjectMeta{Namespace: "shop", Name: service + "-abc", Labels: map[string]string{discoveryv1.LabelServiceName: service}},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   endpoints,
+		}
+	}
+	newService := func(name string, selector map[string]string, targetPort intstr.IntOrString) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+			Spec:       corev1.ServiceSpec{Selector: selector, Ports: []corev1.ServicePort{{Port: 80, TargetPort: targetPort}}},
+		}
+	}
+	selector := map[string]string{"app": "web"}
+
+	tests := []struct {
+		name            string
+		service         *corev1.Service
+		objects         []runtime.Object
+		ready, notReady int
+		pods, unlisted  []string
+		warnings        []string
+	}{
+		{
+			name:    "pods joined",
+			service: newService("web", selector, intstr.FromString("http")),
+			objects: []runtime.Object{newPod("web-1", true, "http"), newPod("web-2", false, "http"), newPod("web-3", false, "http"),
+				newSlice("web", endpoint("web-1", &yes), endpoint("web-2", &no), endpoint("web-gone", nil))},
+			ready: 2, notReady: 1, pods: []string{"web-1", "web-2", "web-gone"}, unlisted: []string{"web-3"},
+		},
+		{
+			name:     "no pod ready",
+			service:  newService("web", selector, intstr.FromInt(8080)),
+			objects:  []runtime.Object{newPod("web-1", false), newSlice("web", endpoint("web-1", &no))},
+			notReady: 1, pods: []string{"web-1"},
+			warnings: []string{"none of the 1 pods matching the selector is ready"},
+		},
+		{
+			name:    "target port not declared",
+			service: newService("web", selector, intstr.FromString("http")),
+			objects: []runtime.Object{newPod("web-1", true, "metrics"), newSlice("web", endpoint("web-1", &yes))},
+			ready:   1, pods: []string{"web-1"},
+			warnings: []string{"target port http of port 80 is declared by no container"},
+		},
+		{
+			name:     "no pod matches",
+			service:  newService("web", map[string]string{"app": "api"}, intstr.FromString("http")),
+			objects:  []runtime.Object{newPod("web-1", true, "http")},
+			warnings: []string{"no pod matches the selector app=api"},
+		},
+		{
+			name:    "no selector",
+			service: newService("db", nil, intstr.FromInt(5432)),
+			objects: []runtime.Object{newPod("web-1", true), newSlice("db", discoveryv1.Endpoint{Addresses: []string{"192.168.1.10"}})},
+			ready:   1, pods: []string{""},
+			warnings: []string{"service has no selector, its endpoints are managed by hand"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			operation := ServiceOperation{clientSet: fake.NewSimpleClientset(tt.objects...)}
+			result, err := operation.Endpoints(context.Background(), tt.service)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Ready != tt.ready || result.NotReady != tt.notReady {
+				t.Errorf("%d ready, %d not ready, want %d, %d", result.Ready, result.NotReady, tt.ready, tt.notReady)
+			}
+			var pods, unlisted []string
+			for _, endpoint := range result.Endpoints {
+				name := ""
+				if endpoint.Pod != nil {
+					name = endpoint.Pod.Name
+				}
+				pods = append(pods, name)
+			}
+			for _, pod := range result.Unlisted {
+				unlisted = append(unlisted, pod.Name)
+			}
+			if !reflect.DeepEqual(pods, tt.pods) || !reflect.DeepEqual(unlisted, tt.unlisted) {
+				t.Errorf("pods %q, unlisted %q, want %q, %q", pods, unlisted, tt.pods, tt.unlisted)
+			}
+			if !reflect.DeepEqual(result.Warnings, tt.warnings) {
+				t.Errorf("warnings = %q, want %q", result.Warnings, tt.warnings)
+			}
+		})
+	}
+
+	// the pod of an endpoint carries its state, and the reason it is not ready.
+	operation := ServiceOperation{clientSet: fake.NewSimpleClientset(newPod("web-2", false), newSlice("web", endpoint("web-2", &no)))}
+	result, err := operation.Endpoints(context.Background(), newService("web", selector, intstr.FromInt(8080)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EndpointPod{Name: "web-2", Phase: corev1.PodRunning, NodeName: node, Reason: "ContainersNotReady"}
+	if got := result.Endpoints[0].Pod; !reflect.DeepEqual(got, want) {
+		t.Errorf("pod = %+v, want %+v", got, want)
+	}
+
+	// an ExternalName service has nothing behind it.
+	external := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "mail"},
+		Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeExternalName, ExternalName: "mail.example.com"}}
+	result, err = ServiceOperation{clientSet: fake.NewSimpleClientset()}.Endpoints(context.Background(), external)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Endpoints) != 0 || !reflect.DeepEqual(result.Warnings, []string{"service of type ExternalName has no endpoints"}) {
+		t.Errorf("endpoints = %+v", result)
+	}
+}
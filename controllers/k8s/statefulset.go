@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// RestartedAtAnnotation restarts the pods of a workload when its value changes, as kubectl rollout
+// restart does.
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+type StatefulSetInterface interface {
+	Get(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error)
+	Create(ctx context.Context, statefulSet *appsv1.StatefulSet) (*appsv1.StatefulSet, error)
+	Update(ctx context.Context, namespace, name string, statefulSet *appsv1.StatefulSet) (*appsv1.StatefulSet, error)
+	Delete(ctx context.Context, namespace, name string) error
+	Scale(ctx context.Context, namespace, name string, replicas int32) error
+	Restart(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error)
+	// Pods returns the pods the StatefulSet controls.
+	Pods(ctx context.Context, statefulSet *appsv1.StatefulSet) (*corev1.PodList, error)
+}
+
+type StatefulSetOperation struct {
+	clientSet *kubernetes.Clientset
+}
+
+func NewStatefulSetOperation(client *kubernetes.Clientset) StatefulSetInterface {
+	return StatefulSetOperation{
+		clientSet: client,
+	}
+}
+
+func (o StatefulSetOperation) Get(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error) {
+	return o.clientSet.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o StatefulSetOperation) Create(ctx context.Context, statefulSet *appsv1.StatefulSet) (*appsv1.StatefulSet, error) {
+	return o.clientSet.AppsV1().StatefulSets(statefulSet.Namespace).Create(ctx, statefulSet, metav1.CreateOptions{})
+}
+
+// Update replaces the StatefulSet namespace/name with statefulSet.
+func (o StatefulSetOperation) Update(ctx context.Context, namespace, name string, statefulSet *appsv1.StatefulSet) (*appsv1.StatefulSet, error) {
+	statefulSet.Namespace, statefulSet.Name = namespace, name
+	return o.clientSet.AppsV1().StatefulSets(namespace).Update(ctx, statefulSet, metav1.UpdateOptions{})
+}
+
+func (o StatefulSetOperation) Delete(ctx context.Context, namespace, name string) error {
+	return o.clientSet.AppsV1().StatefulSets(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (o StatefulSetOperation) Scale(ctx context.Context, namespace, name string, replicas int32) error {
+	scale, err := o.clientSet.AppsV1().StatefulSets(namespace).GetScale(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	scale.Spec.Replicas = replicas
+	_, err = o.clientSet.AppsV1().StatefulSets(namespace).UpdateScale(ctx, name, scale, metav1.UpdateOptions{})
+	return err
+}
+
+func (o StatefulSetOperation) Restart(ctx context.Context, namespace, name string) (*appsv1.StatefulSet, error) {
+	patch, err := restartPatch()
+	if err != nil {
+		return nil, err
+	}
+	return o.clientSet.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
+}
+
+func (o StatefulSetOperation) Pods(ctx context.Context, statefulSet *appsv1.StatefulSet) (*corev1.PodList, error) {
+	return controlledPods(ctx, o.clientSet, statefulSet, statefulSet.Spec.Selector)
+}
+
+// restartPatch changes the RestartedAtAnnotation of a pod template.
+func restartPatch() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{RestartedAtAnnotation: time.Now().Format(time.RFC3339)},
+				},
+			},
+		},
+	})
+}
+
+// controlledPods returns the pods matching selector that owner controls.
+func controlledPods(ctx context.Context, client kubernetes.Interface, owner metav1.Object, selector *metav1.LabelSelector) (*corev1.PodList, error) {
+	pods, err := client.CoreV1().Pods(owner.GetNamespace()).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+	})
+	if err != nil {
+		return nil, err
+	}
+	controlled := pods.Items[:0]
+	for _, pod := range pods.Items {
+		if ref := metav1.GetControllerOf(&pod); ref != nil && ref.UID == owner.GetUID() {
+			controlled = append(controlled, pod)
+		}
+	}
+	pods.Items = controlled
+	return pods, nil
+}
@@ -0,0 +1,230 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// claimEvents is the number of events kept for each Pending claim of a StorageSummary.
+const claimEvents = 5
+
+// StorageSummary is the storage of a namespace: its PersistentVolumeClaims and the pods mounting them.
+type StorageSummary struct {
+	Namespace string `json:"namespace"`
+	Claims    int    `json:"claims"`
+	Bound     int    `json:"bound"`
+	Pending   int    `json:"pending"`
+	Lost      int    `json:"lost"`
+	// Requested sums the storage requested by the claims, Capacity the one of their bound volumes.
+	Requested resource.Quantity `json:"requested"`
+	Capacity  resource.Quantity `json:"capacity"`
+	Items     []ClaimSummary    `json:"items"`
+}
+
+// ClaimSummary is a PersistentVolumeClaim, the pods mounting it and, when Pending, its latest events.
+type ClaimSummary struct {
+	Name         string                                  `json:"name"`
+	Phase        corev1.PersistentVolumeClaimPhase       `json:"phase"`
+	StorageClass string                                  `json:"storageClass,omitempty"`
+	VolumeName   string                                  `json:"volumeName,omitempty"`
+	AccessModes  []corev1.PersistentVolumeAccessMode     `json:"accessModes,omitempty"`
+	Requested    resource.Quantity                       `json:"requested"`
+	Capacity     resource.Quantity                       `json:"capacity"`
+	Conditions   []corev1.PersistentVolumeClaimCondition `json:"conditions,omitempty"`
+	Pods         []string                                `json:"pods"`
+	Events       []corev1.Event                          `json:"events,omitempty"`
+}
+
+type StorageInterface interface {
+	ListClaims(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PersistentVolumeClaimList, error)
+	GetClaim(ctx context.Context, namespace, name string) (*corev1.PersistentVolumeClaim, error)
+	CreateClaim(ctx context.Context, claim *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error)
+	DeleteClaim(ctx context.Context, namespace, name string) error
+	// ExpandClaim raises the storage requested by a claim to size, which its storage class must allow.
+	ExpandClaim(ctx context.Context, namespace, name string, size resource.Quantity) (*corev1.PersistentVolumeClaim, error)
+	ListVolumes(ctx context.Context, opts metav1.ListOptions) (*corev1.PersistentVolumeList, error)
+	ListClasses(ctx context.Context, opts metav1.ListOptions) (*storagev1.StorageClassList, error)
+	Summary(ctx context.Context, namespace string) (*StorageSummary, error)
+}
+
+type StorageOperation struct {
+	clientSet kubernetes.Interface
+}
+
+func NewStorageOperation(client *kubernetes.Clientset) StorageInterface {
+	return StorageOperation{
+		clientSet: client,
+	}
+}
+
+func (o StorageOperation) ListClaims(ctx context.Context, namespace string, opts metav1.ListOptions) (*corev1.PersistentVolumeClaimList, error) {
+	return o.clientSet.CoreV1().PersistentVolumeClaims(namespace).List(ctx, opts)
+}
+
+func (o StorageOperation) GetClaim(ctx context.Context, namespace, name string) (*corev1.PersistentVolumeClaim, error) {
+	return o.clientSet.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+func (o StorageOperation) CreateClaim(ctx context.Context, claim *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	return o.clientSet.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(ctx, claim, metav1.CreateOptions{})
+}
+
+func (o StorageOperation) DeleteClaim(ctx context.Context, namespace, name string) error {
+	return o.clientSet.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{})
+}
+
+func (o StorageOperation) ExpandClaim(ctx context.Context, namespace, name string, size resource.Quantity) (*corev1.PersistentVolumeClaim, error) {
+	claim, err := o.GetClaim(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if claim.Status.Phase != corev1.ClaimBound {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("claim %s is %s, only bound claims can be expanded", name, claim.Status.Phase))
+	}
+	if requested := claim.Spec.Resources.Requests[corev1.ResourceStorage]; size.Cmp(requested) <= 0 {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("claim %s already requests %s, it can only grow", name, requested.String()))
+	}
+	if class := claim.Spec.StorageClassName; class != nil && *class != "" {
+		storageClass, err := o.clientSet.StorageV1().StorageClasses().Get(ctx, *class, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+			return nil, apierrors.NewBadRequest(fmt.Sprintf("storage class %s does not allow volume expansion", *class))
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"requests": map[string]string{string(corev1.ResourceStorage): size.String()},
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.clientSet.CoreV1().PersistentVolumeClaims(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{})
+}
+
+func (o StorageOperation) ListVolumes(ctx context.Context, opts metav1.ListOptions) (*corev1.PersistentVolumeList, error) {
+	return o.clientSet.CoreV1().PersistentVolumes().List(ctx, opts)
+}
+
+func (o StorageOperation) ListClasses(ctx context.Context, opts metav1.ListOptions) (*storagev1.StorageClassList, error) {
+	return o.clientSet.StorageV1().StorageClasses().List(ctx, opts)
+}
+
+func (o StorageOperation) Summary(ctx context.Context, namespace string) (*StorageSummary, error) {
+	claims, err := o.ListClaims(ctx, namespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := o.clientSet.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	mounting := map[string][]string{}
+	for _, pod := range pods.Items {
+		for _, volume := range pod.Spec.Volumes {
+			switch {
+			case volume.PersistentVolumeClaim != nil:
+				mounting[volume.PersistentVolumeClaim.ClaimName] = append(mounting[volume.PersistentVolumeClaim.ClaimName], pod.Name)
+			case volume.Ephemeral != nil:
+				// the claim of a generic ephemeral volume is named after its pod and volume
+				claim := pod.Name + "-" + volume.Name
+				mounting[claim] = append(mounting[claim], pod.Name)
+			}
+		}
+	}
+
+	summary := &StorageSummary{Namespace: namespace, Items: make([]ClaimSummary, 0, len(claims.Items))}
+	pending := false
+	for _, claim := range claims.Items {
+		item := ClaimSummary{
+			Name:        claim.Name,
+			Phase:       claim.Status.Phase,
+			VolumeName:  claim.Spec.VolumeName,
+			AccessModes: claim.Spec.AccessModes,
+			Requested:   claim.Spec.Resources.Requests[corev1.ResourceStorage],
+			Capacity:    claim.Status.Capacity[corev1.ResourceStorage],
+			Conditions:  claim.Status.Conditions,
+			Pods:        mounting[claim.Name],
+		}
+		if item.Pods == nil {
+			item.Pods = []string{}
+		}
+		if claim.Spec.StorageClassName != nil {
+			item.StorageClass = *claim.Spec.StorageClassName
+		}
+		switch claim.Status.Phase {
+		case corev1.ClaimBound:
+			summary.Bound++
+		case corev1.ClaimPending:
+			summary.Pending++
+			pending = true
+		case corev1.ClaimLost:
+			summary.Lost++
+		}
+		summary.Requested.Add(item.Requested)
+		summary.Capacity.Add(item.Capacity)
+		summary.Items = append(summary.Items, item)
+	}
+	summary.Claims = len(summary.Items)
+
+	if pending {
+		events, err := o.claimEvents(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		for i := range summary.Items {
+			if summary.Items[i].Phase == corev1.ClaimPending {
+				summary.Items[i].Events = events[summary.Items[i].Name]
+			}
+		}
+	}
+	return summary, nil
+}
+
+// claimEvents returns the latest events of the claims of namespace, by claim name, most recent first.
+func (o StorageOperation) claimEvents(ctx context.Context, namespace string) (map[string][]corev1.Event, error) {
+	events, err := o.clientSet.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fields.OneTermEqualSelector("involvedObject.kind", "PersistentVolumeClaim").String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(&events.Items[i]).After(eventTime(&events.Items[j]).Time)
+	})
+	byClaim := map[string][]corev1.Event{}
+	for _, event := range events.Items {
+		name := event.InvolvedObject.Name
+		if len(byClaim[name]) < claimEvents {
+			byClaim[name] = append(byClaim[name], event)
+		}
+	}
+	return byClaim, nil
+}
+
+// eventTime is when event last happened, whichever of its times is set.
+func eventTime(event *corev1.Event) metav1.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp
+	case !event.EventTime.IsZero():
+		return metav1.NewTime(event.EventTime.Time)
+	default:
+		return event.CreationTimestamp
+	}
+}
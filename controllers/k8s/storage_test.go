@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newTestClaim(name string, phase corev1.PersistentVolumeClaimPhase, requested, class string) *corev1.PersistentVolumeClaim {
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: name},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(requested)}},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: phase},
+	}
+	if class != "" {
+		claim.Spec.StorageClassName = &class
+	}
+	if phase == corev1.ClaimBound {
+		claim.Spec.VolumeName = "pv-" + name
+		claim.Status.Capacity = corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(requested)}
+	}
+	return claim
+}
+
+func TestStorageExpandClaim(t *testing.T) {
+	expandable, fixed := true, false
+	objects := []runtime.Object{
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "fast"}, AllowVolumeExpansion: &expandable},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "slow"}, AllowVolumeExpansion: &fixed},
+		newTestClaim("data", corev1.ClaimBound, "10Gi", "fast"),
+		newTestClaim("logs", corev1.ClaimBound, "10Gi", "slow"),
+		newTestClaim("static", corev1.ClaimBound, "10Gi", ""),
+		newTestClaim("lost", corev1.ClaimBound, "10Gi", "gone"),
+		newTestClaim("cache", corev1.ClaimPending, "10Gi", "fast"),
+	}
+	tests := []struct {
+		claim string
+		size  string
+		err   func(error) bool
+	}{
+		{claim: "data", size: "20Gi"},
+		{claim: "static", size: "20Gi"},
+		{claim: "data", size: "10Gi", err: apierrors.IsBadRequest},
+		{claim: "data", size: "5Gi", err: apierrors.IsBadRequest},
+		{claim: "cache", size: "20Gi", err: apierrors.IsBadRequest},
+		{claim: "logs", size: "20Gi", err: apierrors.IsBadRequest},
+		{claim: "lost", size: "20Gi", err: apierrors.IsNotFound},
+		{claim: "missing", size: "20Gi", err: apierrors.IsNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.claim+" to "+tt.size, func(t *testing.T) {
+			o := StorageOperation{clientSet: fake.NewSimpleClientset(objects...)}
+			claim, err := o.ExpandClaim(context.Background(), "shop", tt.claim, resource.MustParse(tt.size))
+			if tt.err != nil {
+				if !tt.err(err) {
+					t.Fatalf("err = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if requested := claim.Spec.Resources.Requests[corev1.ResourceStorage]; requested.String() != tt.size {
+				t.Errorf("requested = %s, want %s", requested.String(), tt.size)
+			}
+		})
+	}
+}
+
+func TestStorageSummary(t *testing.T) {
+	base := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	claimEvent := func(claim string, i int) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Namespace: "shop", Name: fmt.Sprintf("%s.%d", claim, i)},
+			InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Namespace: "shop", Name: claim},
+			Reason:         fmt.Sprintf("Provisioning%d", i),
+			LastTimestamp:  metav1.NewTime(base.Add(time.Duration(i) * time.Minute)),
+		}
+	}
+	objects := []runtime.Object{
+		newTestClaim("data", corev1.ClaimBound, "10Gi", "fast"),
+		newTestClaim("web-0-scratch", corev1.ClaimBound, "1Gi", "fast"),
+		newTestClaim("cache", corev1.ClaimPending, "2Gi", ""),
+		newTestClaim("old", corev1.ClaimLost, "5Gi", "fast"),
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-0"}, Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+			{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+			{Name: "scratch", VolumeSource: corev1.VolumeSource{Ephemeral: &corev1.EphemeralVolumeSource{}}},
+			{Name: "tmp", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		}}},
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "shop", Name: "web-1"}, Spec: corev1.PodSpec{Volumes: []corev1.Volume{
+			{Name: "data", VolumeSource: corev1.VolumeSource{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+		}}},
+		claimEvent("data", 9),
+	}
+	for i := 0; i < claimEvents+2; i++ {
+		objects = append(objects, claimEvent("cache", i))
+	}
+	// an event with only its event time set is ordered by it.
+	latest := claimEvent("cache", 99)
+	latest.LastTimestamp = metav1.Time{}
+	latest.EventTime = metav1.NewMicroTime(base.Add(time.Hour))
+	objects = append(objects, latest)
+
+	o := StorageOperation{clientSet: fake.NewSimpleClientset(objects...)}
+	summary, err := o.Summary(context.Background(), "shop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Claims != 4 || summary.Bound != 2 || summary.Pending != 1 || summary.Lost != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	if summary.Requested.String() != "18Gi" || summary.Capacity.String() != "11Gi" {
+		t.Errorf("requested = %s, capacity = %s", summary.Requested.String(), summary.Capacity.String())
+	}
+
+	items := map[string]ClaimSummary{}
+	for _, item := range summary.Items {
+		items[item.Name] = item
+	}
+	pods := map[string][]string{"data": {"web-0", "web-1"}, "web-0-scratch": {"web-0"}, "cache": {}, "old": {}}
+	for name, want := range pods {
+		if !reflect.DeepEqual(items[name].Pods, want) {
+			t.Errorf("pods of %s = %v, want %v", name, items[name].Pods, want)
+		}
+	}
+	if items["data"].StorageClass != "fast" || items["data"].VolumeName != "pv-data" || items["cache"].StorageClass != "" {
+		t.Errorf("items = %+v", summary.Items)
+	}
+
+	// only pending claims get their events, the latest first.
+	if items["data"].Events != nil {
+		t.Errorf("events of a bound claim: %v", items["data"].Events)
+	}
+	var reasons []string
+	for _, event := range items["cache"].Events {
+		reasons = append(reasons, event.Reason)
+	}
+	want := []string{"Provisioning99", "Provisioning6", "Provisioning5", "Provisioning4", "Provisioning3"}
+	if !reflect.DeepEqual(reasons, want) {
+		t.Errorf("events of cache = %v, want %v", reasons, want)
+	}
+}
@@ -0,0 +1,128 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/utils"
+	"github.com/marmotedu/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
+
+// Authenticator validates a bearer token and returns who it belongs to. ok is false when the token
+// is not recognized by this authenticator, err is only set when it failed to decide.
+type Authenticator interface {
+	AuthenticateToken(ctx context.Context, token string) (user *authenticationv1.UserInfo, ok bool, err error)
+}
+
+// unionAuthenticator tries each authenticator in turn, the first one recognizing the token wins.
+type unionAuthenticator []Authenticator
+
+func (u unionAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticationv1.UserInfo, bool, error) {
+	var errs []string
+	for _, authenticator := range u {
+		user, ok, err := authenticator.AuthenticateToken(ctx, token)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if ok {
+			return user, true, nil
+		}
+	}
+	if len(errs) != 0 {
+		return nil, false, errors.New(strings.Join(errs, "; "))
+	}
+	return nil, false, nil
+}
+
+// NewAuthenticatorFromEnv builds the authenticators named in AUTH_AUTHENTICATORS, a comma separated list
+// of "tokenreview", "static" and "oidc". "none" turns authentication off and returns nil.
+func NewAuthenticatorFromEnv() (Authenticator, error) {
+	var authenticators unionAuthenticator
+	for _, name := range strings.Split(utils.GetEnvDefault("AUTH_AUTHENTICATORS", "tokenreview"), ",") {
+		switch strings.TrimSpace(name) {
+		case "none":
+			return nil, nil
+		case "tokenreview":
+			authenticators = append(authenticators, NewTokenReviewAuthenticator())
+		case "static":
+			authenticator, err := NewStaticTokenAuthenticator(utils.GetEnvDefault("AUTH_STATIC_TOKEN_FILE", "/etc/nightwatcher/tokens.csv"))
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, authenticator)
+		case "oidc":
+			authenticator, err := NewOIDCAuthenticator(OIDCOptions{
+				IssuerURL:     utils.GetEnvDefault("AUTH_OIDC_ISSUER_URL", ""),
+				ClientID:      utils.GetEnvDefault("AUTH_OIDC_CLIENT_ID", ""),
+				UsernameClaim: utils.GetEnvDefault("AUTH_OIDC_USERNAME_CLAIM", "sub"),
+				GroupsClaim:   utils.GetEnvDefault("AUTH_OIDC_GROUPS_CLAIM", "groups"),
+			})
+			if err != nil {
+				return nil, err
+			}
+			authenticators = append(authenticators, authenticator)
+		case "":
+		default:
+			return nil, errors.Errorf("unknown authenticator %q", name)
+		}
+	}
+	if len(authenticators) == 0 {
+		return nil, errors.New("no authenticator configured, set AUTH_AUTHENTICATORS=none to turn authentication off")
+	}
+	return authenticators, nil
+}
+
+// Authenticate rejects requests without a valid bearer token and stores the caller in the request context.
+// A nil authenticator lets every request through.
+func Authenticate(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authenticator == nil {
+			c.Next()
+			return
+		}
+		appG := app.Gin{C: c}
+
+		token, err := bearerToken(c.Request)
+		if err != nil {
+			appG.FailWithCode(err, nil)
+			return
+		}
+		user, ok, err := authenticator.AuthenticateToken(c.Request.Context(), token)
+		if err != nil {
+			klog.Errorf("Failed to authenticate request %s %s: %v", c.Request.Method, c.Request.URL.Path, err)
+			appG.FailWithCode(errors.WithCode(utils.ErrTokenInvalid, err.Error()), nil)
+			return
+		}
+		if !ok {
+			appG.FailWithCode(errors.WithCode(utils.ErrTokenInvalid, "token is not recognized"), nil)
+			return
+		}
+		c.Request = c.Request.WithContext(utils.WithUser(c.Request.Context(), user))
+		c.Next()
+	}
+}
+
+// bearerToken extracts the token from the Authorization header. Browsers can't set headers on
+// websocket handshakes, so those may pass it as the access_token query parameter instead.
+func bearerToken(req *http.Request) (string, error) {
+	header := req.Header.Get("Authorization")
+	if header == "" {
+		if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+			if token := req.URL.Query().Get("access_token"); token != "" {
+				return token, nil
+			}
+		}
+		return "", errors.WithCode(utils.ErrMissingHeader, "the Authorization header was empty")
+	}
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") || strings.TrimSpace(parts[1]) == "" {
+		return "", errors.WithCode(utils.ErrInvalidAuthHeader, "the Authorization header is not a bearer token")
+	}
+	return strings.TrimSpace(parts[1]), nil
+}
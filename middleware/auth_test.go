@@ -0,0 +1,223 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/utils"
+	codes "github.com/marmotedu/errors"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		ws     bool
+		want   string
+		code   int
+	}{
+		{name: "bearer", header: "Bearer abc", want: "abc"},
+		{name: "scheme is case insensitive", header: "bearer abc", want: "abc"},
+		{name: "surrounding spaces", header: "Bearer   abc  ", want: "abc"},
+		{name: "missing", code: utils.ErrMissingHeader},
+		{name: "basic", header: "Basic YWxhZGRpbjpvcGVuc2VzYW1l", code: utils.ErrInvalidAuthHeader},
+		{name: "no token", header: "Bearer ", code: utils.ErrInvalidAuthHeader},
+		{name: "no scheme", header: "abc", code: utils.ErrInvalidAuthHeader},
+		{name: "websocket query", query: "abc", ws: true, want: "abc"},
+		{name: "websocket header wins", header: "Bearer abc", query: "def", ws: true, want: "abc"},
+		{name: "query outside websocket", query: "abc", code: utils.ErrMissingHeader},
+		{name: "websocket without token", ws: true, code: utils.ErrMissingHeader},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/clusters", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.query != "" {
+				req.URL.RawQuery = "access_token=" + tt.query
+			}
+			if tt.ws {
+				req.Header.Set("Upgrade", "websocket")
+			}
+
+			got, err := bearerToken(req)
+			if tt.code != 0 {
+				if !codes.IsCode(err, tt.code) {
+					t.Fatalf("err = %v, want code %d", err, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("token = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAuthenticator recognizes a single token.
+type fakeAuthenticator struct {
+	token string
+	user  *authenticationv1.UserInfo
+	err   error
+}
+
+func (f *fakeAuthenticator) AuthenticateToken(_ context.Context, token string) (*authenticationv1.UserInfo, bool, error) {
+	if f.err != nil {
+		return nil, false, f.err
+	}
+	if token != f.token {
+		return nil, false, nil
+	}
+	return f.user, true, nil
+}
+
+func TestUnionAuthenticator(t *testing.T) {
+	alice := &authenticationv1.UserInfo{Username: "alice"}
+	bob := &authenticationv1.UserInfo{Username: "bob"}
+	failing := &fakeAuthenticator{err: errors.New("apiserver unreachable")}
+
+	tests := []struct {
+		name  string
+		union unionAuthenticator
+		token string
+		want  *authenticationv1.UserInfo
+		err   bool
+	}{
+		{name: "first wins", union: unionAuthenticator{&fakeAuthenticator{"t", alice, nil}, &fakeAuthenticator{"t", bob, nil}},
+			token: "t", want: alice},
+		{name: "falls through", union: unionAuthenticator{&fakeAuthenticator{"a", alice, nil}, &fakeAuthenticator{"b", bob, nil}},
+			token: "b", want: bob},
+		{name: "errors are skipped", union: unionAuthenticator{failing, &fakeAuthenticator{"b", bob, nil}},
+			token: "b", want: bob},
+		{name: "unrecognized", union: unionAuthenticator{&fakeAuthenticator{"a", alice, nil}},
+			token: "b"},
+		{name: "unrecognized with errors", union: unionAuthenticator{failing, &fakeAuthenticator{"a", alice, nil}},
+			token: "b", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, ok, err := tt.union.AuthenticateToken(context.Background(), tt.token)
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if ok != (tt.want != nil) || user != tt.want {
+				t.Errorf("user = %v, %v, want %v", user, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticatorFromEnv(t *testing.T) {
+	tests := []struct {
+		name           string
+		authenticators string
+		oidcIssuer     string
+		err            bool
+		none           bool
+		want           int
+	}{
+		{name: "default", want: 1},
+		{name: "none", authenticators: "none", none: true},
+		{name: "several", authenticators: "tokenreview, oidc", oidcIssuer: "https://issuer.example.com", want: 2},
+		{name: "oidc without issuer", authenticators: "oidc", err: true},
+		{name: "static without file", authenticators: "static", err: true},
+		{name: "unknown", authenticators: "ldap", err: true},
+		{name: "empty", authenticators: ",", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.authenticators != "" {
+				t.Setenv("AUTH_AUTHENTICATORS", tt.authenticators)
+			}
+			t.Setenv("AUTH_OIDC_ISSUER_URL", tt.oidcIssuer)
+			t.Setenv("AUTH_OIDC_CLIENT_ID", "nightwatcher")
+			t.Setenv("AUTH_STATIC_TOKEN_FILE", t.TempDir()+"/missing.csv")
+
+			authenticator, err := NewAuthenticatorFromEnv()
+			if (err != nil) != tt.err {
+				t.Fatalf("err = %v, want error %v", err, tt.err)
+			}
+			if tt.err {
+				return
+			}
+			if tt.none {
+				if authenticator != nil {
+					t.Fatalf("authenticator = %v, want none", authenticator)
+				}
+				return
+			}
+			if got := len(authenticator.(unionAuthenticator)); got != tt.want {
+				t.Errorf("%d authenticators, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	alice := &authenticationv1.UserInfo{Username: "alice", Groups: []string{"dev"}}
+
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		header        string
+		status        int
+		code          int
+		user          *authenticationv1.UserInfo
+	}{
+		{name: "authenticated", authenticator: &fakeAuthenticator{"t", alice, nil}, header: "Bearer t",
+			status: http.StatusOK, user: alice},
+		{name: "disabled", header: "", status: http.StatusOK},
+		{name: "missing token", authenticator: &fakeAuthenticator{"t", alice, nil},
+			status: http.StatusUnauthorized, code: utils.ErrMissingHeader},
+		{name: "unknown token", authenticator: &fakeAuthenticator{"t", alice, nil}, header: "Bearer x",
+			status: http.StatusUnauthorized, code: utils.ErrTokenInvalid},
+		{name: "failing authenticator", authenticator: &fakeAuthenticator{err: errors.New("down")}, header: "Bearer t",
+			status: http.StatusUnauthorized, code: utils.ErrTokenInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var user *authenticationv1.UserInfo
+			r := gin.New()
+			r.GET("/api/v1/clusters", Authenticate(tt.authenticator), func(c *gin.Context) {
+				user, _ = utils.UserFrom(c.Request.Context())
+				c.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/clusters", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			if tt.code != 0 {
+				var resp app.Response
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatal(err)
+				}
+				if resp.Code != tt.code {
+					t.Errorf("code = %d, want %d", resp.Code, tt.code)
+				}
+			}
+			if !reflect.DeepEqual(user, tt.user) {
+				t.Errorf("user = %v, want %v", user, tt.user)
+			}
+		})
+	}
+}
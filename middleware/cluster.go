@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lmxia/nightwatcher/app"
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	"github.com/lmxia/nightwatcher/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// TargetCluster sends the request to the ManagedCluster named by the :cluster path parameter.
+func TargetCluster() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appG := app.Gin{C: c}
+
+		cluster := c.Param("cluster")
+		if _, err := k8s.GetClusterClient(c.Request.Context(), cluster); err != nil {
+			if apierrors.IsNotFound(err) {
+				appG.Fail(http.StatusNotFound, err, nil)
+			} else {
+				appG.Fail(http.StatusBadGateway, err, nil)
+			}
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(utils.WithCluster(c.Request.Context(), cluster))
+		c.Next()
+	}
+}
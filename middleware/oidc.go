@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/klog/v2"
+)
+
+// OIDCOptions configures the OIDC JWT authenticator.
+type OIDCOptions struct {
+	// IssuerURL must match the iss claim, the signing keys are discovered from it.
+	IssuerURL string
+	// ClientID must be one of the aud claim values.
+	ClientID      string
+	UsernameClaim string
+	GroupsClaim   string
+}
+
+// OIDCAuthenticator validates ID tokens signed by an OpenID Connect provider.
+type OIDCAuthenticator struct {
+	opts   OIDCOptions
+	client *http.Client
+
+	mu sync.Mutex
+	// verifier is set up on first use, so an unreachable provider doesn't keep the api from starting.
+	verifier *oidc.IDTokenVerifier
+}
+
+func NewOIDCAuthenticator(opts OIDCOptions) (*OIDCAuthenticator, error) {
+	if opts.IssuerURL == "" || opts.ClientID == "" {
+		return nil, errors.New("oidc authenticator requires AUTH_OIDC_ISSUER_URL and AUTH_OIDC_CLIENT_ID")
+	}
+	return &OIDCAuthenticator{
+		opts:   opts,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (a *OIDCAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticationv1.UserInfo, bool, error) {
+	if issuer, ok := unverifiedIssuer(token); !ok || issuer != a.opts.IssuerURL {
+		// not one of our ID tokens, leave it to the other authenticators.
+		return nil, false, nil
+	}
+	verifier, err := a.idTokenVerifier()
+	if err != nil {
+		return nil, false, err
+	}
+	idToken, err := verifier.Verify(ctx, token)
+	if err != nil {
+		klog.V(2).Infof("Rejected oidc token: %v", err)
+		return nil, false, nil
+	}
+	claims := make(map[string]interface{})
+	if err = idToken.Claims(&claims); err != nil {
+		return nil, false, nil
+	}
+
+	username, _ := claims[a.opts.UsernameClaim].(string)
+	if username == "" {
+		return nil, false, nil
+	}
+	if a.opts.UsernameClaim == "email" {
+		// anyone may claim an address they don't own, only trust it once the provider verified it.
+		if verified, _ := claims["email_verified"].(bool); !verified {
+			klog.V(2).Infof("Rejected oidc token of %s: email is not verified", username)
+			return nil, false, nil
+		}
+	} else {
+		// the same prefixing the apiserver does, so names can't collide with other authenticators.
+		username = a.opts.IssuerURL + "#" + username
+	}
+	user := &authenticationv1.UserInfo{Username: username, UID: idToken.Subject}
+	switch groups := claims[a.opts.GroupsClaim].(type) {
+	case string:
+		user.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if g, ok := group.(string); ok {
+				user.Groups = append(user.Groups, g)
+			}
+		}
+	}
+	return user, true, nil
+}
+
+// idTokenVerifier discovers the provider on first use, failed discoveries are retried on the next token.
+func (a *OIDCAuthenticator) idTokenVerifier() (*oidc.IDTokenVerifier, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.verifier != nil {
+		return a.verifier, nil
+	}
+	// the provider keeps this context to refresh its key set, so it must outlive the request.
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), a.client), a.opts.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discover %s failed: %v", a.opts.IssuerURL, err)
+	}
+	a.verifier = provider.Verifier(&oidc.Config{ClientID: a.opts.ClientID})
+	return a.verifier, nil
+}
+
+// unverifiedIssuer reads the iss claim of token without verifying it, ok is false when token is no JWT.
+func unverifiedIssuer(token string) (string, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", false
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return "", false
+	}
+	return claims.Issuer, true
+}
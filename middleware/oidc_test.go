@@ -0,0 +1,209 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+const oidcClientID = "nightwatcher"
+
+// oidcProvider is an httptest OpenID Connect provider serving the public halves of its keys.
+type oidcProvider struct {
+	*httptest.Server
+	keys        map[string]jose.SigningKey
+	discoveries int32
+	down        int32
+}
+
+func newOIDCProvider(t *testing.T) *oidcProvider {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &oidcProvider{keys: map[string]jose.SigningKey{
+		"rsa": {Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: rsaKey, KeyID: "rsa"}},
+		"ec":  {Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: ecKey, KeyID: "ec"}},
+	}}
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+		{Key: &rsaKey.PublicKey, KeyID: "rsa", Algorithm: string(jose.RS256), Use: "sig"},
+		{Key: &ecKey.PublicKey, KeyID: "ec", Algorithm: string(jose.ES256), Use: "sig"},
+	}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.discoveries, 1)
+		if atomic.LoadInt32(&p.down) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                p.URL,
+			"jwks_uri":                              p.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256", "ES256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(set)
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// sign returns an ID token with claims signed by the key kid, defaulting iss, aud and exp.
+func (p *oidcProvider) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	defaults := map[string]interface{}{
+		"iss": p.URL,
+		"aud": oidcClientID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"sub": "1234",
+	}
+	for k, v := range defaults {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+	signer, err := jose.NewSigner(p.keys[kid], (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestOIDCAuthenticator(t *testing.T) {
+	provider := newOIDCProvider(t)
+	other := newOIDCProvider(t)
+
+	tests := []struct {
+		name          string
+		usernameClaim string
+		token         func() string
+		want          *authenticationv1.UserInfo
+	}{
+		{name: "sub", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"groups": []string{"dev", "ops"}})
+		}, want: &authenticationv1.UserInfo{Username: provider.URL + "#1234", UID: "1234", Groups: []string{"dev", "ops"}}},
+		{name: "ecdsa key", token: func() string {
+			return provider.sign(t, "ec", map[string]interface{}{"groups": "dev"})
+		}, want: &authenticationv1.UserInfo{Username: provider.URL + "#1234", UID: "1234", Groups: []string{"dev"}}},
+		{name: "audience list", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"aud": []string{"other", oidcClientID}})
+		}, want: &authenticationv1.UserInfo{Username: provider.URL + "#1234", UID: "1234"}},
+		{name: "verified email", usernameClaim: "email", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"email": "alice@example.com", "email_verified": true})
+		}, want: &authenticationv1.UserInfo{Username: "alice@example.com", UID: "1234"}},
+		{name: "unverified email", usernameClaim: "email", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"email": "alice@example.com", "email_verified": false})
+		}},
+		{name: "email without email_verified", usernameClaim: "email", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"email": "alice@example.com"})
+		}},
+		{name: "missing username claim", usernameClaim: "email", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"email_verified": true})
+		}},
+		{name: "expired", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()})
+		}},
+		{name: "other audience", token: func() string {
+			return provider.sign(t, "rsa", map[string]interface{}{"aud": "other"})
+		}},
+		{name: "signed by another provider", token: func() string {
+			return other.sign(t, "rsa", map[string]interface{}{"iss": provider.URL})
+		}},
+		{name: "issued by another provider", token: func() string {
+			return other.sign(t, "rsa", map[string]interface{}{})
+		}},
+		{name: "forged claims", token: func() string {
+			token := provider.sign(t, "rsa", map[string]interface{}{})
+			forged := provider.sign(t, "rsa", map[string]interface{}{"sub": "admin"})
+			// the claims of one token with the signature of another.
+			return strings.Join(strings.Split(forged, ".")[:2], ".") + "." + strings.Split(token, ".")[2]
+		}},
+		{name: "not a jwt", token: func() string { return "6f0b7f3c-5bd1-4c2b-9a4e" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := OIDCOptions{IssuerURL: provider.URL, ClientID: oidcClientID, UsernameClaim: "sub", GroupsClaim: "groups"}
+			if tt.usernameClaim != "" {
+				opts.UsernameClaim = tt.usernameClaim
+			}
+			authenticator, err := NewOIDCAuthenticator(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			user, ok, err := authenticator.AuthenticateToken(context.Background(), tt.token())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != (tt.want != nil) || !reflect.DeepEqual(user, tt.want) {
+				t.Errorf("user = %v, %v, want %v", user, ok, tt.want)
+			}
+		})
+	}
+	if n := atomic.LoadInt32(&other.discoveries); n != 0 {
+		t.Errorf("tokens of other issuers triggered %d discoveries", n)
+	}
+}
+
+func TestOIDCAuthenticatorDiscovery(t *testing.T) {
+	if _, err := NewOIDCAuthenticator(OIDCOptions{ClientID: oidcClientID}); err == nil {
+		t.Errorf("missing issuer was accepted")
+	}
+	if _, err := NewOIDCAuthenticator(OIDCOptions{IssuerURL: "https://issuer.example.com"}); err == nil {
+		t.Errorf("missing client id was accepted")
+	}
+
+	provider := newOIDCProvider(t)
+	atomic.StoreInt32(&provider.down, 1)
+	authenticator, err := NewOIDCAuthenticator(OIDCOptions{IssuerURL: provider.URL, ClientID: oidcClientID,
+		UsernameClaim: "sub", GroupsClaim: "groups"})
+	if err != nil {
+		t.Fatalf("an unreachable provider failed the authenticator: %v", err)
+	}
+	token := provider.sign(t, "rsa", map[string]interface{}{})
+
+	if _, ok, err := authenticator.AuthenticateToken(context.Background(), token); err == nil || ok {
+		t.Fatalf("ok = %v, err = %v, want a discovery error", ok, err)
+	}
+	atomic.StoreInt32(&provider.down, 0)
+	for i := 0; i < 3; i++ {
+		if _, ok, err := authenticator.AuthenticateToken(context.Background(), token); err != nil || !ok {
+			t.Fatalf("ok = %v, err = %v after the provider came back", ok, err)
+		}
+	}
+	if n := atomic.LoadInt32(&provider.discoveries); n != 2 {
+		t.Errorf("%d discoveries, want the provider discovered once it is up", n)
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+func writeTokenFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestNewStaticTokenAuthenticator(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		err     string
+	}{
+		{name: "valid", content: "t1,alice,1\nt2,bob,2,\"dev,ops\"\n"},
+		{name: "empty file", content: ""},
+		{name: "missing uid", content: "t1,alice\n", err: "line 1: token, user and uid are required"},
+		{name: "empty token", content: "t1,alice,1\n ,bob,2\n", err: "line 2: empty token"},
+		{name: "broken quotes", content: "t1,alice,1,\"dev\n", err: "error while reading"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStaticTokenAuthenticator(writeTokenFile(t, tt.content))
+			if tt.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("err = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	if _, err := NewStaticTokenAuthenticator(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Errorf("missing token file was accepted")
+	}
+}
+
+func TestStaticTokenAuthenticator(t *testing.T) {
+	authenticator, err := NewStaticTokenAuthenticator(writeTokenFile(t,
+		"t1,alice,1\nt2,bob,2,\"dev, ops\"\nt3,carol,3,\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		token string
+		want  *authenticationv1.UserInfo
+	}{
+		{token: "t1", want: &authenticationv1.UserInfo{Username: "alice", UID: "1"}},
+		{token: "t2", want: &authenticationv1.UserInfo{Username: "bob", UID: "2", Groups: []string{"dev", "ops"}}},
+		{token: "t3", want: &authenticationv1.UserInfo{Username: "carol", UID: "3"}},
+		{token: "t4"},
+		{token: "t"},
+		{token: ""},
+	}
+	for _, tt := range tests {
+		user, ok, err := authenticator.AuthenticateToken(context.Background(), tt.token)
+		if err != nil {
+			t.Fatalf("token %q: unexpected error: %v", tt.token, err)
+		}
+		if ok != (tt.want != nil) || !reflect.DeepEqual(user, tt.want) {
+			t.Errorf("token %q: user = %v, %v, want %v", tt.token, user, ok, tt.want)
+		}
+	}
+}
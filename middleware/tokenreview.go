@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"time"
+
+	"github.com/lmxia/nightwatcher/controllers/k8s"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/utils/lru"
+)
+
+const (
+	tokenReviewCacheSize = 1024
+	tokenReviewCacheTTL  = time.Minute
+)
+
+// Hooks for unit tests.
+var tokenReviewClientFunc = func() (kubernetes.Interface, error) {
+	k8sClient, err := k8s.GetClientWithPanic()
+	if err != nil {
+		return nil, err
+	}
+	return k8sClient.K8sClient, nil
+}
+
+type tokenReviewResult struct {
+	user    *authenticationv1.UserInfo
+	ok      bool
+	expires time.Time
+}
+
+// TokenReviewAuthenticator validates tokens against the apiserver with a TokenReview, results are
+// cached for a minute so polling clients don't review their token on every request.
+type TokenReviewAuthenticator struct {
+	cache *lru.Cache
+}
+
+func NewTokenReviewAuthenticator() *TokenReviewAuthenticator {
+	return &TokenReviewAuthenticator{cache: lru.New(tokenReviewCacheSize)}
+}
+
+func (a *TokenReviewAuthenticator) AuthenticateToken(ctx context.Context, token string) (*authenticationv1.UserInfo, bool, error) {
+	key := sha256.Sum256([]byte(token))
+	if cached, ok := a.cache.Get(key); ok {
+		result := cached.(*tokenReviewResult)
+		if time.Now().Before(result.expires) {
+			return result.user, result.ok, nil
+		}
+		a.cache.Remove(key)
+	}
+
+	k8sClient, err := tokenReviewClientFunc()
+	if err != nil {
+		return nil, false, err
+	}
+	review, err := k8sClient.AuthenticationV1().TokenReviews().Create(ctx, &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	result := &tokenReviewResult{
+		user:    &review.Status.User,
+		ok:      review.Status.Authenticated,
+		expires: time.Now().Add(tokenReviewCacheTTL),
+	}
+	a.cache.Add(key, result)
+	return result.user, result.ok, nil
+}
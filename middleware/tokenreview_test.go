@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"testing"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// useFakeTokenReviews answers TokenReviews from users, keyed by token, and counts the reviews made.
+func useFakeTokenReviews(t *testing.T, users map[string]authenticationv1.UserInfo, err error) *int {
+	t.Helper()
+	reviews := 0
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		if err != nil {
+			return true, nil, err
+		}
+		review := action.(k8stesting.CreateAction).GetObject().(*authenticationv1.TokenReview).DeepCopy()
+		if user, ok := users[review.Spec.Token]; ok {
+			review.Status = authenticationv1.TokenReviewStatus{Authenticated: true, User: user}
+		}
+		return true, review, nil
+	})
+
+	old := tokenReviewClientFunc
+	tokenReviewClientFunc = func() (kubernetes.Interface, error) { return client, nil }
+	t.Cleanup(func() { tokenReviewClientFunc = old })
+	return &reviews
+}
+
+func TestTokenReviewAuthenticator(t *testing.T) {
+	alice := authenticationv1.UserInfo{Username: "system:serviceaccount:gaia-system:alice", Groups: []string{"system:serviceaccounts"}}
+	reviews := useFakeTokenReviews(t, map[string]authenticationv1.UserInfo{"t1": alice}, nil)
+	authenticator := NewTokenReviewAuthenticator()
+
+	tests := []struct {
+		token   string
+		want    *authenticationv1.UserInfo
+		reviews int
+	}{
+		{token: "t1", want: &alice, reviews: 1},
+		// results are cached, rejections included.
+		{token: "t1", want: &alice, reviews: 1},
+		{token: "t2", reviews: 2},
+		{token: "t2", reviews: 2},
+	}
+	for _, tt := range tests {
+		user, ok, err := authenticator.AuthenticateToken(context.Background(), tt.token)
+		if err != nil {
+			t.Fatalf("token %q: unexpected error: %v", tt.token, err)
+		}
+		if ok != (tt.want != nil) || (ok && !reflect.DeepEqual(user, tt.want)) {
+			t.Errorf("token %q: user = %v, %v, want %v", tt.token, user, ok, tt.want)
+		}
+		if *reviews != tt.reviews {
+			t.Errorf("token %q: %d reviews, want %d", tt.token, *reviews, tt.reviews)
+		}
+	}
+
+	// expired results are reviewed again.
+	for _, key := range []string{"t1", "t2"} {
+		cached, _ := authenticator.cache.Get(sha256.Sum256([]byte(key)))
+		cached.(*tokenReviewResult).expires = cached.(*tokenReviewResult).expires.Add(-2 * tokenReviewCacheTTL)
+	}
+	if _, ok, _ := authenticator.AuthenticateToken(context.Background(), "t1"); !ok || *reviews != 3 {
+		t.Errorf("expired result was not reviewed again, %d reviews", *reviews)
+	}
+}
+
+func TestTokenReviewAuthenticatorError(t *testing.T) {
+	reviews := useFakeTokenReviews(t, nil, errors.New("apiserver unreachable"))
+	authenticator := NewTokenReviewAuthenticator()
+
+	for i := 1; i <= 2; i++ {
+		if _, ok, err := authenticator.AuthenticateToken(context.Background(), "t1"); err == nil || ok {
+			t.Fatalf("ok = %v, err = %v, want an error", ok, err)
+		}
+		// failed reviews are not cached.
+		if *reviews != i {
+			t.Fatalf("%d reviews, want %d", *reviews, i)
+		}
+	}
+}
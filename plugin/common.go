@@ -3,4 +3,7 @@ package plugin
 const (
 	NONFQDN   = "nofqdn"
 	FQDNINDEX = "fqdnindex"
+
+	// DrainAnnotation marks a ManagedCluster as draining when set to "true" on it.
+	DrainAnnotation = "crossdns.gaia.io/draining"
 )
@@ -5,19 +5,23 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog/v2"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/fall"
 	"github.com/coredns/coredns/request"
 	appv1alpha1 "github.com/lmxia/gaia/pkg/apis/apps/v1alpha1"
 	"github.com/lmxia/gaia/pkg/common"
 	"github.com/lmxia/gaia/pkg/generated/listers/apps/v1alpha1"
+	platformlisters "github.com/lmxia/gaia/pkg/generated/listers/platform/v1alpha1"
 	"github.com/lmxia/nightwatcher/utils"
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
@@ -32,6 +36,14 @@ type CrossDNS struct {
 
 	indexedStore cache.ThreadSafeStore
 	descLister   v1alpha1.DescriptionLister
+
+	mclsLister platformlisters.ManagedClusterLister
+	mclsSynced cache.InformerSynced
+
+	// serial is the SOA serial handed out for the configured zones.
+	serial uint32
+
+	RateLimit *RateLimit
 }
 
 type DNSRecord struct {
@@ -49,13 +61,35 @@ func (c CrossDNS) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	}
 
 	klog.Infof("Request received for %q", qname)
+	zone = qname[len(qname)-len(zone):] // maintain case of original query
+	state.Zone = zone
+
+	// throttle before any lister scan or hermes call is made for the query.
+	if allowed, slip := c.RateLimit.allowQuery(state); !allowed {
+		if slip {
+			rateLimited.WithLabelValues("query", "truncate").Inc()
+			return writeResponse(state, truncated(r))
+		}
+		rateLimited.WithLabelValues("query", "drop").Inc()
+		return dns.RcodeSuccess, nil
+	}
+	if c.RateLimit.enabled() {
+		w = &rateLimitedWriter{ResponseWriter: w, state: state, rl: c.RateLimit}
+		state.W = w
+	}
+
+	if state.QType() == dns.TypeSOA && qname == zone {
+		a := new(dns.Msg)
+		a.SetReply(r)
+		a.Answer = []dns.RR{c.soa(state)}
+		return writeResponse(state, a)
+	}
+
 	if state.QType() != dns.TypeA && state.QType() != dns.TypeAAAA && state.QType() != dns.TypeSRV {
 		msg := fmt.Sprintf("Query of type %d is not supported", state.QType())
 		klog.Info(msg)
 		return plugin.NextOrFailure(c.Name(), c.Next, ctx, state.W, r) // nolint:wrapcheck // Let the caller wrap it.
 	}
-	zone = qname[len(qname)-len(zone):] // maintain case of original query
-	state.Zone = zone
 
 	pReq, pErr := parseRequest(state)
 
@@ -76,17 +110,33 @@ func (c *CrossDNS) getDNSRecord(ctx context.Context, zone string, state *request
 		klog.Fatal("unable to sync caches for resource binding")
 	}
 
+	// apex or SRV-only queries carry no fqdn to look up.
+	if pReq.hostname == "" {
+		return c.emptyResponse(state)
+	}
+
 	// 1. get which desc you belong.
 	descNames, err := c.indexedStore.IndexKeys(FQDNINDEX, pReq.hostname)
 	if err != nil || len(descNames) == 0 {
 		klog.Infof("Couldn't find a scheduled description %q", state.QName())
-		return c.emptyResponse(state)
+		return c.nxDomainResponse(state)
 	}
 	// 2. get which component the fqdn belong
 	componentName, err := utils.GetComponentFromDescriptionAndFQDN(c.descLister, descNames[0], pReq.hostname)
 	if err != nil {
 		klog.Infof("Get component name failed %s", err)
-		return c.emptyResponse(state)
+		return c.nxDomainResponse(state)
+	}
+	var ports []corev1.ContainerPort
+	if state.QType() == dns.TypeSRV {
+		ports = c.componentPorts(descNames[0], componentName, pReq)
+		if len(ports) == 0 && pReq.port != "" {
+			klog.Infof("Component %s of %q has no %s port %s", componentName, state.QName(), pReq.protocol, pReq.port)
+			return c.nxDomainResponse(state)
+		}
+		if len(ports) == 0 {
+			return c.emptyResponse(state)
+		}
 	}
 
 	var dnsRecords []DNSRecord
@@ -108,6 +158,16 @@ func (c *CrossDNS) getDNSRecord(ctx context.Context, zone string, state *request
 		klog.Errorf("Failed to write message %v", err)
 		return dns.RcodeServerFailure, errors.New("failed to write response")
 	}
+	// a cluster qualified name only answers with that field.
+	if pReq.cluster != "" {
+		if !sets.New[string](fields...).Has(pReq.cluster) {
+			klog.Infof("Component %s of %q is not placed in field %s", componentName, state.QName(), pReq.cluster)
+			return c.nxDomainResponse(state)
+		}
+		fields = []string{pReq.cluster}
+	} else {
+		fields = c.excludeDrainingFields(fields)
+	}
 
 	// 4. Now we get fields, so get fields ip from hermes.
 	realEndpoints, err := utils.FilterAccessServiceIPFrom(sets.New[string](fields...))
@@ -124,9 +184,25 @@ func (c *CrossDNS) getDNSRecord(ctx context.Context, zone string, state *request
 	}
 
 	records := make([]dns.RR, 0)
-
-	if state.QType() == dns.TypeA {
-		records = c.createARecords(dnsRecords, state)
+	var extra []dns.RR
+
+	switch state.QType() {
+	case dns.TypeA:
+		records = c.createARecords(dnsRecords, state.QName(), state)
+	case dns.TypeAAAA:
+		records = c.createAAAARecords(dnsRecords, state.QName(), state)
+	case dns.TypeSRV:
+		// the ports point at the name answering the addresses of the same fields.
+		target := dnsutil.Join(pReq.hostname, zone)
+		if pReq.cluster != "" {
+			target = dnsutil.Join(pReq.cluster, pReq.hostname, zone)
+		}
+		records = c.createSRVRecords(ports, target, state)
+		extra = append(c.createARecords(dnsRecords, target, state), c.createAAAARecords(dnsRecords, target, state)...)
+	}
+	if len(records) == 0 {
+		klog.Infof("No %s records can be synthesized for %q", dns.TypeToString[state.QType()], state.QName())
+		return c.emptyResponse(state)
 	}
 
 	rand := rand.New(rand.NewSource(time.Now().Unix()))
@@ -136,10 +212,16 @@ func (c *CrossDNS) getDNSRecord(ctx context.Context, zone string, state *request
 	a.SetReply(r)
 	a.Authoritative = true
 
-	// all
-	//a.Answer = append(a.Answer, records...)
-	// random one
-	a.Answer = append(a.Answer, records[rand.Intn(len(records))])
+	if state.QType() == dns.TypeSRV {
+		// every port, with the addresses of their target.
+		a.Answer = records
+		a.Extra = extra
+	} else {
+		// all
+		//a.Answer = append(a.Answer, records...)
+		// random one
+		a.Answer = append(a.Answer, records[rand.Intn(len(records))])
+	}
 	klog.Infof("Responding to query with '%s'", a.Answer)
 
 	wErr := w.WriteMsg(a)
@@ -156,13 +238,42 @@ func (c CrossDNS) Name() string {
 	return "crossdns"
 }
 
+// emptyResponse answers NODATA: the name exists but has no records of the queried type.
 func (c CrossDNS) emptyResponse(state *request.Request) (int, error) {
 	a := new(dns.Msg)
 	a.SetReply(state.Req)
+	// A single SOA in the authority section lets the dnssec plugin sign the denial.
+	a.Ns = []dns.RR{c.soa(state)}
+
+	return writeResponse(state, a)
+}
+
+// nxDomainResponse answers NXDOMAIN for names we know nothing about.
+func (c CrossDNS) nxDomainResponse(state *request.Request) (int, error) {
+	a := new(dns.Msg)
+	a.SetRcode(state.Req, dns.RcodeNameError)
+	a.Ns = []dns.RR{c.soa(state)}
 
 	return writeResponse(state, a)
 }
 
+// soa returns the synthesized SOA record of the zone in state.
+func (c CrossDNS) soa(state *request.Request) dns.RR {
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name: state.Zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET,
+			Ttl: uint32(5),
+		},
+		Ns:      dnsutil.Join("ns.dns", state.Zone),
+		Mbox:    dnsutil.Join("hostmaster", state.Zone),
+		Serial:  c.serial,
+		Refresh: 7200,
+		Retry:   1800,
+		Expire:  86400,
+		Minttl:  5,
+	}
+}
+
 func writeResponse(state *request.Request, a *dns.Msg) (int, error) {
 	a.Authoritative = true
 
@@ -175,20 +286,92 @@ func writeResponse(state *request.Request, a *dns.Msg) (int, error) {
 	return dns.RcodeSuccess, nil
 }
 
-func (c CrossDNS) createARecords(dnsrecords []DNSRecord, state *request.Request) []dns.RR {
+func (c CrossDNS) createARecords(dnsrecords []DNSRecord, name string, state *request.Request) []dns.RR {
 	records := make([]dns.RR, 0)
 
 	for _, record := range dnsrecords {
+		ip := net.ParseIP(record.IP).To4()
+		if ip == nil {
+			continue
+		}
 		dnsRecord := &dns.A{Hdr: dns.RR_Header{
-			Name: state.QName(), Rrtype: dns.TypeA, Class: state.QClass(),
+			Name: name, Rrtype: dns.TypeA, Class: state.QClass(),
+			Ttl: uint32(5),
+		}, A: ip}
+		records = append(records, dnsRecord)
+	}
+
+	return records
+}
+
+func (c CrossDNS) createAAAARecords(dnsrecords []DNSRecord, name string, state *request.Request) []dns.RR {
+	records := make([]dns.RR, 0)
+
+	for _, record := range dnsrecords {
+		ip := net.ParseIP(record.IP)
+		if ip == nil || ip.To4() != nil {
+			continue
+		}
+		dnsRecord := &dns.AAAA{Hdr: dns.RR_Header{
+			Name: name, Rrtype: dns.TypeAAAA, Class: state.QClass(),
 			Ttl: uint32(5),
-		}, A: net.ParseIP(record.IP).To4()}
+		}, AAAA: ip}
 		records = append(records, dnsRecord)
 	}
 
 	return records
 }
 
+// createSRVRecords answers every port with target, at an equal weight.
+func (c CrossDNS) createSRVRecords(ports []corev1.ContainerPort, target string, state *request.Request) []dns.RR {
+	records := make([]dns.RR, 0, len(ports))
+
+	for _, port := range ports {
+		dnsRecord := &dns.SRV{Hdr: dns.RR_Header{
+			Name: state.QName(), Rrtype: dns.TypeSRV, Class: state.QClass(),
+			Ttl: uint32(5),
+		}, Priority: 0, Weight: uint16(100 / len(ports)), Port: uint16(port.ContainerPort), Target: target}
+		records = append(records, dnsRecord)
+	}
+
+	return records
+}
+
+// componentPorts returns the container ports of component in description descName, only the one named
+// by the port and protocol of pReq when it names one. Ports seen twice are returned once.
+func (c *CrossDNS) componentPorts(descName, component string, pReq *recordRequest) []corev1.ContainerPort {
+	desc, err := c.descLister.Descriptions(common.GaiaReservedNamespace).Get(descName)
+	if err != nil {
+		klog.Errorf("can't get description from: %s/%s", common.GaiaReservedNamespace, descName)
+		return nil
+	}
+	var ports []corev1.ContainerPort
+	seen := sets.New[string]()
+	for _, item := range desc.Spec.WorkloadComponents {
+		if item.ComponentName != component {
+			continue
+		}
+		for _, container := range item.Module.Spec.Containers {
+			for _, port := range container.Ports {
+				protocol := port.Protocol
+				if protocol == "" {
+					protocol = corev1.ProtocolTCP
+				}
+				if pReq.port != "" && (!strings.EqualFold(port.Name, pReq.port) || !strings.EqualFold(string(protocol), pReq.protocol)) {
+					continue
+				}
+				key := fmt.Sprintf("%d/%s", port.ContainerPort, protocol)
+				if seen.Has(key) {
+					continue
+				}
+				seen.Insert(key)
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
 func getAllRecordsFromField(slices []string) []DNSRecord {
 	records := make([]DNSRecord, 0)
 	for _, endpoint := range slices {
@@ -0,0 +1,558 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	appsv1alpha1 "github.com/lmxia/gaia/pkg/apis/apps/v1alpha1"
+	platformv1alpha1 "github.com/lmxia/gaia/pkg/apis/platform/v1alpha1"
+	"github.com/lmxia/gaia/pkg/common"
+	gaiaclientset "github.com/lmxia/gaia/pkg/generated/clientset/versioned"
+	"github.com/lmxia/gaia/pkg/generated/clientset/versioned/fake"
+	"github.com/miekg/dns"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
+)
+
+const testZone = "gslb.local."
+
+// hermesFields is what the hermes stand-in reports: the access service node ips of each field.
+var hermesFields = map[string][]string{
+	"field-a": {"10.0.0.1"},
+	"field-b": {"10.0.0.2", "fd00::2"},
+	"field-c": {"10.0.0.3"},
+}
+
+func newHermes(t *testing.T, fields map[string][]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/query" || r.URL.Query().Get("query_range") == "" {
+			http.Error(w, `{"message":"bad query"}`, http.StatusBadRequest)
+			return
+		}
+		streams := ""
+		for field, ips := range fields {
+			for _, ip := range ips {
+				if streams != "" {
+					streams += ","
+				}
+				streams += fmt.Sprintf(`{"metric":{"field_flag":%q,"node_ip":%q},"values":[]}`, field, ip)
+			}
+		}
+		fmt.Fprintf(w, `{"QueryValM":[%s]}`, streams)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newDescription(name, namespace string, fqdns map[string]string) *appsv1alpha1.Description {
+	desc := &appsv1alpha1.Description{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Status:     appsv1alpha1.DescriptionStatus{Phase: appsv1alpha1.DescriptionPhaseScheduled},
+	}
+	for component, fqdn := range fqdns {
+		desc.Spec.WorkloadComponents = append(desc.Spec.WorkloadComponents,
+			appsv1alpha1.WorkloadComponent{ComponentName: component, FQDN: fqdn})
+	}
+	return desc
+}
+
+func newResourceBinding(desc string, replicas map[string]map[string]int32) *appsv1alpha1.ResourceBinding {
+	rb := &appsv1alpha1.ResourceBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      desc + "-rb",
+			Namespace: common.GaiaRBMergedReservedNamespace,
+			Labels: map[string]string{
+				common.GaiaDescriptionLabel: desc,
+				common.StatusScheduler:      string(appsv1alpha1.ResourceBindingSelected),
+			},
+		},
+	}
+	for field, r := range replicas {
+		rb.Spec.RbApps = append(rb.Spec.RbApps, &appsv1alpha1.ResourceBindingApps{ClusterName: field, Replicas: r})
+	}
+	return rb
+}
+
+func newManagedCluster(name string, draining bool) *platformv1alpha1.ManagedCluster {
+	mcls := &platformv1alpha1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "gaia-system"}}
+	if draining {
+		mcls.Annotations = map[string]string{DrainAnnotation: "true"}
+	}
+	return mcls
+}
+
+// shopObjects places web in field-a and field-b, and api nowhere.
+func shopObjects() []runtime.Object {
+	return []runtime.Object{
+		newDescription("shop", common.GaiaReservedNamespace, map[string]string{"web": "web", "api": "api"}),
+		newResourceBinding("shop", map[string]map[string]int32{
+			"field-a": {"web": 1, "api": 0},
+			"field-b": {"web": 2},
+		}),
+	}
+}
+
+// newTestCrossDNS sets crossdns up from a Corefile through the clientset hook and waits for its caches.
+func newTestCrossDNS(t *testing.T, hermesURL string, objects ...runtime.Object) (*CrossDNS, *fake.Clientset) {
+	t.Helper()
+	t.Setenv("HERMESURL", hermesURL)
+	t.Setenv("ACCESS_SERVICE_DEFAULT_IP", "192.0.2.10")
+
+	client := useFakeClientset(t, objects...)
+	cd, err := CrossDNSParse(caddy.NewTestController("dns", "crossdns "+testZone))
+	if err != nil {
+		t.Fatalf("CrossDNSParse: %v", err)
+	}
+	cd.Next = test.NextHandler(dns.RcodeRefused, nil)
+
+	descs := 0
+	for _, obj := range objects {
+		if desc, ok := obj.(*appsv1alpha1.Description); ok && desc.Namespace == common.GaiaReservedNamespace {
+			descs++
+		}
+	}
+	waitFor(t, func() bool {
+		return cd.rbSynced() && cd.mclsSynced() && len(cd.indexedStore.ListKeys()) == descs
+	})
+	return cd, client
+}
+
+// useFakeClientset points the clientset hook at a fake clientset holding objects for the rest of the test.
+func useFakeClientset(t *testing.T, objects ...runtime.Object) *fake.Clientset {
+	t.Helper()
+	client := fake.NewSimpleClientset(objects...)
+	oldBuild, oldClient := buildKubeConfigFunc, newGaiaClientFunc
+	buildKubeConfigFunc = func(string, string) (*rest.Config, error) { return &rest.Config{}, nil }
+	newGaiaClientFunc = func(*rest.Config) (gaiaclientset.Interface, error) { return client, nil }
+	t.Cleanup(func() { buildKubeConfigFunc, newGaiaClientFunc = oldBuild, oldClient })
+	return client
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return condition(), nil
+	})
+	if err != nil {
+		t.Fatalf("condition not met: %v", err)
+	}
+}
+
+type dnsCase struct {
+	name    string
+	qname   string
+	qtype   uint16
+	rcode   int
+	err     bool
+	answers []string // any one of them is a valid answer, empty means none
+	soa     bool     // a SOA is expected in the authority section
+	msg     bool     // a message is expected to be written
+}
+
+func serve(t *testing.T, cd *CrossDNS, qname string, qtype uint16) (int, error, *dnstest.Recorder) {
+	t.Helper()
+	m := new(dns.Msg)
+	m.SetQuestion(qname, qtype)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	rcode, err := cd.ServeDNS(context.Background(), rec, m)
+	return rcode, err, rec
+}
+
+func runDNSCases(t *testing.T, cd *CrossDNS, cases []dnsCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rcode, err, rec := serve(t, cd, tc.qname, tc.qtype)
+			if (err != nil) != tc.err {
+				t.Fatalf("ServeDNS error = %v, want error %v", err, tc.err)
+			}
+			if !tc.msg {
+				if rec.Msg != nil {
+					t.Fatalf("unexpected message written: %v", rec.Msg)
+				}
+				if rcode != tc.rcode {
+					t.Fatalf("rcode = %s, want %s", dns.RcodeToString[rcode], dns.RcodeToString[tc.rcode])
+				}
+				return
+			}
+			if rec.Msg == nil {
+				t.Fatalf("no message written, rcode %s", dns.RcodeToString[rcode])
+			}
+			if rec.Msg.Rcode != tc.rcode {
+				t.Fatalf("rcode = %s, want %s", dns.RcodeToString[rec.Msg.Rcode], dns.RcodeToString[tc.rcode])
+			}
+			if !rec.Msg.Authoritative {
+				t.Errorf("answer is not authoritative")
+			}
+			checkAnswer(t, rec.Msg, tc.qtype, tc.answers)
+			if tc.soa != (len(rec.Msg.Ns) == 1 && rec.Msg.Ns[0].Header().Rrtype == dns.TypeSOA) {
+				t.Errorf("authority = %v, want SOA %v", rec.Msg.Ns, tc.soa)
+			}
+		})
+	}
+}
+
+func checkAnswer(t *testing.T, msg *dns.Msg, qtype uint16, answers []string) {
+	t.Helper()
+	if len(answers) == 0 {
+		if len(msg.Answer) != 0 {
+			t.Fatalf("answer = %v, want none", msg.Answer)
+		}
+		return
+	}
+	if len(msg.Answer) != 1 {
+		t.Fatalf("answer = %v, want one of %v", msg.Answer, answers)
+	}
+	var got string
+	switch rr := msg.Answer[0].(type) {
+	case *dns.A:
+		got = rr.A.String()
+	case *dns.AAAA:
+		got = rr.AAAA.String()
+	case *dns.SOA:
+		got = rr.Ns
+	}
+	if msg.Answer[0].Header().Rrtype != qtype {
+		t.Fatalf("answer type = %s, want %s", dns.TypeToString[msg.Answer[0].Header().Rrtype], dns.TypeToString[qtype])
+	}
+	for _, want := range answers {
+		if got == want {
+			return
+		}
+	}
+	t.Fatalf("answer = %s, want one of %v", got, answers)
+}
+
+func TestServeDNS(t *testing.T) {
+	hermes := newHermes(t, hermesFields)
+	cd, _ := newTestCrossDNS(t, hermes.URL, shopObjects()...)
+
+	runDNSCases(t, cd, []dnsCase{
+		{name: "A of every field", qname: "web." + testZone, qtype: dns.TypeA, msg: true,
+			answers: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "A is case insensitive", qname: "WEB.Gslb.Local.", qtype: dns.TypeA, msg: true,
+			answers: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "AAAA of every field", qname: "web." + testZone, qtype: dns.TypeAAAA, msg: true,
+			answers: []string{"fd00::2"}},
+		{name: "A of one field", qname: "field-a.web." + testZone, qtype: dns.TypeA, msg: true,
+			answers: []string{"10.0.0.1"}},
+		{name: "AAAA of one field", qname: "field-b.web." + testZone, qtype: dns.TypeAAAA, msg: true,
+			answers: []string{"fd00::2"}},
+		{name: "AAAA of a field without ipv6", qname: "field-a.web." + testZone, qtype: dns.TypeAAAA, msg: true,
+			soa: true},
+		{name: "deeper names resolve by their first label", qname: "web.shop.apps." + testZone, qtype: dns.TypeA,
+			msg: true, answers: []string{"10.0.0.1", "10.0.0.2"}},
+		{name: "deeper names of unknown fqdn", qname: "cart.web.shop." + testZone, qtype: dns.TypeA, msg: true,
+			rcode: dns.RcodeNameError, soa: true},
+		{name: "field the component is not placed in", qname: "field-c.web." + testZone, qtype: dns.TypeA, msg: true,
+			rcode: dns.RcodeNameError, soa: true},
+		{name: "unknown fqdn", qname: "cart." + testZone, qtype: dns.TypeA, msg: true,
+			rcode: dns.RcodeNameError, soa: true},
+		{name: "component without replicas", qname: "api." + testZone, qtype: dns.TypeA,
+			rcode: dns.RcodeServerFailure, err: true},
+		{name: "SRV of a component without ports", qname: "web." + testZone, qtype: dns.TypeSRV, msg: true,
+			soa: true},
+		{name: "SRV of a port the component has not", qname: "_http._tcp.web." + testZone, qtype: dns.TypeSRV, msg: true,
+			rcode: dns.RcodeNameError, soa: true},
+		{name: "SRV without a host", qname: "_http._tcp." + testZone, qtype: dns.TypeSRV, msg: true,
+			soa: true},
+		{name: "SOA at the apex", qname: testZone, qtype: dns.TypeSOA, msg: true,
+			answers: []string{"ns.dns." + testZone}},
+		{name: "A at the apex", qname: testZone, qtype: dns.TypeA, msg: true,
+			soa: true},
+		{name: "unsupported type goes to next", qname: "web." + testZone, qtype: dns.TypeMX,
+			rcode: dns.RcodeRefused},
+		{name: "other zone goes to next", qname: "web.example.org.", qtype: dns.TypeA,
+			rcode: dns.RcodeRefused},
+	})
+}
+
+func TestServeDNSSRV(t *testing.T) {
+	hermes := newHermes(t, hermesFields)
+	objects := shopObjects()
+	desc := objects[0].(*appsv1alpha1.Description)
+	for i := range desc.Spec.WorkloadComponents {
+		if desc.Spec.WorkloadComponents[i].ComponentName == "web" {
+			desc.Spec.WorkloadComponents[i].Module.Spec.Containers = []corev1.Container{
+				{Name: "web", Ports: []corev1.ContainerPort{
+					{Name: "http", ContainerPort: 80},
+					{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+					{ContainerPort: 9090},
+				}},
+				{Name: "sidecar", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 80}}},
+			}
+		}
+	}
+	cd, _ := newTestCrossDNS(t, hermes.URL, objects...)
+
+	tests := []struct {
+		name   string
+		qname  string
+		rcode  int
+		ports  []uint16
+		target string
+		extra  []string
+	}{
+		{name: "every port", qname: "web." + testZone, ports: []uint16{80, 53, 9090},
+			target: "web." + testZone, extra: []string{"10.0.0.1", "10.0.0.2", "fd00::2"}},
+		{name: "named port", qname: "_http._tcp.web." + testZone, ports: []uint16{80},
+			target: "web." + testZone, extra: []string{"10.0.0.1", "10.0.0.2", "fd00::2"}},
+		{name: "protocol is case insensitive", qname: "_DNS._UDP.web." + testZone, ports: []uint16{53},
+			target: "web." + testZone, extra: []string{"10.0.0.1", "10.0.0.2", "fd00::2"}},
+		{name: "port of one field", qname: "_dns._udp.field-a.web." + testZone, ports: []uint16{53},
+			target: "field-a.web." + testZone, extra: []string{"10.0.0.1"}},
+		{name: "port of another protocol", qname: "_http._udp.web." + testZone, rcode: dns.RcodeNameError},
+		{name: "field the component is not placed in", qname: "field-c.web." + testZone, rcode: dns.RcodeNameError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err, rec := serve(t, cd, tt.qname, dns.TypeSRV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rec.Msg == nil || rec.Msg.Rcode != tt.rcode {
+				t.Fatalf("message = %v, want rcode %s", rec.Msg, dns.RcodeToString[tt.rcode])
+			}
+			var ports []uint16
+			for _, rr := range rec.Msg.Answer {
+				srv, ok := rr.(*dns.SRV)
+				if !ok || srv.Hdr.Name != dns.Fqdn(tt.qname) || srv.Target != tt.target || srv.Weight != uint16(100/len(tt.ports)) {
+					t.Fatalf("answer %v, want SRV of %s to %s", rr, tt.qname, tt.target)
+				}
+				ports = append(ports, srv.Port)
+			}
+			if !reflect.DeepEqual(ports, tt.ports) {
+				t.Errorf("ports = %v, want %v", ports, tt.ports)
+			}
+			var extra []string
+			for _, rr := range rec.Msg.Extra {
+				if rr.Header().Name != tt.target {
+					t.Errorf("extra %v is not of the target %s", rr, tt.target)
+				}
+				switch rr := rr.(type) {
+				case *dns.A:
+					extra = append(extra, rr.A.String())
+				case *dns.AAAA:
+					extra = append(extra, rr.AAAA.String())
+				}
+			}
+			sort.Strings(extra)
+			if !reflect.DeepEqual(extra, tt.extra) {
+				t.Errorf("extra = %v, want %v", extra, tt.extra)
+			}
+		})
+	}
+}
+
+func TestServeDNSDraining(t *testing.T) {
+	hermes := newHermes(t, hermesFields)
+
+	t.Run("draining field is skipped", func(t *testing.T) {
+		objects := append(shopObjects(), newManagedCluster("field-a", false), newManagedCluster("field-b", true))
+		cd, _ := newTestCrossDNS(t, hermes.URL, objects...)
+		runDNSCases(t, cd, []dnsCase{
+			{name: "A", qname: "web." + testZone, qtype: dns.TypeA, msg: true, answers: []string{"10.0.0.1"}},
+			{name: "AAAA", qname: "web." + testZone, qtype: dns.TypeAAAA, msg: true, soa: true},
+			{name: "cluster qualified", qname: "field-b.web." + testZone, qtype: dns.TypeA, msg: true,
+				answers: []string{"10.0.0.2"}},
+		})
+	})
+
+	t.Run("all fields draining", func(t *testing.T) {
+		objects := append(shopObjects(), newManagedCluster("field-a", true), newManagedCluster("field-b", true))
+		cd, _ := newTestCrossDNS(t, hermes.URL, objects...)
+		runDNSCases(t, cd, []dnsCase{
+			{name: "A", qname: "web." + testZone, qtype: dns.TypeA, msg: true,
+				answers: []string{"10.0.0.1", "10.0.0.2"}},
+		})
+	})
+
+	t.Run("drain is picked up from the informer", func(t *testing.T) {
+		objects := append(shopObjects(), newManagedCluster("field-a", false))
+		cd, client := newTestCrossDNS(t, hermes.URL, objects...)
+		_, err := client.PlatformV1alpha1().ManagedClusters("gaia-system").
+			Update(context.Background(), newManagedCluster("field-a", true), metav1.UpdateOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitFor(t, func() bool { return cd.drainingFields().Has("field-a") })
+		runDNSCases(t, cd, []dnsCase{
+			{name: "A", qname: "web." + testZone, qtype: dns.TypeA, msg: true, answers: []string{"10.0.0.2"}},
+		})
+	})
+}
+
+func TestServeDNSHermesFallback(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"unavailable"}`, http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	tests := []struct {
+		name   string
+		hermes string
+	}{
+		{name: "hermes fails", hermes: down.URL},
+		{name: "hermes unreachable", hermes: "http://127.0.0.1:1"},
+		{name: "no endpoints of the fields", hermes: newHermes(t, map[string][]string{"field-c": {"10.0.0.3"}}).URL},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd, _ := newTestCrossDNS(t, tt.hermes, shopObjects()...)
+			runDNSCases(t, cd, []dnsCase{
+				{name: "A", qname: "web." + testZone, qtype: dns.TypeA, msg: true, answers: []string{"192.0.2.10"}},
+				{name: "AAAA", qname: "web." + testZone, qtype: dns.TypeAAAA, msg: true, soa: true},
+			})
+		})
+	}
+}
+
+func TestDescriptionIndex(t *testing.T) {
+	hermes := newHermes(t, hermesFields)
+	objects := shopObjects()[1:]
+	cd, client := newTestCrossDNS(t, hermes.URL, objects...)
+	descs := client.AppsV1alpha1().Descriptions(common.GaiaReservedNamespace)
+	ctx := context.Background()
+
+	indexed := func(fqdn string) func() bool {
+		return func() bool {
+			names, err := cd.indexedStore.IndexKeys(FQDNINDEX, fqdn)
+			return err == nil && len(names) == 1 && names[0] == "shop"
+		}
+	}
+	answers := func(fqdn string, rcode int, answers ...string) []dnsCase {
+		return []dnsCase{{name: fqdn, qname: fqdn + "." + testZone, qtype: dns.TypeA, msg: true,
+			rcode: rcode, soa: rcode != dns.RcodeSuccess, answers: answers}}
+	}
+
+	runDNSCases(t, cd, answers("web", dns.RcodeNameError))
+
+	// descriptions of other namespaces or not scheduled yet are not indexed.
+	pending := newDescription("shop", common.GaiaReservedNamespace, map[string]string{"web": "web"})
+	pending.Status.Phase = ""
+	if _, err := descs.Create(ctx, pending, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	other := newDescription("shop", "default", map[string]string{"web": "web"})
+	if _, err := client.AppsV1alpha1().Descriptions("default").Create(ctx, other, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// a scheduled description created after them is indexed once they went through the filter.
+	marker := newDescription("sync", common.GaiaReservedNamespace, map[string]string{"web": "sync"})
+	if _, err := descs.Create(ctx, marker, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool {
+		names, err := cd.indexedStore.IndexKeys(FQDNINDEX, "sync")
+		return err == nil && len(names) == 1
+	})
+	if keys := cd.indexedStore.ListKeys(); len(keys) != 1 || keys[0] != "sync" {
+		t.Fatalf("indexed %v, want sync only", keys)
+	}
+	if err := descs.Delete(ctx, "sync", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(cd.indexedStore.ListKeys()) == 0 })
+
+	if _, err := descs.Update(ctx, newDescription("shop", common.GaiaReservedNamespace,
+		map[string]string{"web": "web"}), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, indexed("web"))
+	runDNSCases(t, cd, answers("web", dns.RcodeSuccess, "10.0.0.1", "10.0.0.2"))
+
+	// a renamed fqdn moves the index.
+	if _, err := descs.Update(ctx, newDescription("shop", common.GaiaReservedNamespace,
+		map[string]string{"web": "store"}), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, indexed("store"))
+	runDNSCases(t, cd, append(answers("web", dns.RcodeNameError),
+		answers("store", dns.RcodeSuccess, "10.0.0.1", "10.0.0.2")...))
+
+	// a description without any fqdn is indexed under NONFQDN only.
+	if _, err := descs.Update(ctx, newDescription("shop", common.GaiaReservedNamespace,
+		map[string]string{"web": ""}), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, indexed(NONFQDN))
+	runDNSCases(t, cd, answers("store", dns.RcodeNameError))
+
+	if _, err := descs.Update(ctx, newDescription("shop", common.GaiaReservedNamespace,
+		map[string]string{"web": "web"}), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, indexed("web"))
+	if err := descs.Delete(ctx, "shop", metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, func() bool { return len(cd.indexedStore.ListKeys()) == 0 })
+	runDNSCases(t, cd, answers("web", dns.RcodeNameError))
+}
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		qname string
+		qtype uint16
+		want  string // hostname.cluster.service.namespace.podOrSvc
+		port  string
+		proto string
+		err   bool
+	}{
+		{qname: testZone, qtype: dns.TypeA, want: "...."},
+		{qname: "svc." + testZone, qtype: dns.TypeA, want: "...."},
+		{qname: "pod." + testZone, qtype: dns.TypeA, want: "...."},
+		{qname: "web." + testZone, qtype: dns.TypeA, want: "web...."},
+		{qname: "web." + testZone, qtype: dns.TypeAAAA, want: "web...."},
+		{qname: "field-a.web." + testZone, qtype: dns.TypeA, want: "web.field-a..."},
+		{qname: "field-a.web." + testZone, qtype: dns.TypeAAAA, want: "web.field-a..."},
+		{qname: "web.shop.apps." + testZone, qtype: dns.TypeA, want: "web...."},
+		{qname: "web.field-a.shop.apps." + testZone, qtype: dns.TypeAAAA, want: "web...."},
+		{qname: "web." + testZone, qtype: dns.TypeSRV, want: "web...."},
+		{qname: "field-a.web." + testZone, qtype: dns.TypeSRV, want: "web.field-a..."},
+		{qname: "_http._tcp." + testZone, qtype: dns.TypeSRV, want: "....", port: "http", proto: "tcp"},
+		{qname: "_http._tcp.web." + testZone, qtype: dns.TypeSRV, want: "web....", port: "http", proto: "tcp"},
+		{qname: "_http._tcp.field-a.web." + testZone, qtype: dns.TypeSRV, want: "web.field-a...", port: "http", proto: "tcp"},
+		{qname: "a._http._tcp.web." + testZone, qtype: dns.TypeSRV, err: true},
+		{qname: "web.field-a.shop." + testZone, qtype: dns.TypeSRV, err: true},
+		{qname: "web." + testZone, qtype: dns.TypeTXT, want: "...."},
+	}
+	for _, tt := range tests {
+		t.Run(dns.TypeToString[tt.qtype]+" "+tt.qname, func(t *testing.T) {
+			m := new(dns.Msg)
+			m.SetQuestion(tt.qname, tt.qtype)
+			state := &request.Request{Req: m, Zone: testZone}
+
+			r, err := parseRequest(state)
+			if tt.err {
+				if err != errInvalidRequest {
+					t.Fatalf("err = %v, want %v", err, errInvalidRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("request = %q, want %q", got, tt.want)
+			}
+			if r.port != tt.port || r.protocol != tt.proto {
+				t.Errorf("port/protocol = %q/%q, want %q/%q", r.port, r.protocol, tt.port, tt.proto)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	platformv1alpha1 "github.com/lmxia/gaia/pkg/apis/platform/v1alpha1"
+)
+
+func isDraining(mcls *platformv1alpha1.ManagedCluster) bool {
+	return mcls.Annotations[DrainAnnotation] == "true"
+}
+
+// drainingFields returns the names of all fields marked as draining.
+func (c *CrossDNS) drainingFields() sets.Set[string] {
+	draining := sets.New[string]()
+	if c.mclsLister == nil || !c.mclsSynced() {
+		return draining
+	}
+	clusters, err := c.mclsLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list managed clusters: %v", err)
+		return draining
+	}
+	for _, mcls := range clusters {
+		if isDraining(mcls) {
+			draining.Insert(mcls.Name)
+		}
+	}
+	return draining
+}
+
+// excludeDrainingFields drops draining fields, unless nothing would be left to answer with.
+func (c *CrossDNS) excludeDrainingFields(fields []string) []string {
+	draining := c.drainingFields()
+	if draining.Len() == 0 {
+		return fields
+	}
+	serving := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !draining.Has(field) {
+			serving = append(serving, field)
+		}
+	}
+	if len(serving) == 0 {
+		klog.Infof("All fields %s are draining, keep answering with them", fields)
+		return fields
+	}
+	return serving
+}
+
+// recordDrainState exposes the drain state of a ManagedCluster through metrics.
+func recordDrainState(obj interface{}) {
+	mcls, ok := obj.(*platformv1alpha1.ManagedCluster)
+	if !ok {
+		return
+	}
+	if isDraining(mcls) {
+		klog.Infof("Field %s is draining", mcls.Name)
+		fieldDraining.WithLabelValues(mcls.Name).Set(1)
+		return
+	}
+	fieldDraining.WithLabelValues(mcls.Name).Set(0)
+}
+
+func forgetDrainState(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	mcls, ok := obj.(*platformv1alpha1.ManagedCluster)
+	if !ok {
+		return
+	}
+	fieldDraining.DeleteLabelValues(mcls.Name)
+}
@@ -0,0 +1,24 @@
+package plugin
+
+import (
+	"github.com/coredns/coredns/plugin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	// fieldDraining is 1 for every field whose ManagedCluster is marked as draining, 0 otherwise.
+	fieldDraining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "crossdns",
+		Name:      "field_draining",
+		Help:      "Whether a field is draining (1) or serving (0).",
+	}, []string{"field"})
+	// rateLimited counts the queries and responses over their client subnet's rate limit.
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "crossdns",
+		Name:      "rate_limited_total",
+		Help:      "Counter of queries and responses over their client subnet's rate limit, by action taken.",
+	}, []string{"type", "action"})
+)
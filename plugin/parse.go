@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
@@ -36,13 +37,12 @@ type recordRequest struct {
 	podOrSvc string
 }
 
-// parseRequest parses the qname to find all the elements we need for querying lighthouse.
-// 3 Possible cases:
-// 1. (host): host.cluster.service.namespace.pod|svc.zone
-// 2. (cluster): cluster.service.namespace.pod|svc.zone
-// 3. (service): service.namespace.pod|svc.zone
-//
-// Federations are handled in the federation plugin. And aren't parsed here.
+// parseRequest parses the qname to find all the elements we need for querying crossdns.
+// 3 Possible cases for A and AAAA:
+// 1. (host): host.zone, answered with the endpoints of every field the component is placed in
+// 2. (cluster): cluster.host.zone, answered with the endpoints of that field only
+// 3. (legacy): host.any.labels.zone, deeper names keep resolving by their first label as they always did
+// SRV takes the same names, prefixed with _port._proto to ask for a single port of the host.
 func parseRequest(state *request.Request) (*recordRequest, error) {
 	r := &recordRequest{}
 
@@ -54,7 +54,7 @@ func parseRequest(state *request.Request) (*recordRequest, error) {
 
 	segs := dns.SplitDomainName(base)
 
-	return parseSegments(segs, 0, r, state.QType())
+	return parseSegments(segs, len(segs)-1, r, state.QType())
 }
 
 // String return a string representation of r, it just returns all fields concatenated with dots.
@@ -70,30 +70,33 @@ func (r *recordRequest) String() string {
 }
 
 func parseSegments(segs []string, count int, r *recordRequest, qType uint16) (*recordRequest, error) {
-	// Because of ambiguity we check the labels left: 1: a cluster. 2: hostname and cluster.
-	// Anything else is a query that is too long to answer and can safely be delegated to return an nxdomain.
-	if qType == dns.TypeA {
+	// Because of ambiguity we check the labels left: 1: a hostname. 2: cluster and hostname.
+	// Anything longer is answered for its first label, as every name was before cluster qualified names.
+	if qType == dns.TypeA || qType == dns.TypeAAAA {
 		switch count {
-		case 0: // cluster only
+		case 0: // hostname only
 			r.hostname = segs[count]
 		case 1: // cluster and hostname
-			r.cluster = segs[count]
-			r.hostname = segs[count-1]
-		default: // too long
-			return r, errInvalidRequest
+			r.cluster = segs[count-1]
+			r.hostname = segs[count]
+		default: // legacy
+			r.hostname = segs[0]
 		}
 	} else if qType == dns.TypeSRV {
+		// _port._proto.host.zone or _port._proto.cluster.host.zone, the port and protocol may be left out.
+		if count >= 1 && strings.HasPrefix(segs[0], "_") && strings.HasPrefix(segs[1], "_") {
+			r.port = stripUnderscore(segs[0])
+			r.protocol = stripUnderscore(segs[1])
+			segs = segs[2:]
+			count -= 2
+		}
 		switch count {
-		case 0: // cluster only
-			r.cluster = segs[count]
-		case 1: // endpoint only
-			r.protocol = stripUnderscore(segs[count])
-			r.port = stripUnderscore(segs[count-1])
-
-		case 2: // service and port
-			r.cluster = segs[count]
-			r.protocol = stripUnderscore(segs[count-1])
-			r.port = stripUnderscore(segs[count-2])
+		case -1: // port and protocol only
+		case 0: // hostname only
+			r.hostname = segs[count]
+		case 1: // cluster and hostname
+			r.cluster = segs[count-1]
+			r.hostname = segs[count]
 		default: // too long
 			return r, errInvalidRequest
 		}
@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultIPv4PrefixLength = 24
+	defaultIPv6PrefixLength = 56
+	defaultSlip             = 2
+	// limiters idle for longer than this are forgotten.
+	limiterIdleTimeout = 3 * time.Minute
+)
+
+// RateLimit holds the per client subnet query and response rate limits configured in the Corefile.
+type RateLimit struct {
+	// QPS and Burst limit the queries a single client subnet may send, 0 disables it.
+	QPS   float64
+	Burst int
+	// RPS limits the identical responses a single client subnet may receive, 0 disables it.
+	RPS float64
+	// Slip answers every Slip-th limited query with a truncated response and drops the others.
+	// 0 drops all of them, 1 truncates all of them.
+	Slip int
+
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+
+	queries   *limiterSet
+	responses *limiterSet
+}
+
+func newRateLimit() *RateLimit {
+	return &RateLimit{
+		Slip:             defaultSlip,
+		IPv4PrefixLength: defaultIPv4PrefixLength,
+		IPv6PrefixLength: defaultIPv6PrefixLength,
+	}
+}
+
+// init prepares the limiters once the Corefile has been parsed.
+func (rl *RateLimit) init() {
+	if rl.QPS > 0 {
+		if rl.Burst <= 0 {
+			// a burst below 1 would limit every query.
+			rl.Burst = int(math.Ceil(rl.QPS))
+		}
+		rl.queries = newLimiterSet(rate.Limit(rl.QPS), rl.Burst)
+	}
+	if rl.RPS > 0 {
+		rl.responses = newLimiterSet(rate.Limit(rl.RPS), int(math.Ceil(rl.RPS)))
+	}
+}
+
+func (rl *RateLimit) enabled() bool {
+	return rl != nil && (rl.queries != nil || rl.responses != nil)
+}
+
+// subnet returns the client subnet the query in state originates from.
+func (rl *RateLimit) subnet(state *request.Request) string {
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return state.IP()
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(rl.IPv4PrefixLength, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(rl.IPv6PrefixLength, 128)).String()
+}
+
+// allowQuery reports whether the query in state is within its subnet's query rate, and if not, whether
+// it should be answered with a truncated response instead of being dropped.
+func (rl *RateLimit) allowQuery(state *request.Request) (allowed, slip bool) {
+	if rl == nil || rl.queries == nil || state.Proto() == "tcp" {
+		return true, false
+	}
+	return rl.queries.allow(rl.subnet(state), rl.Slip)
+}
+
+// allowResponse does the same as allowQuery for responses. NXDOMAIN answers are accounted per zone, so
+// random subdomain floods share one budget.
+func (rl *RateLimit) allowResponse(state *request.Request, msg *dns.Msg) (allowed, slip bool) {
+	if rl == nil || rl.responses == nil || state.Proto() == "tcp" {
+		return true, false
+	}
+	name := state.Name()
+	if msg.Rcode == dns.RcodeNameError {
+		name = strings.ToLower(state.Zone)
+	}
+	key := rl.subnet(state) + "/" + name + "/" + strconv.Itoa(int(state.QType())) + "/" + strconv.Itoa(msg.Rcode)
+	return rl.responses.allow(key, rl.Slip)
+}
+
+// sweep forgets limiters which have not been used for a while.
+func (rl *RateLimit) sweep() {
+	if rl == nil {
+		return
+	}
+	rl.queries.sweep()
+	rl.responses.sweep()
+}
+
+type limiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+	limited  int
+}
+
+type limiterSet struct {
+	limit rate.Limit
+	burst int
+
+	mu       sync.Mutex
+	limiters map[string]*limiter
+}
+
+func newLimiterSet(limit rate.Limit, burst int) *limiterSet {
+	return &limiterSet{
+		limit:    limit,
+		burst:    burst,
+		limiters: make(map[string]*limiter),
+	}
+}
+
+func (s *limiterSet) allow(key string, slip int) (allowed, truncate bool) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.limiters[key]
+	if !ok {
+		l = &limiter{Limiter: rate.NewLimiter(s.limit, s.burst)}
+		s.limiters[key] = l
+	}
+	l.lastSeen = now
+	if l.AllowN(now, 1) {
+		return true, false
+	}
+	l.limited++
+	return false, slip > 0 && l.limited%slip == 0
+}
+
+func (s *limiterSet) sweep() {
+	if s == nil {
+		return
+	}
+	deadline := time.Now().Add(-limiterIdleTimeout)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, l := range s.limiters {
+		if l.lastSeen.Before(deadline) {
+			delete(s.limiters, key)
+		}
+	}
+}
+
+// rateLimitedWriter applies the response rate limit to everything crossdns writes.
+type rateLimitedWriter struct {
+	dns.ResponseWriter
+	state *request.Request
+	rl    *RateLimit
+}
+
+func (w *rateLimitedWriter) WriteMsg(msg *dns.Msg) error {
+	allowed, slip := w.rl.allowResponse(w.state, msg)
+	if allowed {
+		return w.ResponseWriter.WriteMsg(msg)
+	}
+	if slip {
+		rateLimited.WithLabelValues("response", "truncate").Inc()
+		return w.ResponseWriter.WriteMsg(truncated(w.state.Req))
+	}
+	rateLimited.WithLabelValues("response", "drop").Inc()
+	return nil
+}
+
+// truncated returns an empty reply to r with the TC bit set, so well behaved clients retry over TCP.
+func truncated(r *dns.Msg) *dns.Msg {
+	a := new(dns.Msg)
+	a.SetReply(r)
+	a.Truncated = true
+	return a
+}
@@ -0,0 +1,270 @@
+package plugin
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/caddy"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+	"github.com/miekg/dns"
+	"golang.org/x/time/rate"
+)
+
+// clientWriter is a test.ResponseWriter for queries from addr.
+type clientWriter struct {
+	test.ResponseWriter
+	addr string
+}
+
+func (w *clientWriter) RemoteAddr() net.Addr {
+	if w.TCP {
+		return &net.TCPAddr{IP: net.ParseIP(w.addr), Port: 40212}
+	}
+	return &net.UDPAddr{IP: net.ParseIP(w.addr), Port: 40212}
+}
+
+func newState(addr string, tcp bool, qname string, qtype uint16) *request.Request {
+	m := new(dns.Msg)
+	m.SetQuestion(qname, qtype)
+	w := &clientWriter{ResponseWriter: test.ResponseWriter{TCP: tcp}, addr: addr}
+	return &request.Request{W: w, Req: m, Zone: testZone}
+}
+
+func TestRateLimitSubnet(t *testing.T) {
+	tests := []struct {
+		addr string
+		v4   int
+		v6   int
+		want string
+	}{
+		{addr: "10.240.0.1", v4: 24, v6: 56, want: "10.240.0.0"},
+		{addr: "10.240.7.9", v4: 16, v6: 56, want: "10.240.0.0"},
+		{addr: "10.240.7.9", v4: 32, v6: 56, want: "10.240.7.9"},
+		{addr: "10.240.7.9", v4: 0, v6: 56, want: "0.0.0.0"},
+		{addr: "2001:db8:1:2:3::1", v4: 24, v6: 56, want: "2001:db8:1::"},
+		{addr: "2001:db8:1:2ff:3::1", v4: 24, v6: 56, want: "2001:db8:1:200::"},
+		{addr: "2001:db8:1:2:3::1", v4: 24, v6: 128, want: "2001:db8:1:2:3::1"},
+		{addr: "::ffff:10.240.0.1", v4: 24, v6: 56, want: "10.240.0.0"},
+	}
+	for _, tt := range tests {
+		rl := &RateLimit{IPv4PrefixLength: tt.v4, IPv6PrefixLength: tt.v6}
+		if got := rl.subnet(newState(tt.addr, false, "web."+testZone, dns.TypeA)); got != tt.want {
+			t.Errorf("subnet(%s) with /%d /%d = %s, want %s", tt.addr, tt.v4, tt.v6, got, tt.want)
+		}
+	}
+}
+
+func TestLimiterSetAllow(t *testing.T) {
+	tests := []struct {
+		name string
+		slip int
+		// want is the outcome of each query: a allowed, t truncated, d dropped.
+		want string
+	}{
+		{name: "slip 0 drops", slip: 0, want: "aadddd"},
+		{name: "slip 1 truncates", slip: 1, want: "aatttt"},
+		{name: "slip 2 alternates", slip: 2, want: "aadtdt"},
+		{name: "slip 3", slip: 3, want: "aaddtd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newLimiterSet(rate.Limit(0.001), 2)
+			var got strings.Builder
+			for range tt.want {
+				switch allowed, truncate := s.allow("10.240.0.0", tt.slip); {
+				case allowed:
+					got.WriteByte('a')
+				case truncate:
+					got.WriteByte('t')
+				default:
+					got.WriteByte('d')
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("outcomes = %s, want %s", got.String(), tt.want)
+			}
+			// other keys keep their own budget.
+			if allowed, _ := s.allow("10.241.0.0", tt.slip); !allowed {
+				t.Errorf("a different key was limited")
+			}
+		})
+	}
+}
+
+func TestAllowQuery(t *testing.T) {
+	var disabled *RateLimit
+	if allowed, _ := disabled.allowQuery(newState("10.240.0.1", false, "web."+testZone, dns.TypeA)); !allowed {
+		t.Fatalf("nil rate limit limited a query")
+	}
+
+	rl := newRateLimit()
+	rl.QPS = 0.001
+	rl.init()
+	if rl.Burst != 1 {
+		t.Fatalf("burst = %d, want the qps rounded up", rl.Burst)
+	}
+	if !rl.enabled() {
+		t.Fatalf("rate limit is not enabled")
+	}
+
+	rl = newRateLimit()
+	rl.QPS, rl.Burst = 0.001, 1
+	rl.init()
+	query := func(addr string, tcp bool) bool {
+		allowed, _ := rl.allowQuery(newState(addr, tcp, "web."+testZone, dns.TypeA))
+		return allowed
+	}
+	if !query("10.240.0.1", false) {
+		t.Fatalf("first query was limited")
+	}
+	if query("10.240.0.2", false) {
+		t.Fatalf("second query of the same /24 was allowed")
+	}
+	if !query("10.240.0.2", true) {
+		t.Fatalf("tcp query was limited")
+	}
+	if !query("10.240.1.1", false) {
+		t.Fatalf("query of another /24 was limited")
+	}
+}
+
+func TestAllowResponse(t *testing.T) {
+	rl := newRateLimit()
+	rl.RPS = 1
+	rl.init()
+	if rl.queries != nil {
+		t.Fatalf("query limit enabled without qps")
+	}
+
+	respond := func(qname string, rcode int) bool {
+		state := newState("10.240.0.1", false, qname, dns.TypeA)
+		msg := new(dns.Msg)
+		msg.SetRcode(state.Req, rcode)
+		allowed, _ := rl.allowResponse(state, msg)
+		return allowed
+	}
+	if !respond("web."+testZone, dns.RcodeSuccess) || respond("WEB."+testZone, dns.RcodeSuccess) {
+		t.Fatalf("identical responses were not limited")
+	}
+	if !respond("api."+testZone, dns.RcodeSuccess) {
+		t.Fatalf("response for another name was limited")
+	}
+	if !respond("a."+testZone, dns.RcodeNameError) || respond("b."+testZone, dns.RcodeNameError) {
+		t.Fatalf("NXDOMAIN responses of one zone do not share a budget")
+	}
+}
+
+func TestLimiterSetSweep(t *testing.T) {
+	s := newLimiterSet(rate.Limit(1), 1)
+	s.allow("idle", 0)
+	s.allow("active", 0)
+	s.limiters["idle"].lastSeen = time.Now().Add(-2 * limiterIdleTimeout)
+	s.sweep()
+	if _, ok := s.limiters["idle"]; ok {
+		t.Errorf("idle limiter was kept")
+	}
+	if _, ok := s.limiters["active"]; !ok {
+		t.Errorf("active limiter was swept")
+	}
+
+	var disabled *RateLimit
+	disabled.sweep()
+	newRateLimit().sweep()
+}
+
+func TestServeDNSRateLimited(t *testing.T) {
+	hermes := newHermes(t, hermesFields)
+	tests := []struct {
+		name  string
+		setup func(*RateLimit)
+		// want is the outcome of each query: a answered, t truncated, d dropped.
+		want string
+	}{
+		{name: "queries", setup: func(rl *RateLimit) { rl.QPS, rl.Burst, rl.Slip = 0.001, 2, 2 }, want: "aadt"},
+		{name: "responses", setup: func(rl *RateLimit) { rl.RPS, rl.Slip = 1, 1 }, want: "attt"},
+		{name: "responses dropped", setup: func(rl *RateLimit) { rl.RPS, rl.Slip = 1, 0 }, want: "addd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cd, _ := newTestCrossDNS(t, hermes.URL, shopObjects()...)
+			tt.setup(cd.RateLimit)
+			cd.RateLimit.init()
+
+			var got strings.Builder
+			for range tt.want {
+				m := new(dns.Msg)
+				m.SetQuestion("field-a.web."+testZone, dns.TypeA)
+				rec := dnstest.NewRecorder(&test.ResponseWriter{})
+				if _, err := cd.ServeDNS(context.Background(), rec, m); err != nil {
+					t.Fatal(err)
+				}
+				switch {
+				case rec.Msg == nil:
+					got.WriteByte('d')
+				case rec.Msg.Truncated && len(rec.Msg.Answer) == 0:
+					got.WriteByte('t')
+				case len(rec.Msg.Answer) == 1:
+					got.WriteByte('a')
+				default:
+					t.Fatalf("unexpected message %v", rec.Msg)
+				}
+			}
+			if got.String() != tt.want {
+				t.Errorf("outcomes = %s, want %s", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCrossDNSParseRateLimit(t *testing.T) {
+	tests := []struct {
+		name  string
+		block string
+		err   string
+		want  RateLimit
+	}{
+		{name: "defaults", block: "",
+			want: RateLimit{Slip: 2, IPv4PrefixLength: 24, IPv6PrefixLength: 56}},
+		{name: "qps only", block: "ratelimit 100",
+			want: RateLimit{QPS: 100, Burst: 100, Slip: 2, IPv4PrefixLength: 24, IPv6PrefixLength: 56}},
+		{name: "everything", block: "ratelimit 50 200\nrrl 5\nslip 0\nipv4-prefix-length 32\nipv6-prefix-length 64",
+			want: RateLimit{QPS: 50, Burst: 200, RPS: 5, IPv4PrefixLength: 32, IPv6PrefixLength: 64}},
+		{name: "ratelimit without qps", block: "ratelimit", err: "Wrong argument count"},
+		{name: "ratelimit with too many args", block: "ratelimit 1 2 3", err: "Wrong argument count"},
+		{name: "negative qps", block: "ratelimit -1", err: "invalid ratelimit qps"},
+		{name: "invalid burst", block: "ratelimit 1 x", err: "invalid ratelimit burst"},
+		{name: "invalid rrl", block: "rrl x", err: "invalid rrl responses per second"},
+		{name: "slip out of range", block: "slip 11", err: "invalid slip '11', must be within [0, 10]"},
+		{name: "ipv4 prefix out of range", block: "ipv4-prefix-length 33", err: "invalid ipv4-prefix-length"},
+		{name: "ipv6 prefix out of range", block: "ipv6-prefix-length 129", err: "invalid ipv6-prefix-length"},
+		{name: "unknown property", block: "burst 1", err: "unknown property 'burst'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeClientset(t)
+			cd, err := CrossDNSParse(caddy.NewTestController("dns", "crossdns "+testZone+" {\n"+tt.block+"\n}"))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := *cd.RateLimit
+			got.queries, got.responses = nil, nil
+			if got != tt.want {
+				t.Errorf("rate limit = %+v, want %+v", got, tt.want)
+			}
+			if cd.RateLimit.enabled() != (tt.want.QPS > 0 || tt.want.RPS > 0) {
+				t.Errorf("enabled = %v", cd.RateLimit.enabled())
+			}
+		})
+	}
+}
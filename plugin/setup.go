@@ -3,12 +3,15 @@ package plugin
 import (
 	"context"
 	"flag"
+	"strconv"
 	"time"
 
 	"github.com/dixudx/yacht"
 	"github.com/lmxia/gaia/pkg/apis/apps/v1alpha1"
 	"github.com/lmxia/gaia/pkg/common"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/klog/v2"
@@ -26,8 +29,13 @@ var (
 	kubeconfig string
 )
 
-// Hook for unit tests.
-var buildKubeConfigFunc = clientcmd.BuildConfigFromFlags
+// Hooks for unit tests.
+var (
+	buildKubeConfigFunc = clientcmd.BuildConfigFromFlags
+	newGaiaClientFunc   = func(cfg *rest.Config) (gaiaclientset.Interface, error) {
+		return gaiaclientset.NewForConfig(cfg)
+	}
+)
 
 // init registers this plugin within the Caddy plugin framework. It uses "example" as the
 // name, and couples it to the Action "setup".
@@ -81,9 +89,13 @@ func CrossDNSParse(c *caddy.Controller) (*CrossDNS, error) {
 
 	ctx := context.Background()
 	initCtx, cancel := context.WithCancel(ctx)
-	cd := &CrossDNS{}
+	cd := &CrossDNS{serial: uint32(time.Now().Unix()), RateLimit: newRateLimit()}
 
-	localGaiaClientSet := gaiaclientset.NewForConfigOrDie(cfg)
+	localGaiaClientSet, err := newGaiaClientFunc(cfg)
+	if err != nil {
+		cancel()
+		return nil, errors.Wrap(err, "error building gaia clientset")
+	}
 
 	localAllGaiaInformerFactory := gaiainformers.NewSharedInformerFactory(localGaiaClientSet, 2*time.Hour)
 	rbInformer := localAllGaiaInformerFactory.Apps().V1alpha1().ResourceBindings()
@@ -92,6 +104,9 @@ func CrossDNSParse(c *caddy.Controller) (*CrossDNS, error) {
 	cd.descLister = descInformer.Lister()
 	cd.rbSynced = rbInformer.Informer().HasSynced
 	cd.indexedStore = indexedStore
+	mclsInformer := localAllGaiaInformerFactory.Platform().V1alpha1().ManagedClusters()
+	cd.mclsLister = mclsInformer.Lister()
+	cd.mclsSynced = mclsInformer.Informer().HasSynced
 
 	yachtController := yacht.NewController("desc").
 		WithCacheSynced(descInformer.Informer().HasSynced).
@@ -121,6 +136,17 @@ func CrossDNSParse(c *caddy.Controller) (*CrossDNS, error) {
 		cancel()
 		return nil, err
 	}
+	_, err = mclsInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: recordDrainState,
+		UpdateFunc: func(_, newObj interface{}) {
+			recordDrainState(newObj)
+		},
+		DeleteFunc: forgetDrainState,
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
 	go wait.UntilWithContext(initCtx, func(ctx context.Context) {
 		yachtController.Run(ctx)
 	}, time.Duration(0))
@@ -145,6 +171,51 @@ func CrossDNSParse(c *caddy.Controller) (*CrossDNS, error) {
 			switch c.Val() {
 			case "fallthrough":
 				cd.Fall.SetZonesFromArgs(c.RemainingArgs())
+			case "ratelimit":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr() // nolint:wrapcheck // No need to wrap this.
+				}
+				qps, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || qps < 0 {
+					return nil, c.Errf("invalid ratelimit qps '%s'", args[0]) // nolint:wrapcheck // No need to wrap this.
+				}
+				cd.RateLimit.QPS = qps
+				if len(args) == 2 {
+					burst, err := strconv.Atoi(args[1])
+					if err != nil || burst < 0 {
+						return nil, c.Errf("invalid ratelimit burst '%s'", args[1]) // nolint:wrapcheck // No need to wrap this.
+					}
+					cd.RateLimit.Burst = burst
+				}
+			case "rrl":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr() // nolint:wrapcheck // No need to wrap this.
+				}
+				rps, err := strconv.ParseFloat(args[0], 64)
+				if err != nil || rps < 0 {
+					return nil, c.Errf("invalid rrl responses per second '%s'", args[0]) // nolint:wrapcheck // No need to wrap this.
+				}
+				cd.RateLimit.RPS = rps
+			case "slip":
+				n, err := singleIntArg(c, 0, 10)
+				if err != nil {
+					return nil, err
+				}
+				cd.RateLimit.Slip = n
+			case "ipv4-prefix-length":
+				n, err := singleIntArg(c, 0, 32)
+				if err != nil {
+					return nil, err
+				}
+				cd.RateLimit.IPv4PrefixLength = n
+			case "ipv6-prefix-length":
+				n, err := singleIntArg(c, 0, 128)
+				if err != nil {
+					return nil, err
+				}
+				cd.RateLimit.IPv6PrefixLength = n
 			default:
 				if c.Val() != "}" {
 					return nil, c.Errf("unknown property '%s'", c.Val()) // nolint:wrapcheck // No need to wrap this.
@@ -152,9 +223,29 @@ func CrossDNSParse(c *caddy.Controller) (*CrossDNS, error) {
 			}
 		}
 	}
+	cd.RateLimit.init()
+	if cd.RateLimit.enabled() {
+		go wait.UntilWithContext(initCtx, func(ctx context.Context) {
+			cd.RateLimit.sweep()
+		}, time.Minute)
+	}
 	return cd, nil
 }
 
+// singleIntArg parses the only argument of the current property as an int within [min, max].
+func singleIntArg(c *caddy.Controller, min, max int) (int, error) {
+	property := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr() // nolint:wrapcheck // No need to wrap this.
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < min || n > max {
+		return 0, c.Errf("invalid %s '%s', must be within [%d, %d]", property, args[0], min, max) // nolint:wrapcheck // No need to wrap this.
+	}
+	return n, nil
+}
+
 // Handle Actually don't really need this, make it happened in filter is also fine,
 // I just don't want slow down enqueue proceed.
 func (cd *CrossDNS) Handle(obj interface{}) (requeueAfter *time.Duration, err error) {
@@ -166,6 +257,11 @@ func (cd *CrossDNS) Handle(obj interface{}) (requeueAfter *time.Duration, err er
 		return &failedPeriod, nil
 	}
 	cachedDesc, err := cd.descLister.Descriptions(namespace).Get(descName)
+	if apierrors.IsNotFound(err) {
+		// deleted without us seeing its deletion timestamp.
+		cd.indexedStore.Delete(descName)
+		return nil, nil
+	}
 	if err != nil {
 		klog.Errorf("can't get description from: %s/%s", namespace, descName)
 		return &failedPeriod, nil
@@ -21,6 +21,7 @@ func addGaiaRoutes(rg *gin.RouterGroup) {
 	{
 		descRouter.GET("", gaia.GetDescriptions)
 		descRouter.GET("/:namespace/:descName", gaia.GetDescription)
+		descRouter.GET("/:namespace/:descName/export", gaia.ExportDescription)
 		descRouter.GET("/status", gaia.GetDescriptionStatus)
 		descRouter.GET("/scn", gaia.ScnsIsInTheSameDesc)
 		descRouter.DELETE("/gaia-reserved/:descName", gaia.DeleteDescription)
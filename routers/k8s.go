@@ -25,25 +25,79 @@ func addK8sRoutes(rg *gin.RouterGroup) {
 	router.PATCH("/deployments/:namespace/:deploymentName", k8sv1.PatchDeployment)
 	router.GET("/deployment_status/:namespace/:deploymentName", k8sv1.GetDeploymentStatus)
 	router.GET("/deployment_pods/:namespace/:deploymentName", k8sv1.GetDeploymentPods)
+	router.GET("/watch/deployment_status/:namespace/:deploymentName", k8sv1.WatchDeploymentStatus)
+	router.GET("/rollouts/:namespace/:deploymentName", k8sv1.GetRollout)
+	router.POST("/rollouts/:namespace/:deploymentName", k8sv1.PostRollout)
+	router.DELETE("/rollouts/:namespace/:deploymentName", k8sv1.DeleteRollout)
+
+	router.GET("/statefulsets", k8sv1.GetStatefulSets)
+	router.GET("/statefulsets/:namespace/:statefulsetName", k8sv1.GetStatefulSet)
+	router.POST("/statefulsets", k8sv1.PostStatefulSet)
+	router.POST("/statefulsets/:namespace/:statefulsetName", k8sv1.StatefulSetDoAction)
+	router.PUT("/statefulsets/:namespace/:statefulsetName", k8sv1.PutStatefulSet)
+	router.DELETE("/statefulsets/:namespace/:statefulsetName", k8sv1.DeleteStatefulSet)
+	router.GET("/statefulset_status/:namespace/:statefulsetName", k8sv1.GetStatefulSetStatus)
+	router.GET("/statefulset_pods/:namespace/:statefulsetName", k8sv1.GetStatefulSetPods)
+
+	router.GET("/daemonsets", k8sv1.GetDaemonSets)
+	router.GET("/daemonsets/:namespace/:daemonsetName", k8sv1.GetDaemonSet)
+	router.POST("/daemonsets", k8sv1.PostDaemonSet)
+	router.POST("/daemonsets/:namespace/:daemonsetName", k8sv1.DaemonSetDoAction)
+	router.PUT("/daemonsets/:namespace/:daemonsetName", k8sv1.PutDaemonSet)
+	router.DELETE("/daemonsets/:namespace/:daemonsetName", k8sv1.DeleteDaemonSet)
+	router.GET("/daemonset_status/:namespace/:daemonsetName", k8sv1.GetDaemonSetStatus)
+	router.GET("/daemonset_pods/:namespace/:daemonsetName", k8sv1.GetDaemonSetPods)
 
 	router.GET("/services", k8sv1.GetServices)
+	router.POST("/services", k8sv1.PostService)
 	router.GET("/services/:namespace/:serviceName", k8sv1.GetService)
+	router.PUT("/services/:namespace/:serviceName", k8sv1.PutService)
+	router.DELETE("/services/:namespace/:serviceName", k8sv1.DeleteService)
+	router.GET("/services/:namespace/:serviceName/endpoints", k8sv1.GetServiceEndpoints)
+
+	router.GET("/ingresses", k8sv1.GetIngresses)
+	router.POST("/ingresses", k8sv1.PostIngress)
+	router.GET("/ingresses/:namespace/:ingressName", k8sv1.GetIngress)
+	router.PUT("/ingresses/:namespace/:ingressName", k8sv1.PutIngress)
+	router.DELETE("/ingresses/:namespace/:ingressName", k8sv1.DeleteIngress)
 
 	router.GET("/jobs", k8sv1.GetJobs)
+	router.POST("/jobs", k8sv1.PostJob)
 	router.GET("/jobs/:namespace/:jobName", k8sv1.GetJob)
+	router.GET("/jobs/:namespace/:jobName/logs", k8sv1.GetJobLogs)
+	router.POST("/jobs/:namespace/:jobName/rerun", k8sv1.RerunJob)
 	router.DELETE("/jobs/:namespace/:jobName", k8sv1.DeleteJob)
 
 	router.GET("/cronjobs", k8sv1.GetCronJobs)
 	router.POST("/cronjobs", k8sv1.PostCronJob)
 	router.GET("/cronjobs/:namespace/:cronjobName", k8sv1.GetCronJob)
+	router.POST("/cronjobs/:namespace/:cronjobName", k8sv1.CronJobDoAction)
+	router.POST("/cronjobs/:namespace/:cronjobName/trigger", k8sv1.TriggerCronJob)
 	router.PUT("/cronjobs/:namespace/:cronjobName", k8sv1.PutCronJob)
 	router.DELETE("/cronjobs/:namespace/:cronjobName", k8sv1.DeleteCronJob)
 
+	router.GET("/secrets", k8sv1.GetSecrets)
+	router.POST("/secrets", k8sv1.PostSecret)
+	router.GET("/secrets/:namespace/:secretName", k8sv1.GetSecret)
+	router.PUT("/secrets/:namespace/:secretName", k8sv1.PutSecret)
+	router.DELETE("/secrets/:namespace/:secretName", k8sv1.DeleteSecret)
+
+	router.GET("/persistentvolumeclaims", k8sv1.GetPersistentVolumeClaims)
+	router.POST("/persistentvolumeclaims", k8sv1.PostPersistentVolumeClaim)
+	router.GET("/persistentvolumeclaims/:namespace/:claimName", k8sv1.GetPersistentVolumeClaim)
+	router.DELETE("/persistentvolumeclaims/:namespace/:claimName", k8sv1.DeletePersistentVolumeClaim)
+	router.POST("/persistentvolumeclaims/:namespace/:claimName/expand", k8sv1.ExpandPersistentVolumeClaim)
+	router.GET("/persistentvolumes", k8sv1.GetPersistentVolumes)
+	router.GET("/storageclasses", k8sv1.GetStorageClasses)
+	router.GET("/storage_summary/:namespace", k8sv1.GetStorageSummary)
+
 	router.GET("/events", k8sv1.GetEvents)
 
 	router.GET("/nodes", k8sv1.GetNodes)
 	router.GET("/namespaces", k8sv1.GetNamespaces)
 
+	router.GET("/configmaps", k8sv1.GetConfigmaps)
+	router.POST("/configmaps", k8sv1.PostConfigmap)
 	router.GET("/configmaps/:namespace/:name", k8sv1.GetConfigmap)
 	router.PUT("/configmaps/:namespace/:name", k8sv1.PutConfigmap)
 	router.DELETE("/configmaps/:namespace/:name", k8sv1.DeleteConfigmap)
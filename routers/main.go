@@ -5,8 +5,10 @@ import (
 	adminv1 "github.com/lmxia/nightwatcher/api/v1/admin"
 	"github.com/lmxia/nightwatcher/app"
 	docs "github.com/lmxia/nightwatcher/docs"
+	"github.com/lmxia/nightwatcher/middleware"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
+	"k8s.io/klog/v2"
 )
 
 func InitRouter() *gin.Engine {
@@ -16,15 +18,28 @@ func InitRouter() *gin.Engine {
 	r.NoMethod(app.HandleNotMethod)
 	r.NoRoute(app.HandleNotFound)
 	// Authentication
+	authenticator, err := middleware.NewAuthenticatorFromEnv()
+	if err != nil {
+		klog.Fatalf("Failed to set up authentication: %v", err)
+	}
+	if authenticator == nil {
+		klog.Warning("Authentication is turned off, every /api/v1 route is open")
+	}
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	docs.SwaggerInfo.BasePath = "/api/v1"
 	apiv1 := r.Group("/api/v1")
 	{
 		apiv1.GET("/health_check", adminv1.HealthCheck)
+		authorized := apiv1.Group("", middleware.Authenticate(authenticator))
 		// k8s api
-		addK8sRoutes(apiv1)
+		addK8sRoutes(authorized)
+		addResourceRoutes(authorized)
+		// k8s api of the gaia managed clusters
+		cluster := authorized.Group("/clusters/:cluster", middleware.TargetCluster())
+		addK8sRoutes(cluster)
+		addResourceRoutes(cluster)
 		// gaia api
-		addGaiaRoutes(apiv1)
+		addGaiaRoutes(authorized)
 	}
 	return r
 }
@@ -0,0 +1,28 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	k8sv1 "github.com/lmxia/nightwatcher/api/v1/k8s"
+)
+
+// addResourceRoutes serves any resource the apiserver discovers, core resources are under the "core" group.
+func addResourceRoutes(rg *gin.RouterGroup) {
+	rg.POST("/apply", k8sv1.PostApply)
+	rg.GET("/export/namespaces/:namespace", k8sv1.ExportNamespace)
+
+	router := rg.Group("/resources/:group/:version/:resource")
+
+	router.GET("", k8sv1.GetResource)
+	router.POST("", k8sv1.PostResource)
+
+	router.GET("/:namespace", k8sv1.GetResource)
+	router.POST("/:namespace", k8sv1.PostResource)
+	router.PUT("/:namespace", k8sv1.PutResource)
+	router.PATCH("/:namespace", k8sv1.PatchResource)
+	router.DELETE("/:namespace", k8sv1.DeleteResource)
+
+	router.GET("/:namespace/:name", k8sv1.GetResource)
+	router.PUT("/:namespace/:name", k8sv1.PutResource)
+	router.PATCH("/:namespace/:name", k8sv1.PatchResource)
+	router.DELETE("/:namespace/:name", k8sv1.DeleteResource)
+}
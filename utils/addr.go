@@ -36,13 +36,13 @@ func GetEnvDefault(key, defVal string) string {
 	return val
 }
 
-// FilterAccessServiceIPFrom  filter out from hermes which fields
-func FilterAccessServiceIPFrom(fields sets.Set[string]) ([]string, error) {
-	realEndpoints := make([]string, 0)
+// QueryHermes runs a PromQL query over the last window on hermes.
+func QueryHermes(promQL string, window time.Duration) (*QueryPromResp, error) {
+	now := time.Now()
 	param := HermesQueryParam{
-		QueryValue: fmt.Sprintf("container_cpu_usage_seconds_total{component_name=\"%s\"}", GetEnvDefault("ACCESS_SERVICE_NAME", "gaia")),
-		StartTime:  time.Now().Add(-time.Minute * 1).Format(time.RFC3339Nano),
-		EndTime:    time.Now().Format(time.RFC3339Nano),
+		QueryValue: promQL,
+		StartTime:  now.Add(-window).Format(time.RFC3339Nano),
+		EndTime:    now.Format(time.RFC3339Nano),
 	}
 	v, err := query.Values(param)
 	if err != nil {
@@ -52,9 +52,7 @@ func FilterAccessServiceIPFrom(fields sets.Set[string]) ([]string, error) {
 	path := fmt.Sprintf("/query?%s", v.Encode())
 	resp, err := NewHttpClient().Call(WithToHermes(), WithPath(path), WithUsePost())
 	if err != nil {
-		// we can't get from hermes, it's unstable. so give a default access service ip.
-		//realEndpoints = append(realEndpoints, GetEnvDefault("ACCESS_SERVICE_DEFAULT_IP", "172.17.2.35"))
-		return realEndpoints, err
+		return nil, err
 	}
 
 	defer resp.Body.Close()
@@ -64,6 +62,18 @@ func FilterAccessServiceIPFrom(fields sets.Set[string]) ([]string, error) {
 		klog.Errorf("Can't decode result from hermes")
 		return nil, err
 	}
+	return &result, nil
+}
+
+// FilterAccessServiceIPFrom  filter out from hermes which fields
+func FilterAccessServiceIPFrom(fields sets.Set[string]) ([]string, error) {
+	realEndpoints := make([]string, 0)
+	result, err := QueryHermes(fmt.Sprintf("container_cpu_usage_seconds_total{component_name=\"%s\"}", GetEnvDefault("ACCESS_SERVICE_NAME", "gaia")), time.Minute)
+	if err != nil {
+		// we can't get from hermes, it's unstable. so give a default access service ip.
+		//realEndpoints = append(realEndpoints, GetEnvDefault("ACCESS_SERVICE_DEFAULT_IP", "172.17.2.35"))
+		return realEndpoints, err
+	}
 	for _, item := range result.QueryValM {
 		cloneSet := item.Metric.Clone()
 		// 当前所属的field名称，在我们查出来的field内
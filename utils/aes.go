@@ -4,7 +4,16 @@ import (
 	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"encoding/base64"
+	"errors"
+)
+
+var (
+	errAesIV = errors.New("aes: IV length must equal block size")
+	// errAesDecrypt is every failure to decrypt, the cause is not told apart so that it can't be
+	// probed for.
+	errAesDecrypt = errors.New("aes: decryption failed")
 )
 
 // 加密 aes_128_cbc
@@ -16,6 +25,9 @@ func AesEncrypt(encryptStr string, key []byte, iv string) (string, error) {
 	}
 
 	blockSize := block.BlockSize()
+	if len(iv) != blockSize {
+		return "", errAesIV
+	}
 	encryptBytes = pkcs5Padding(encryptBytes, blockSize)
 
 	blockMode := cipher.NewCBCEncrypter(block, []byte(iv))
@@ -28,7 +40,7 @@ func AesEncrypt(encryptStr string, key []byte, iv string) (string, error) {
 func AesDecrypt(decryptStr string, key []byte, iv string) (string, error) {
 	decryptBytes, err := base64.URLEncoding.DecodeString(decryptStr)
 	if err != nil {
-		return "", err
+		return "", errAesDecrypt
 	}
 
 	block, err := aes.NewCipher(key)
@@ -36,22 +48,74 @@ func AesDecrypt(decryptStr string, key []byte, iv string) (string, error) {
 		return "", err
 	}
 
+	if len(iv) != block.BlockSize() {
+		return "", errAesIV
+	}
+	if len(decryptBytes) == 0 || len(decryptBytes)%block.BlockSize() != 0 {
+		return "", errAesDecrypt
+	}
 	blockMode := cipher.NewCBCDecrypter(block, []byte(iv))
 	decrypted := make([]byte, len(decryptBytes))
 
 	blockMode.CryptBlocks(decrypted, decryptBytes)
-	decrypted = pkcs5UnPadding(decrypted)
+	decrypted, err = pkcs5UnPadding(decrypted, block.BlockSize())
+	if err != nil {
+		return "", err
+	}
 	return string(decrypted), nil
 }
 
+// AesGCMEncrypt seals plaintext with AES-GCM under key and a random nonce, authenticating
+// additionalData along, and returns the nonce followed by the ciphertext, URL-safe base64 encoded.
+func AesGCMEncrypt(plaintext, key, additionalData []byte) (string, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, additionalData)), nil
+}
+
+// AesGCMDecrypt opens what AesGCMEncrypt sealed under key with the same additionalData. Values
+// tampered with, sealed under another key or with other additionalData all fail alike.
+func AesGCMDecrypt(sealed string, key, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := base64.URLEncoding.DecodeString(sealed)
+	if err != nil || len(data) < aead.NonceSize()+aead.Overhead() {
+		return nil, errAesDecrypt
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errAesDecrypt
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
 func pkcs5Padding(cipherText []byte, blockSize int) []byte {
 	padding := blockSize - len(cipherText)%blockSize
 	padText := bytes.Repeat([]byte{byte(padding)}, padding)
 	return append(cipherText, padText...)
 }
 
-func pkcs5UnPadding(decrypted []byte) []byte {
+func pkcs5UnPadding(decrypted []byte, blockSize int) ([]byte, error) {
 	length := len(decrypted)
 	unPadding := int(decrypted[length-1])
-	return decrypted[:(length - unPadding)]
+	if unPadding == 0 || unPadding > blockSize || unPadding > length {
+		return nil, errAesDecrypt
+	}
+	return decrypted[:(length - unPadding)], nil
 }
@@ -0,0 +1,16 @@
+package utils
+
+import "context"
+
+type clusterKey struct{}
+
+// WithCluster returns a copy of ctx targeting the named ManagedCluster instead of the local cluster.
+func WithCluster(ctx context.Context, cluster string) context.Context {
+	return context.WithValue(ctx, clusterKey{}, cluster)
+}
+
+// ClusterFrom returns the ManagedCluster targeted by ctx, if any.
+func ClusterFrom(ctx context.Context) (string, bool) {
+	cluster, ok := ctx.Value(clusterKey{}).(string)
+	return cluster, ok && cluster != ""
+}
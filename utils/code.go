@@ -217,6 +217,7 @@ func init() {
 	register(ErrTokenInvalid, 401, "Token 不合法")
 	register(ErrExpired, 401, "Token 已过期")
 	register(ErrSignatureInvalid, 401, "签名不合法")
+	register(ErrInvalidAuthHeader, 401, "认证头部不合法")
 	register(ErrMissingHeader, 401, "缺少认证头部")
 	register(ErrPasswordIncorrect, 401, "密码不正确")
 	register(ErrPermissionDenied, 403, "没有操作权限")
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContext is the number of unchanged lines around the changes of a hunk, as diff -u.
+const diffContext = 3
+
+// MaxDiffLines bounds the lines of the two texts UnifiedDiff compares, together. Finding the changes
+// takes up to the product of their lengths in time.
+const MaxDiffLines = 20000
+
+// ErrDiffTooLarge is returned by UnifiedDiff for texts of more than MaxDiffLines lines.
+var ErrDiffTooLarge = fmt.Errorf("more than %d lines to diff", MaxDiffLines)
+
+// UnifiedDiff returns the differences between the texts a and b in the unified format of diff -u,
+// naming them from and to. It is empty when they are equal.
+func UnifiedDiff(from, to, a, b string) (string, error) {
+	if a == b {
+		return "", nil
+	}
+	aLines, bLines := splitLines(a), splitLines(b)
+	if len(aLines)+len(bLines) > MaxDiffLines {
+		return "", ErrDiffTooLarge
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        aLines,
+		B:        bLines,
+		FromFile: from,
+		ToFile:   to,
+		Context:  diffContext,
+	})
+}
+
+// splitLines splits s into lines ending with their newline, a last line without one gets it.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(s, "\n"), "\n")
+	lines[len(lines)-1] += "\n"
+	return lines
+}
@@ -0,0 +1,78 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines l1 to ln, with the lines in changed upper cased.
+func numbered(n int, changed ...int) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line := fmt.Sprintf("l%d", i)
+		for _, c := range changed {
+			if c == i {
+				line = strings.ToUpper(line)
+			}
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{name: "equal", a: numbered(5), b: numbered(5), want: ""},
+		{name: "one line", a: numbered(9), b: numbered(9, 5),
+			want: "@@ -2,7 +2,7 @@\n l2\n l3\n l4\n-l5\n+L5\n l6\n l7\n l8\n"},
+		{name: "first line", a: numbered(2), b: numbered(2, 1),
+			want: "@@ -1,2 +1,2 @@\n-l1\n+L1\n l2\n"},
+		{name: "from nothing", a: "", b: "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n"},
+		{name: "to nothing", a: "a\n", b: "",
+			want: "@@ -1 +0,0 @@\n-a\n"},
+		{name: "insertion", a: "a\nc\n", b: "a\nb\nc\n",
+			want: "@@ -1,2 +1,3 @@\n a\n+b\n c\n"},
+		{name: "far apart changes", a: numbered(20), b: numbered(20, 2, 18),
+			want: "@@ -1,5 +1,5 @@\n l1\n-l2\n+L2\n l3\n l4\n l5\n" +
+				"@@ -15,6 +15,6 @@\n l15\n l16\n l17\n-l18\n+L18\n l19\n l20\n"},
+		{name: "close changes share a hunk", a: numbered(12), b: numbered(12, 3, 8),
+			want: "@@ -1,11 +1,11 @@\n l1\n l2\n-l3\n+L3\n l4\n l5\n l6\n l7\n-l8\n+L8\n l9\n l10\n l11\n"},
+		{name: "missing last newline", a: "a\nb", b: "a\nc\n",
+			want: "@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := UnifiedDiff("live", "updated", tt.a, tt.b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" {
+				tt.want = "--- live\n+++ updated\n" + tt.want
+			}
+			if got != tt.want {
+				t.Errorf("diff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiffLarge(t *testing.T) {
+	half := MaxDiffLines / 2
+	got, err := UnifiedDiff("live", "updated", numbered(half), numbered(half, half/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(got, "@@ -") != 1 || !strings.Contains(got, fmt.Sprintf("-l%d\n+L%d\n", half/2, half/2)) {
+		t.Errorf("diff = %s", got)
+	}
+
+	if _, err := UnifiedDiff("live", "updated", numbered(half), numbered(half+1)); err != ErrDiffTooLarge {
+		t.Errorf("err = %v, want ErrDiffTooLarge", err)
+	}
+}
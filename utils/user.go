@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"context"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+)
+
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying the authenticated caller.
+func WithUser(ctx context.Context, user *authenticationv1.UserInfo) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the authenticated caller carried by ctx, if any.
+func UserFrom(ctx context.Context) (*authenticationv1.UserInfo, bool) {
+	user, ok := ctx.Value(userKey{}).(*authenticationv1.UserInfo)
+	return user, ok && user != nil
+}